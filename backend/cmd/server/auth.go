@@ -0,0 +1,82 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultOrg owns every repo when no API keys are configured, i.e. a
+// single-tenant instance
+const defaultOrg = "default"
+
+// OrgMiddleware resolves the caller's org from its API key, sent as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>". Requests without a
+// known key are rejected with 401. When no API keys are configured every
+// request belongs to defaultOrg.
+func OrgMiddleware() gin.HandlerFunc {
+	orgs := cfg.APIKeys
+
+	return func(c *gin.Context) {
+		if len(orgs) == 0 {
+			c.Set("org_id", defaultOrg)
+			c.Next()
+			return
+		}
+
+		key := apiKey(c)
+		org, ok := orgs[key]
+		if key == "" || !ok {
+			respondError(c, 401, "Missing or invalid API key")
+			return
+		}
+		c.Set("org_id", org)
+		c.Next()
+	}
+}
+
+// apiKey returns the API key the request was sent with, if any
+func apiKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
+
+// RepoScopeMiddleware answers 404 for any :repo_id route whose repo
+// belongs to another org, so handlers only see the caller's repos
+func RepoScopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repoID := c.Param("repo_id")
+		if repoID == "" {
+			c.Next()
+			return
+		}
+
+		var exists bool
+		err := db.QueryRow("SELECT TRUE FROM repos WHERE id = $1 AND org_id = $2", repoID, orgID(c)).Scan(&exists)
+		if err == sql.ErrNoRows {
+			respondError(c, 404, "Repo not found")
+			return
+		} else if err != nil {
+			respondError(c, 500, err.Error())
+			return
+		}
+		c.Next()
+	}
+}
+
+// orgID returns the org OrgMiddleware resolved for the request
+func orgID(c *gin.Context) string {
+	return c.GetString("org_id")
+}
+
+// orgRepoID scopes a repo ID to its org. The default org keeps plain IDs
+// so single-tenant instances are unaffected.
+func orgRepoID(org, repoID string) string {
+	if org == defaultOrg {
+		return repoID
+	}
+	return org + "__" + repoID
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiError is the envelope of every error response:
+// {"error": {"code": "not_found", "message": "Repo not found"}}. Code is
+// stable for clients to branch on; Message is for people. Requests with
+// invalid fields get code "validation_failed" and a detail per field.
+type apiError struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []fieldError `json:"details,omitempty"`
+}
+
+// fieldError is what is wrong with one request field. Field is its JSON
+// name, dotted for nested fields, e.g. "environments.prod.sampling_rate".
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// errorCodes are the codes of error responses by HTTP status
+var errorCodes = map[int]string{
+	400: "bad_request",
+	401: "unauthorized",
+	404: "not_found",
+	409: "conflict",
+	413: "payload_too_large",
+	429: "rate_limited",
+	500: "internal_error",
+	502: "upstream_error",
+	503: "unavailable",
+	504: "timeout",
+}
+
+// errorCode returns the code of an error response with status
+func errorCode(status int) string {
+	if code, ok := errorCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return "internal_error"
+	}
+	return "bad_request"
+}
+
+// errorBody is the body of an error response with status
+func errorBody(status int, message string) gin.H {
+	return gin.H{"error": apiError{Code: errorCode(status), Message: message}}
+}
+
+// respondError writes an error response and stops the request's remaining
+// handlers, so middleware can use it too
+func respondError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, errorBody(status, message))
+}
+
+// fieldErrors collects everything wrong with a request's fields, so a
+// client can fix them all at once
+type fieldErrors []fieldError
+
+// check records err, if any, against field
+func (v *fieldErrors) check(field string, err error) {
+	if err != nil {
+		*v = append(*v, fieldError{Field: field, Message: err.Error()})
+	}
+}
+
+// add records a problem with field
+func (v *fieldErrors) add(field, format string, args ...interface{}) {
+	*v = append(*v, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// dedupe drops repeated problems, e.g. a request-wide default found
+// invalid for each environment it fills in
+func (v fieldErrors) dedupe() fieldErrors {
+	seen := map[fieldError]bool{}
+	out := fieldErrors{}
+	for _, f := range v {
+		if !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// respond writes the problems found as a 400 and reports whether there
+// were any
+func (v fieldErrors) respond(c *gin.Context) bool {
+	if len(v) == 0 {
+		return false
+	}
+	fields := []string{}
+	for _, f := range v {
+		fields = append(fields, f.Field)
+	}
+	c.AbortWithStatusJSON(400, gin.H{"error": apiError{
+		Code:    "validation_failed",
+		Message: "Invalid fields: " + strings.Join(fields, ", "),
+		Details: v,
+	}})
+	return true
+}
+
+// bindJSON decodes the request body into req. A body that isn't JSON, or a
+// field of the wrong type, is answered with a 400 naming the problem, and
+// false is returned.
+func bindJSON(c *gin.Context, req interface{}) bool {
+	err := c.ShouldBindJSON(req)
+	if err == nil {
+		return true
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		v := fieldErrors{}
+		v.add(typeErr.Field, "must be %s, got %s", jsonTypeName(typeErr.Type), typeErr.Value)
+		v.respond(c)
+	case errors.As(err, &syntaxErr):
+		respondError(c, 400, fmt.Sprintf("Request body is not valid JSON: %v at offset %d", syntaxErr, syntaxErr.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		respondError(c, 400, "Request body is not valid JSON: it ends early")
+	case errors.Is(err, io.EOF):
+		respondError(c, 400, "Request body is required")
+	default:
+		respondError(c, 400, "Invalid request body: "+err.Error())
+	}
+	return false
+}
+
+// jsonTypeName describes the JSON a Go type is decoded from
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
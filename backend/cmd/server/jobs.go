@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"observability-copilot/pkg/logging"
+)
+
+// Job statuses as stored in the jobs table
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+var (
+	// errQueueFull is returned when every queue slot is taken
+	errQueueFull = errors.New("job queue is full, try again later")
+
+	// errShuttingDown fails jobs the server stops before they run
+	errShuttingDown = errors.New("server is shutting down")
+)
+
+// job is a unit of background work. Run's result is stored as JSON. Its
+// context carries the ID of the request that queued it and is cancelled
+// when shutdown runs out of time.
+type job struct {
+	ID        string
+	RequestID string
+	Run       func(ctx context.Context) (interface{}, error)
+}
+
+// Job is a job's stored state as returned by the status endpoint
+type Job struct {
+	ID        string          `json:"job_id"`
+	Kind      string          `json:"kind"`
+	RepoID    string          `json:"repo_id"`
+	Status    string          `json:"status"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+var (
+	jobQueue chan job
+
+	// queueMu guards closing jobQueue against concurrent enqueues
+	queueMu      sync.RWMutex
+	queueStopped bool
+
+	workers    sync.WaitGroup
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+)
+
+// startJobWorkers creates the bounded queue of JobQueueSize jobs and
+// starts JobWorkers workers draining it. Jobs left queued or running by a
+// previous process are marked failed.
+func startJobWorkers() error {
+	_, err := db.Exec(
+		"UPDATE jobs SET status = $1, error = 'interrupted by server restart', updated_at = NOW() WHERE status IN ($2, $3)",
+		jobFailed, jobQueued, jobRunning,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to recover jobs: %w", err)
+	}
+
+	jobQueue = make(chan job, cfg.JobQueueSize)
+	jobsCtx, cancelJobs = context.WithCancel(context.Background())
+	for i := 0; i < cfg.JobWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for j := range jobQueue {
+				// Once stopped, queued jobs are failed rather than started
+				if jobsStopped() {
+					setJobStatus(j.ID, jobFailed, nil, errShuttingDown)
+					continue
+				}
+				runJob(j)
+			}
+		}()
+	}
+	return nil
+}
+
+// jobsStopped reports whether stopJobWorkers has been called
+func jobsStopped() bool {
+	queueMu.RLock()
+	defer queueMu.RUnlock()
+	return queueStopped
+}
+
+// stopJobWorkers stops accepting jobs, fails those still queued and waits
+// for running ones to finish. Once ctx is done the running jobs are
+// cancelled and given a few more seconds to return.
+func stopJobWorkers(ctx context.Context) {
+	queueMu.Lock()
+	queueStopped = true
+	close(jobQueue)
+	queueMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+		slog.Warn("shutdown timed out, cancelling running jobs")
+		cancelJobs()
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		slog.Error("running jobs did not stop after cancellation")
+	}
+}
+
+// enqueueJob records a queued job for org and hands it to the workers,
+// returning its ID. The job logs with ctx's request ID. errQueueFull is
+// returned without recording anything when the queue has no room.
+func enqueueJob(ctx context.Context, kind, org, repoID string, run func(ctx context.Context) (interface{}, error)) (string, error) {
+	queueMu.RLock()
+	defer queueMu.RUnlock()
+	if queueStopped {
+		return "", errShuttingDown
+	}
+	if len(jobQueue) == cap(jobQueue) {
+		return "", errQueueFull
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+	_, err = db.Exec(
+		"INSERT INTO jobs (id, kind, org_id, repo_id, status, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, NOW(), NOW())",
+		id, kind, org, repoID, jobQueued,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to save job: %w", err)
+	}
+
+	select {
+	case jobQueue <- job{ID: id, RequestID: logging.RequestID(ctx), Run: run}:
+		return id, nil
+	default:
+		setJobStatus(id, jobFailed, nil, errQueueFull)
+		return "", errQueueFull
+	}
+}
+
+// runJob runs a job and stores its outcome
+func runJob(j job) {
+	setJobStatus(j.ID, jobRunning, nil, nil)
+	ctx := jobsCtx
+	if j.RequestID != "" {
+		ctx = logging.WithRequestID(ctx, j.RequestID)
+	}
+	log := logging.FromContext(ctx).With("job_id", j.ID)
+	log.Info("job started")
+	start := time.Now()
+
+	result, err := func() (result interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		return j.Run(ctx)
+	}()
+
+	if err != nil {
+		log.Error("job failed", "error", err, "duration_ms", time.Since(start).Milliseconds())
+		setJobStatus(j.ID, jobFailed, nil, err)
+		return
+	}
+	log.Info("job done", "duration_ms", time.Since(start).Milliseconds())
+	setJobStatus(j.ID, jobDone, result, nil)
+}
+
+// setJobStatus updates a job's status along with its result or error
+func setJobStatus(id, status string, result interface{}, jobErr error) {
+	var data []byte
+	if result != nil {
+		var err error
+		if data, err = json.Marshal(result); err != nil {
+			status, jobErr = jobFailed, err
+		}
+	}
+	var message string
+	if jobErr != nil {
+		message = jobErr.Error()
+	}
+
+	_, err := db.Exec(
+		"UPDATE jobs SET status = $2, result = $3, error = NULLIF($4, ''), updated_at = NOW() WHERE id = $1",
+		id, status, data, message,
+	)
+	if err != nil {
+		slog.Error("failed to update job", "job_id", id, "error", err)
+	}
+}
+
+// loadJob returns a job belonging to org, or nil if there is none
+func loadJob(id, org string) (*Job, error) {
+	var j Job
+	var result []byte
+	var jobErr sql.NullString
+	err := db.QueryRow(
+		"SELECT id, kind, repo_id, status, result, error, created_at, updated_at FROM jobs WHERE id = $1 AND org_id = $2",
+		id, org,
+	).Scan(&j.ID, &j.Kind, &j.RepoID, &j.Status, &result, &jobErr, &j.CreatedAt, &j.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	j.Result = result
+	j.Error = jobErr.String
+	return &j, nil
+}
+
+// newJobID returns a random 16-byte hex ID
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
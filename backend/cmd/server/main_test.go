@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"observability-copilot/pkg/scanner"
+)
+
+func TestRepoIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		repoURL  string
+		wantID   string
+		wantName string
+	}{
+		{name: "owner and name", repoURL: "https://github.com/alice/api", wantID: "alice__api", wantName: "api"},
+		{name: "same name, other owner", repoURL: "https://github.com/bob/api", wantID: "bob__api", wantName: "api"},
+		{name: "git suffix", repoURL: "https://github.com/alice/api.git", wantID: "alice__api", wantName: "api"},
+		{name: "trailing slash", repoURL: "https://github.com/alice/api/", wantID: "alice__api", wantName: "api"},
+		{name: "http", repoURL: "http://github.com/alice/api", wantID: "alice__api", wantName: "api"},
+		{name: "nested group", repoURL: "https://gitlab.com/acme/platform/api", wantID: "acme__platform__api", wantName: "api"},
+		{name: "bare name", repoURL: "api", wantID: "api", wantName: "api"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, name := repoIdentity(tt.repoURL)
+			if id != tt.wantID || name != tt.wantName {
+				t.Errorf("repoIdentity(%q) = %q, %q, want %q, %q", tt.repoURL, id, name, tt.wantID, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestRepoIdentityCollision(t *testing.T) {
+	alice, aliceName := repoIdentity("https://github.com/alice/api")
+	bob, bobName := repoIdentity("https://github.com/bob/api")
+	if alice == bob {
+		t.Errorf("github.com/alice/api and github.com/bob/api share ID %q", alice)
+	}
+	if aliceName != bobName {
+		t.Errorf("display names %q and %q differ, want both %q", aliceName, bobName, "api")
+	}
+}
+
+func TestCloneErrorBody(t *testing.T) {
+	const url = "https://github.com/acme/private"
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "auth required",
+			err:        scanner.ClassifyGitError(transport.ErrAuthenticationRequired, url, ""),
+			wantStatus: 403,
+			wantCode:   "auth_required",
+		},
+		{
+			name:       "auth required while listing branches",
+			err:        fmt.Errorf("listing remote branches failed: %w", scanner.ClassifyGitError(transport.ErrAuthorizationFailed, url, "")),
+			wantStatus: 403,
+			wantCode:   "auth_required",
+		},
+		{
+			name:       "missing branch",
+			err:        scanner.ClassifyGitError(plumbing.ErrReferenceNotFound, url, "release"),
+			wantStatus: 404,
+			wantCode:   "branch_not_found",
+		},
+		{
+			name:       "missing base branch",
+			err:        &scanner.CloneError{Kind: scanner.ErrBranchNotFound, URL: url, Branch: "develop", Err: errors.New("base branch \"develop\" does not exist on the remote")},
+			wantStatus: 404,
+			wantCode:   "branch_not_found",
+		},
+		{
+			name:       "missing repo",
+			err:        scanner.ClassifyGitError(transport.ErrRepositoryNotFound, url, ""),
+			wantStatus: 404,
+			wantCode:   "repo_not_found",
+		},
+		{
+			name:       "unknown failure",
+			err:        errors.New("object not found"),
+			wantStatus: 500,
+			wantCode:   "internal_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := cloneErrorBody(tt.err, tt.err.Error())
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			apiErr, ok := body["error"].(apiError)
+			if !ok {
+				t.Fatalf("body = %#v, want an apiError", body)
+			}
+			if apiErr.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", apiErr.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestNormalizeFramework(t *testing.T) {
+	tests := []struct {
+		framework string
+		want      string
+	}{
+		{"Go", "Go"},
+		{"go", "Go"},
+		{"golang", "Go"},
+		{"Python", "Python"},
+		{"python", "Python"},
+		{"Java", "Java"},
+		{"JAVA", "Java"},
+		{"Node.js", "Node.js"},
+		{"nodejs", "Node.js"},
+		{"node", "Node.js"},
+		{".NET", ".NET"},
+		{".net", ".NET"},
+		{"dotnet", ".NET"},
+		{"csharp", ".NET"},
+		{"C#", ".NET"},
+		{"asp.net", ".NET"},
+		{"ASP.NET Core", ".NET"},
+		{"aspnetcore", ".NET"},
+		{"Rust", "Rust"},
+		{"rust", "Rust"},
+		{"actix", "Rust"},
+		{"actix-web", "Rust"},
+		{"Axum", "Rust"},
+		{"rocket", "Rust"},
+		{"cargo", "Rust"},
+		{"Ruby", "Ruby"},
+		{"ruby", "Ruby"},
+		{" dotnet ", ".NET"},
+		{"Elixir", "Elixir"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.framework, func(t *testing.T) {
+			if got := normalizeFramework(tt.framework); got != tt.want {
+				t.Errorf("normalizeFramework(%q) = %q, want %q", tt.framework, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeFrameworkDetectedLanguages(t *testing.T) {
+	// Every language the scanner detects is already canonical
+	for _, language := range scanner.SupportedLanguages {
+		if got := normalizeFramework(language); got != language {
+			t.Errorf("normalizeFramework(%q) = %q, want it unchanged", language, got)
+		}
+	}
+}
+
+func TestParseFrameworkOverride(t *testing.T) {
+	tests := []struct {
+		override     string
+		wantLanguage string
+		wantWeb      string
+		wantErr      bool
+	}{
+		{override: "dotnet", wantLanguage: ".NET"},
+		{override: "C#", wantLanguage: ".NET"},
+		{override: "cargo", wantLanguage: "Rust"},
+		{override: "Axum", wantLanguage: "Rust", wantWeb: "Axum"},
+		{override: "fastapi", wantLanguage: "Python", wantWeb: "FastAPI"},
+		{override: "golang", wantLanguage: "Go"},
+		{override: "Elixir", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.override, func(t *testing.T) {
+			language, web, err := parseFrameworkOverride(tt.override)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFrameworkOverride(%q) error = %v, want error %v", tt.override, err, tt.wantErr)
+			}
+			if language != tt.wantLanguage || web != tt.wantWeb {
+				t.Errorf("parseFrameworkOverride(%q) = %q, %q, want %q, %q", tt.override, language, web, tt.wantLanguage, tt.wantWeb)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"observability-copilot/pkg/github"
+)
+
+// The server's own metrics, served on /metrics when METRICS_ENABLED is set.
+// They are recorded either way; the flag only decides whether they are
+// exposed.
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "copilot",
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by route and status.",
+	}, []string{"method", "endpoint", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "copilot",
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "endpoint"})
+
+	scansStarted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "copilot",
+		Name:      "scans_started_total",
+		Help:      "Repository scans started.",
+	})
+
+	scansFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "copilot",
+		Name:      "scans_failed_total",
+		Help:      "Repository scans that failed, including clone errors.",
+	})
+
+	scanDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "copilot",
+		Name:      "scan_duration_seconds",
+		Help:      "Time to clone and scan a repository.",
+		Buckets:   []float64{1, 2.5, 5, 10, 30, 60, 120, 300},
+	})
+
+	prsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "copilot",
+		Name:      "prs_created_total",
+		Help:      "Instrumentation pull requests opened or updated.",
+	})
+
+	prsFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "copilot",
+		Name:      "prs_failed_total",
+		Help:      "Instrumentation pull requests that could not be created.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		scansStarted, scansFailed, scanDuration,
+		prsCreated, prsFailed,
+	)
+}
+
+// MetricsMiddleware counts and times each request by its route template,
+// e.g. "/api/v1/repos/:repo_id/plan", so IDs don't explode the label set
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		endpoint := c.FullPath()
+		if endpoint == "" {
+			endpoint = "unmatched"
+		}
+		httpRequests.WithLabelValues(c.Request.Method, endpoint, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, endpoint).Observe(time.Since(start).Seconds())
+	}
+}
+
+// metricsHandler serves the registry in the Prometheus text format
+func metricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+}
+
+// startScan counts a scan as started and returns its start time for
+// observeScan
+func startScan() time.Time {
+	scansStarted.Inc()
+	return time.Now()
+}
+
+// observeScan records a scan started at start that finished with err
+func observeScan(start time.Time, err error) {
+	scanDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		scansFailed.Inc()
+	}
+}
+
+// observePR records the outcome of creating an instrumentation PR. An
+// already open PR counts as neither created nor failed, and one missing
+// some reviewers or labels as created.
+func observePR(err error) {
+	var followUp *github.FollowUpError
+	switch {
+	case err == nil, errors.As(err, &followUp):
+		prsCreated.Inc()
+	case !errors.Is(err, github.ErrPRExists):
+		prsFailed.Inc()
+	}
+}
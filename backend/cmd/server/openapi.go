@@ -0,0 +1,21 @@
+package main
+
+import (
+	_ "embed"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec is the OpenAPI 3 description of the routes registered in
+// main. It is maintained by hand: routes, request bodies and responses
+// changed there are updated in openapi.json too.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// openAPIHandler serves openAPISpec
+func openAPIHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(200, "application/json; charset=utf-8", openAPISpec)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"observability-copilot/pkg/generator"
+	"observability-copilot/pkg/logging"
+	"observability-copilot/pkg/scanner"
+	"observability-copilot/pkg/togglespec"
+)
+
+// PullRequest is a recorded instrumentation PR
+type PullRequest struct {
+	Service   string    `json:"service"`
+	Mode      string    `json:"mode"`
+	Branch    string    `json:"branch"`
+	PRURL     string    `json:"pr_url"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// savePR records a PR opened for a repo's service. A PR already recorded
+// under the same URL is kept as is.
+func savePR(repoID string, pr PullRequest) error {
+	_, err := db.Exec(`
+		INSERT INTO pull_requests (repo_id, service, mode, branch, pr_url, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (pr_url) DO NOTHING
+	`, repoID, pr.Service, pr.Mode, pr.Branch, pr.PRURL, pr.Status)
+	if err != nil {
+		return fmt.Errorf("failed to save pull request: %w", err)
+	}
+	return nil
+}
+
+// listPRs returns the PRs recorded for a repo, newest first
+func listPRs(repoID string) ([]PullRequest, error) {
+	rows, err := db.Query(`
+		SELECT service, mode, branch, pr_url, status, created_at
+		FROM pull_requests
+		WHERE repo_id = $1
+		ORDER BY created_at DESC
+	`, repoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prs := []PullRequest{}
+	for rows.Next() {
+		var pr PullRequest
+		if err := rows.Scan(&pr.Service, &pr.Mode, &pr.Branch, &pr.PRURL, &pr.Status, &pr.CreatedAt); err != nil {
+			return nil, err
+		}
+		prs = append(prs, pr)
+	}
+	return prs, rows.Err()
+}
+
+// unsafeFileNameChars are replaced in generated file names
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// patchFileName names the patch of a service's changes, e.g.
+// "flask-app-both.patch"
+func patchFileName(service, mode string) string {
+	return unsafeFileNameChars.ReplaceAllString(service+"-"+mode, "-") + ".patch"
+}
+
+// preparedPR is the plan a create-pr request generated, and the repo and
+// service it is for
+type preparedPR struct {
+	Plan       *generator.InstrumentationPlan
+	RepoURL    string
+	Service    string
+	HasMetrics bool
+	HasOtel    bool
+}
+
+// preparePR generates the plan a create-pr request asks for, filling in
+// req's defaults from the repo and the environment's ToggleSpec. When it
+// can't, the error is answered and false returned.
+func preparePR(c *gin.Context, repoID string, req *createPRRequest) (*preparedPR, bool) {
+	// Get repo info
+	var githubURL string
+	var otlpEndpoint, subpath sql.NullString
+	var prometheusConfig bool
+	err := db.QueryRow("SELECT github_url, otlp_endpoint, subpath, prometheus_config FROM repos WHERE id = $1", repoID).Scan(&githubURL, &otlpEndpoint, &subpath, &prometheusConfig)
+	if err != nil {
+		respondError(c, 404, "Repo not found")
+		return nil, false
+	}
+	if githubURL == "" {
+		respondError(c, 409, errNoRemote.Error())
+		return nil, false
+	}
+	if req.IncludePrometheusConfig != nil {
+		prometheusConfig = *req.IncludePrometheusConfig
+	}
+	if req.OTLPEndpoint == "" {
+		req.OTLPEndpoint = endpointOrDefault(otlpEndpoint.String)
+	}
+	if req.Subpath == "" {
+		req.Subpath = subpath.String
+	}
+
+	// Get service info (framework, existing instrumentation)
+	var framework, serviceName string
+	var hasMetrics, hasOtel bool
+	err = db.QueryRow(`
+		SELECT framework, name, has_metrics, has_otel 
+		FROM services 
+		WHERE repo_id = $1 AND ($2 = '' OR name = $2)
+		ORDER BY created_at
+		LIMIT 1
+	`, repoID, req.Service).Scan(&framework, &serviceName, &hasMetrics, &hasOtel)
+
+	if err == sql.ErrNoRows {
+		respondError(c, 404, "Service not found")
+		return nil, false
+	} else if err != nil {
+		respondError(c, 500, "Failed to get service info")
+		return nil, false
+	}
+
+	// Fall back to the mode and sampling rate of the environment's toggle spec
+	var spec, envMode string
+	err = db.QueryRow(`
+		SELECT t.spec, t.telemetry_mode FROM togglespecs t
+		JOIN services s ON s.id = t.service_id
+		WHERE s.repo_id = $1 AND s.name = $2 AND ($3 = '' OR t.environment = $3)
+		ORDER BY t.updated_at DESC
+		LIMIT 1
+	`, repoID, serviceName, req.Environment).Scan(&spec, &envMode)
+	if err == sql.ErrNoRows && req.Environment != "" {
+		respondError(c, 404, fmt.Sprintf("ToggleSpec not found for environment %s", req.Environment))
+		return nil, false
+	}
+	if err == nil {
+		if req.TelemetryMode == "" {
+			req.TelemetryMode = envMode
+		}
+		if req.SamplingRate == nil {
+			req.SamplingRate = specSamplingRate(spec)
+		}
+	}
+	if req.SamplingRate != nil {
+		if err := togglespec.ValidateSamplingRate(*req.SamplingRate); err != nil {
+			respondError(c, 400, err.Error())
+			return nil, false
+		}
+	}
+	// Refuse modes the language has no generator for before cloning
+	language := normalizeFramework(framework)
+	if req.FrameworkOverride != "" {
+		language, _, _ = parseFrameworkOverride(req.FrameworkOverride)
+	}
+	modeErrors := fieldErrors{}
+	if req.TelemetryMode == "" {
+		modeErrors.add("telemetry_mode", "is required when the environment has no ToggleSpec")
+	} else {
+		modeErrors.check("telemetry_mode", generator.CheckMode(language, req.TelemetryMode))
+	}
+	if modeErrors.respond(c) {
+		return nil, false
+	}
+
+	// Only add what's missing
+	modeToAdd, err := pendingTelemetryMode(req.TelemetryMode, hasMetrics, hasOtel)
+	if err != nil {
+		respondError(c, 400, err.Error())
+		return nil, false
+	}
+
+	// Locate the files the generator should anchor on
+	result, err := getScan(c.Request.Context(), repoID, githubURL, req.BaseBranch, req.Subpath, c.Query("rescan") == "true")
+	if err != nil {
+		respondCloneError(c, err, err.Error())
+		return nil, false
+	}
+
+	// The repo's default doesn't add a scrape job next to one it already ships
+	if req.IncludePrometheusConfig == nil && result.HasInfra(scanner.InfraPrometheus) {
+		prometheusConfig = false
+	}
+
+	// Generate instrumentation plan
+	var detection scanner.FrameworkDetection
+	language, detection, err = overrideFramework(framework, findDetection(result, serviceName), req.FrameworkOverride)
+	if err != nil {
+		respondError(c, 400, err.Error())
+		return nil, false
+	}
+	plan, err := generator.Generate(language, serviceName, modeToAdd, detection.Candidates, generator.Options{
+		OTLPEndpoint: req.OTLPEndpoint,
+		Dir:          detection.Path,
+		SamplingRate: req.SamplingRate,
+		WebFramework: detection.Framework,
+		ModulePath:   detection.ModulePath,
+
+		CollectorConfig:   req.IncludeCollectorConfig,
+		CollectorExporter: req.CollectorExporter,
+		PrometheusConfig:  prometheusConfig,
+		MetricsPort:       req.MetricsPort,
+		AppPort:           detection.Port,
+		GrafanaDashboard:  req.IncludeGrafanaDashboard,
+		DatabaseTracing:   req.IncludeDatabaseTracing == nil || *req.IncludeDatabaseTracing,
+		MetricsNamespace:  req.MetricsNamespace,
+		MetricsSubsystem:  req.MetricsSubsystem,
+
+		Logger: logging.FromContext(c.Request.Context()),
+	})
+	if err != nil {
+		respondError(c, 500, err.Error())
+		return nil, false
+	}
+	if len(plan.Changes) == 0 {
+		respondError(c, 400, plan.Description)
+		return nil, false
+	}
+
+	return &preparedPR{
+		Plan:       plan,
+		RepoURL:    githubURL,
+		Service:    serviceName,
+		HasMetrics: hasMetrics,
+		HasOtel:    hasOtel,
+	}, true
+}
+
+// pendingTelemetryMode reduces mode to the signals a service with the
+// given instrumentation is missing, so "all" on a service with metrics
+// adds "traces+logs". It errors when nothing is left to add or remove.
+// Logs are always kept, as the scan can't tell whether they are
+// correlated already.
+func pendingTelemetryMode(mode string, hasMetrics, hasOtel bool) (string, error) {
+	if mode == "none" {
+		if !hasMetrics && !hasOtel {
+			return "", errors.New("No instrumentation to remove")
+		}
+		return mode, nil
+	}
+	metrics, traces, logs, ok := togglespec.ModeSignals(mode)
+	if !ok || (!metrics && !traces) {
+		return mode, nil
+	}
+
+	addMetrics, addTraces := metrics && !hasMetrics, traces && !hasOtel
+	if !addMetrics && !addTraces && !logs {
+		switch {
+		case metrics && traces:
+			return "", errors.New("Already has both metrics and traces")
+		case metrics:
+			return "", errors.New("Already has metrics")
+		default:
+			return "", errors.New("Already has traces")
+		}
+	}
+	return togglespec.ModeFor(addMetrics, addTraces, logs), nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestPendingTelemetryMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		hasMetrics bool
+		hasOtel    bool
+		want       string
+		wantErr    string
+	}{
+		{name: "both", mode: "both", want: "both"},
+		{name: "both with metrics", mode: "both", hasMetrics: true, want: "traces"},
+		{name: "both with traces", mode: "both", hasOtel: true, want: "metrics"},
+		{name: "both with both", mode: "both", hasMetrics: true, hasOtel: true, wantErr: "Already has both metrics and traces"},
+		{name: "metrics with metrics", mode: "metrics", hasMetrics: true, wantErr: "Already has metrics"},
+		{name: "traces with traces", mode: "traces", hasOtel: true, wantErr: "Already has traces"},
+		{name: "metrics with traces", mode: "metrics", hasOtel: true, want: "metrics"},
+		{name: "logs", mode: "logs", hasMetrics: true, hasOtel: true, want: "logs"},
+		{name: "all", mode: "all", want: "all"},
+		{name: "all with metrics", mode: "all", hasMetrics: true, want: "traces+logs"},
+		{name: "all with traces", mode: "all", hasOtel: true, want: "metrics+logs"},
+		{name: "all with both", mode: "all", hasMetrics: true, hasOtel: true, want: "logs"},
+		{name: "metrics+logs", mode: "metrics+logs", hasOtel: true, want: "metrics+logs"},
+		{name: "metrics+logs with metrics", mode: "metrics+logs", hasMetrics: true, want: "logs"},
+		{name: "traces+logs with traces", mode: "traces+logs", hasOtel: true, want: "logs"},
+		{name: "none", mode: "none", hasMetrics: true, want: "none"},
+		{name: "none without instrumentation", mode: "none", wantErr: "No instrumentation to remove"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pendingTelemetryMode(tt.mode, tt.hasMetrics, tt.hasOtel)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("pendingTelemetryMode(%q, %t, %t) error = %v, want %q", tt.mode, tt.hasMetrics, tt.hasOtel, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pendingTelemetryMode(%q, %t, %t) error = %v", tt.mode, tt.hasMetrics, tt.hasOtel, err)
+			}
+			if got != tt.want {
+				t.Errorf("pendingTelemetryMode(%q, %t, %t) = %q, want %q", tt.mode, tt.hasMetrics, tt.hasOtel, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterIdle is how long a caller's bucket is kept after its last
+// request
+const rateLimiterIdle = 10 * time.Minute
+
+// rateLimiter is a token bucket per caller, refilled at perMinute tokens a
+// minute and holding up to perMinute of them
+type rateLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	buckets   map[string]*bucket
+	swept     time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// reserve takes a token from key's bucket, returning how long to wait
+// before retrying when it is empty
+func (l *rateLimiter) reserve(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.swept) > rateLimiterIdle {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) > rateLimiterIdle {
+				delete(l.buckets, k)
+			}
+		}
+		l.swept = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(float64(l.perMinute)/60), l.perMinute)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	r := b.limiter.ReserveN(now, 1)
+	if wait := r.DelayFrom(now); wait > 0 {
+		r.CancelAt(now)
+		return wait
+	}
+	return 0
+}
+
+// RateLimitMiddleware allows each caller perMinute requests a minute
+// through the routes it is used on, answering the rest with 429 and a
+// Retry-After. Callers are told apart by API key, or by client IP when no
+// API keys are configured, so it must run after OrgMiddleware. A
+// perMinute of 0 allows every request.
+func RateLimitMiddleware(perMinute int) gin.HandlerFunc {
+	if perMinute <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	limiter := &rateLimiter{perMinute: perMinute, buckets: map[string]*bucket{}}
+
+	return func(c *gin.Context) {
+		key := "ip:" + c.ClientIP()
+		if len(cfg.APIKeys) > 0 {
+			key = "key:" + apiKey(c)
+		}
+
+		wait := limiter.reserve(key)
+		if wait == 0 {
+			c.Next()
+			return
+		}
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", fmt.Sprint(retryAfter))
+		c.AbortWithStatusJSON(429, gin.H{
+			"error":       apiError{Code: errorCode(429), Message: fmt.Sprintf("Too many requests, retry in %ds", retryAfter)},
+			"retry_after": retryAfter,
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"observability-copilot/pkg/config"
+)
+
+func TestRateLimitClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := cfg
+	t.Cleanup(func() { cfg = previous })
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		// forwardedFor is sent as X-Forwarded-For on each request in turn
+		forwardedFor []string
+		want         []int
+	}{
+		{
+			name:         "spoofed header without trusted proxies",
+			remoteAddr:   "203.0.113.7:4000",
+			forwardedFor: []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"},
+			want:         []int{200, 200, 429},
+		},
+		{
+			name:           "spoofed header from an untrusted peer",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "203.0.113.7:4000",
+			forwardedFor:   []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"},
+			want:           []int{200, 200, 429},
+		},
+		{
+			name:           "clients behind a trusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.1.2.3:4000",
+			forwardedFor:   []string{"198.51.100.1", "198.51.100.1", "198.51.100.2", "198.51.100.1"},
+			want:           []int{200, 200, 200, 429},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg = &config.Config{TrustedProxies: tt.trustedProxies}
+			router := gin.New()
+			if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+				t.Fatal(err)
+			}
+			router.Use(RateLimitMiddleware(2))
+			router.GET("/", func(c *gin.Context) { c.Status(200) })
+
+			for i, forwardedFor := range tt.forwardedFor {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = tt.remoteAddr
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				if w.Code != tt.want[i] {
+					t.Errorf("request %d from %s = %d, want %d", i+1, forwardedFor, w.Code, tt.want[i])
+				}
+			}
+		})
+	}
+}
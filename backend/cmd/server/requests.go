@@ -0,0 +1,166 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"observability-copilot/pkg/generator"
+	"observability-copilot/pkg/github"
+	"observability-copilot/pkg/scanner"
+	"observability-copilot/pkg/togglespec"
+)
+
+// importRequest is the body of POST /api/v1/imports
+type importRequest struct {
+	GitHubURL     string `json:"github_url"`
+	TelemetryMode string `json:"telemetry_mode"`
+	OTLPEndpoint  string `json:"otlp_endpoint"`
+	Subpath       string `json:"subpath"`
+	// Format is the default spec format of the environments
+	Format string `json:"format"`
+
+	// Environments seeds a ToggleSpec per environment, e.g.
+	// {"dev": {...}, "staging": {...}, "prod": {...}}.
+	// Without it only "dev" is seeded with telemetry_mode.
+	Environments map[string]environmentToggle `json:"environments"`
+
+	// IncludePrometheusConfig makes PRs for this repo add a
+	// Prometheus scrape job by default
+	IncludePrometheusConfig bool `json:"include_prometheus_config"`
+}
+
+// validate normalizes the URL to its https form, which the token
+// authenticates, and fills in the environments' toggles
+func (req *importRequest) validate() fieldErrors {
+	v := fieldErrors{}
+	req.GitHubURL = scanner.NormalizeRepoURL(req.GitHubURL)
+	if req.GitHubURL == "" {
+		v.add("github_url", "is required")
+	}
+	environments, problems := importEnvironments(req.Environments, req.TelemetryMode, req.Format)
+	req.Environments = environments
+	return append(v, problems...)
+}
+
+// createPRRequest is the body of POST /api/v1/repos/:repo_id/create-pr
+type createPRRequest struct {
+	TelemetryMode string `json:"telemetry_mode"`
+	OTLPEndpoint  string `json:"otlp_endpoint"`
+	Service       string `json:"service"`
+	// FrameworkOverride generates for this language or web framework,
+	// e.g. "FastAPI", rather than the detected one
+	FrameworkOverride string   `json:"framework_override"`
+	Subpath           string   `json:"subpath"`
+	DryRun            bool     `json:"dry_run"`
+	BaseBranch        string   `json:"base_branch"`
+	SamplingRate      *float64 `json:"sampling_rate"`
+	Environment       string   `json:"environment"`
+	Force             bool     `json:"force"`
+	// Draft opens the PR as a draft, for repos with required reviews
+	Draft bool `json:"draft"`
+	// Reviewers ("login" or "org/team") and Labels are added to a new PR
+	Reviewers []string `json:"reviewers"`
+	Labels    []string `json:"labels"`
+	// TitleTemplate and BodyTemplate are Go text/templates executed
+	// with github.PRTemplateData, e.g. to link a ticket
+	TitleTemplate string `json:"title_template"`
+	BodyTemplate  string `json:"body_template"`
+	// CallbackURL is notified once the PR is created, like WEBHOOK_URL
+	CallbackURL string `json:"callback_url"`
+	// AuthorName and AuthorEmail attribute the commit to a specific
+	// account, e.g. a bot user CODEOWNERS and branch protection know
+	AuthorName  string `json:"author_name"`
+	AuthorEmail string `json:"author_email"`
+
+	// IncludeCollectorConfig adds an otel-collector-config.yaml for
+	// the traces, forwarding them to CollectorExporter when set
+	IncludeCollectorConfig bool   `json:"include_collector_config"`
+	CollectorExporter      string `json:"collector_exporter"`
+
+	// IncludePrometheusConfig adds a prometheus-scrape.yaml job for
+	// the metrics, defaulting to the repo's import setting
+	IncludePrometheusConfig *bool `json:"include_prometheus_config"`
+	MetricsPort             int   `json:"metrics_port"`
+
+	IncludeGrafanaDashboard bool `json:"include_grafana_dashboard"`
+
+	// IncludeDatabaseTracing traces the queries of the database client
+	// the scan found along with the requests, on unless set to false
+	IncludeDatabaseTracing *bool `json:"include_database_tracing"`
+
+	// MetricsNamespace and MetricsSubsystem prefix the generated
+	// metric names, e.g. myco_http_requests_total
+	MetricsNamespace string `json:"metrics_namespace"`
+	MetricsSubsystem string `json:"metrics_subsystem"`
+}
+
+// validate checks the fields that don't depend on the repo. The telemetry
+// mode may be left to the environment's ToggleSpec.
+func (req *createPRRequest) validate() fieldErrors {
+	v := fieldErrors{}
+	if req.TelemetryMode != "" {
+		v.check("telemetry_mode", togglespec.ValidateMode(req.TelemetryMode))
+	}
+	if req.SamplingRate != nil {
+		v.check("sampling_rate", togglespec.ValidateSamplingRate(*req.SamplingRate))
+	}
+	if req.FrameworkOverride != "" {
+		_, _, err := parseFrameworkOverride(req.FrameworkOverride)
+		v.check("framework_override", err)
+	}
+	if req.CallbackURL != "" && !validCallbackURL(req.CallbackURL) {
+		v.add("callback_url", "must be an http or https URL to a public host")
+	}
+	v.check("author_name", github.ValidateAuthor(req.AuthorName, ""))
+	v.check("author_email", github.ValidateAuthor("", req.AuthorEmail))
+	if req.MetricsPort < 0 || req.MetricsPort > 65535 {
+		v.add("metrics_port", "must be a port up to 65535, or 0 for the default, got %d", req.MetricsPort)
+	}
+	v.check("metrics_namespace", generator.ValidateMetricsNamespace(req.MetricsNamespace, ""))
+	v.check("metrics_subsystem", generator.ValidateMetricsNamespace("", req.MetricsSubsystem))
+	return v
+}
+
+// toggleRequest is the body of PUT
+// /api/v1/repos/:repo_id/services/:svc/toggles/:env: a raw spec, or the
+// telemetry mode and sampling rate to generate one from
+type toggleRequest struct {
+	TelemetryMode string   `json:"telemetry_mode"`
+	Spec          string   `json:"spec"`
+	SamplingRate  *float64 `json:"sampling_rate"`
+	// Format is "yaml" (default) or "json"
+	Format string `json:"format"`
+}
+
+// validate checks the request, returning the raw spec parsed when one was
+// given
+func (req *toggleRequest) validate() (togglespec.ToggleSpec, fieldErrors) {
+	v := fieldErrors{}
+	v.check("format", togglespec.ValidateFormat(req.Format))
+	if req.Spec != "" {
+		parsed, err := togglespec.ParseToggleSpec(req.Spec)
+		v.check("spec", err)
+		return parsed, v
+	}
+
+	if req.TelemetryMode == "" {
+		v.add("telemetry_mode", "is required without a spec")
+	} else {
+		v.check("telemetry_mode", togglespec.ValidateMode(req.TelemetryMode))
+	}
+	if req.SamplingRate != nil {
+		v.check("sampling_rate", togglespec.ValidateSamplingRate(*req.SamplingRate))
+	}
+	return togglespec.ToggleSpec{}, v
+}
+
+// planQueryErrors checks the query parameters instrumentation-plan and diff
+// take in place of a create-pr body
+func planQueryErrors(c *gin.Context) fieldErrors {
+	v := fieldErrors{}
+	v.check("metrics_namespace", generator.ValidateMetricsNamespace(c.Query("metrics_namespace"), ""))
+	v.check("metrics_subsystem", generator.ValidateMetricsNamespace("", c.Query("metrics_subsystem")))
+	if override := c.Query("framework_override"); override != "" {
+		_, _, err := parseFrameworkOverride(override)
+		v.check("framework_override", err)
+	}
+	return v
+}
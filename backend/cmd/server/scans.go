@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"observability-copilot/pkg/scanner"
+)
+
+// defaultScanBranch keys scans of the repo's default branch
+const defaultScanBranch = "HEAD"
+
+// scanBranchKey maps an optional branch name onto the scans table key
+func scanBranchKey(branch string) string {
+	if branch == "" {
+		return defaultScanBranch
+	}
+	return branch
+}
+
+// errNoRemote is returned for git operations on a repo uploaded as an
+// archive, which has no remote to clone
+var errNoRemote = errors.New("repo was uploaded as an archive and has no remote; upload it again to rescan")
+
+// scanCacheTTL is how long a stored scan is reused before rescanning
+const scanCacheTTL = 24 * time.Hour
+
+// saveScan stores the scan result for a repo/branch/subpath, replacing any
+// previous one. A scan of the default branch also refreshes the signals recorded
+// for the repo and its services.
+func saveScan(repoID, branch, subpath string, result *scanner.ScanResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO scans (repo_id, branch, subpath, result, scanned_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (repo_id, branch, subpath) DO UPDATE SET
+			result = EXCLUDED.result,
+			scanned_at = NOW()
+	`, repoID, branch, subpath, data)
+	if err != nil {
+		return fmt.Errorf("failed to save scan: %w", err)
+	}
+
+	if branch != defaultScanBranch {
+		return nil
+	}
+	_, err = db.Exec("UPDATE repos SET unsupported = $2, updated_at = NOW() WHERE id = $1", repoID, result.Unsupported)
+	if err != nil {
+		return fmt.Errorf("failed to update repo: %w", err)
+	}
+	for _, detection := range result.Detections {
+		_, err := db.Exec(
+			"UPDATE services SET has_metrics = $2, has_otel = $3, updated_at = NOW() WHERE id = $1",
+			fmt.Sprintf("%s-%s", repoID, detection.ServiceName), detection.HasMetrics, detection.HasOTel,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update services: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadScan returns the stored scan for a repo/branch/subpath, or nil if
+// there is none or it is older than maxAge. A zero maxAge accepts scans of
+// any age.
+func loadScan(repoID, branch, subpath string, maxAge time.Duration) (*scanner.ScanResult, error) {
+	var data []byte
+	var scannedAt time.Time
+	err := db.QueryRow(
+		"SELECT result, scanned_at FROM scans WHERE repo_id = $1 AND branch = $2 AND subpath = $3",
+		repoID, branch, subpath,
+	).Scan(&data, &scannedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if maxAge > 0 && time.Since(scannedAt) > maxAge {
+		return nil, nil
+	}
+
+	var result scanner.ScanResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// getScan returns the cached scan unless rescan is set or the cache is stale,
+// in which case the repo is scanned again and the result stored.
+func getScan(ctx context.Context, repoID, githubURL, branch, subpath string, rescan bool) (*scanner.ScanResult, error) {
+	// An uploaded repo keeps the scan taken on import
+	if githubURL == "" {
+		stored, err := loadScan(repoID, scanBranchKey(branch), subpath, 0)
+		if err != nil {
+			return nil, err
+		}
+		if stored == nil || rescan {
+			return nil, errNoRemote
+		}
+		return stored, nil
+	}
+
+	if !rescan {
+		cached, err := loadScan(repoID, scanBranchKey(branch), subpath, scanCacheTTL)
+		if err != nil {
+			return nil, err
+		}
+		if cached != nil {
+			return cached, nil
+		}
+	}
+
+	start := startScan()
+	result, err := scanner.ScanRepo(ctx, githubURL, repoID, branch, subpath)
+	observeScan(start, err)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveScan(repoID, scanBranchKey(branch), subpath, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
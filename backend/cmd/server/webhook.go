@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"observability-copilot/pkg/logging"
+)
+
+// Webhook request headers. The signature is "sha256=" and the hex HMAC of
+// the body keyed with WEBHOOK_SECRET, sent only when a secret is set.
+const (
+	webhookEventHeader     = "X-Copilot-Event"
+	webhookSignatureHeader = "X-Copilot-Signature-256"
+)
+
+// prCreatedEvent is posted to webhooks when create-pr opens a pull request
+type prCreatedEvent struct {
+	Event     string    `json:"event"`
+	RepoID    string    `json:"repo_id"`
+	RepoURL   string    `json:"repo_url"`
+	Service   string    `json:"service"`
+	Mode      string    `json:"mode"`
+	PRURL     string    `json:"pr_url"`
+	Draft     bool      `json:"draft"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// pendingWebhooks tracks deliveries still in flight so shutdown can wait
+var pendingWebhooks sync.WaitGroup
+
+// validCallbackURL reports whether raw is an absolute http(s) URL whose
+// host isn't a loopback, private or link-local address. Hosts resolving to
+// one are caught when the delivery connects, see callbackClient.
+func validCallbackURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return false
+	}
+	if strings.EqualFold(u.Hostname(), "localhost") {
+		return false
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !publicAddr(ip) {
+		return false
+	}
+	return true
+}
+
+// publicAddr reports whether a callback may be delivered to ip: it is not
+// loopback, private, link-local (which includes cloud metadata endpoints),
+// multicast or unspecified
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// errCallbackAddress is returned for a callback whose host resolves to an
+// address publicAddr rejects
+var errCallbackAddress = errors.New("callback address is not public")
+
+// Webhook deliveries don't follow redirects, which could send them on to
+// an internal address. Callbacks, whose URLs come from API requests, may
+// only connect to public addresses; the check runs on the address dialed,
+// so a name resolving to an internal address is refused too.
+var (
+	webhookClient = &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	callbackClient = &http.Client{
+		CheckRedirect: webhookClient.CheckRedirect,
+		// No Proxy: the address dialed must be the callback host's own
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 30 * time.Second,
+				Control: func(network, address string, _ syscall.RawConn) error {
+					addrPort, err := netip.ParseAddrPort(address)
+					if err != nil || !publicAddr(addrPort.Addr()) {
+						return fmt.Errorf("%w: %s", errCallbackAddress, address)
+					}
+					return nil
+				},
+			}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+)
+
+// notifyPRCreated posts event to the configured webhook and callbackURL, if
+// any, in the background. Delivery failures are only logged: the PR stands
+// either way.
+func notifyPRCreated(ctx context.Context, callbackURL string, event prCreatedEvent) {
+	targets := []string{}
+	if cfg.WebhookURL != "" {
+		targets = append(targets, cfg.WebhookURL)
+	}
+	if callbackURL != "" && callbackURL != cfg.WebhookURL {
+		targets = append(targets, callbackURL)
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	event.Event = "pr.created"
+	event.Timestamp = time.Now().UTC()
+	body, err := json.Marshal(event)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to encode webhook payload", "error", err)
+		return
+	}
+
+	// Deliveries outlive the request but keep its ID in their logs
+	deliveryCtx := logging.WithRequestID(context.Background(), logging.RequestID(ctx))
+	for _, target := range targets {
+		pendingWebhooks.Add(1)
+		go func(target string) {
+			defer pendingWebhooks.Done()
+			client := webhookClient
+			if target != cfg.WebhookURL {
+				client = callbackClient
+			}
+			if err := postWebhook(deliveryCtx, client, target, event.Event, body); err != nil {
+				logging.FromContext(deliveryCtx).Warn("webhook delivery failed", "url", target, "error", err)
+				return
+			}
+			logging.FromContext(deliveryCtx).Info("webhook delivered", "url", target, "event", event.Event)
+		}(target)
+	}
+}
+
+// postWebhook sends one signed delivery with client, giving up after
+// WEBHOOK_TIMEOUT
+func postWebhook(ctx context.Context, client *http.Client, target, event string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, cfg.WebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, event)
+	if cfg.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(cfg.WebhookSecret))
+		mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// waitForWebhooks waits for in-flight deliveries until ctx is done
+func waitForWebhooks(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		pendingWebhooks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,356 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the server's configuration, read once from the environment at
+// startup and handed to the packages that need it
+type Config struct {
+	// DatabaseURL is the Postgres connection string (DATABASE_URL, required)
+	DatabaseURL string
+	// Port is the HTTP listen port (PORT, default 8000)
+	Port string
+	// ShutdownTimeout is how long in-flight requests and jobs may run after
+	// SIGTERM before they are cancelled (SHUTDOWN_TIMEOUT, default 25s, within
+	// Kubernetes' default 30s grace period)
+	ShutdownTimeout time.Duration
+
+	// GitHubToken authenticates clones, pushes and API calls (GITHUB_TOKEN)
+	GitHubToken string
+	// GitHubHost is the GitHub Enterprise Server host (GITHUB_HOST, default
+	// github.com)
+	GitHubHost string
+	// GitHubAPIURL overrides the REST API root (GITHUB_API_URL)
+	GitHubAPIURL string
+	// GitLabToken and GitLabHost do the same for GitLab (GITLAB_TOKEN,
+	// GITLAB_HOST, default gitlab.com)
+	GitLabToken string
+	GitLabHost  string
+	// APIMaxAttempts is how many times a rate-limited API call is tried
+	// (API_MAX_ATTEMPTS, default 4)
+	APIMaxAttempts int
+
+	// SignCommits signs instrumentation commits with a GPG key
+	// (GIT_SIGN_COMMITS). The ASCII-armored private key is read from
+	// GitSigningKeyFile (GIT_SIGNING_KEY_FILE), picked by GitSigningKeyID
+	// (GIT_SIGNING_KEY_ID) when the file holds several, and unlocked with
+	// GitSigningPassphrase (GIT_SIGNING_PASSPHRASE).
+	SignCommits          bool
+	GitSigningKeyFile    string
+	GitSigningKeyID      string
+	GitSigningPassphrase string
+
+	// GitAuthorName and GitAuthorEmail attribute instrumentation commits to
+	// an account of the org's (GIT_AUTHOR_NAME, GIT_AUTHOR_EMAIL), instead
+	// of the built-in bot identity
+	GitAuthorName  string
+	GitAuthorEmail string
+
+	// FormatCode runs black and isort, prettier or google-java-format,
+	// when installed, over the Python, Node.js or Java files a PR creates
+	// (FORMAT_CODE)
+	FormatCode bool
+
+	// CompileJava compiles the Maven projects a PR changes with mvn before
+	// committing. Maven runs the project's own build plugins, so this is off
+	// unless the repos are trusted (COMPILE_JAVA).
+	CompileJava bool
+
+	// OTLPEndpoint is the collector generated code exports to when a repo
+	// doesn't set its own (OTLP_ENDPOINT). Empty uses the generator default.
+	OTLPEndpoint string
+
+	// CloneTimeout bounds clones and remote calls (CLONE_TIMEOUT, default 2m)
+	CloneTimeout time.Duration
+	// MaxCloneBytes caps a checkout's size on disk, 0 for no limit
+	// (MAX_CLONE_BYTES)
+	MaxCloneBytes int64
+	// MaxUploadBytes caps the archives accepted by imports/upload
+	// (MAX_UPLOAD_BYTES, default 100 MiB). Their extracted files are
+	// capped by MaxCloneBytes.
+	MaxUploadBytes int64
+	// CloneCacheSize is how many scan checkouts are kept and reused while
+	// their branch's commit is unchanged (CLONE_CACHE_SIZE, default 20, 0
+	// disables); unused ones are removed after CloneCacheTTL
+	// (CLONE_CACHE_TTL, default 1h)
+	CloneCacheSize int
+	CloneCacheTTL  time.Duration
+	// DefaultBranchTTL is how long a repo's default branch, the base of
+	// PRs that don't name one, is remembered (DEFAULT_BRANCH_TTL, default
+	// 1h)
+	DefaultBranchTTL time.Duration
+	// ScanIncludeTestFiles searches test and generated files too
+	// (SCAN_INCLUDE_TEST_FILES). Otherwise files matching
+	// ScanExcludePatterns (SCAN_EXCLUDE_PATTERNS, comma-separated file name
+	// globs, default scanner.DefaultExcludePatterns) are skipped.
+	ScanIncludeTestFiles bool
+	ScanExcludePatterns  []string
+
+	// JobWorkers and JobQueueSize size the background job pool
+	// (JOB_WORKERS, default 4; JOB_QUEUE_SIZE, default 100)
+	JobWorkers   int
+	JobQueueSize int
+
+	// APIKeys maps API keys to their org (API_KEYS, "key:org,..."). Empty
+	// makes the instance single-tenant.
+	APIKeys map[string]string
+
+	// RateLimit is how many API requests a minute each API key, or client
+	// IP without API keys, may make (RATE_LIMIT, default 300, 0 disables).
+	// create-pr and imports, which spend the code host token, are limited
+	// further by CreatePRRateLimit (RATE_LIMIT_CREATE_PR, default 10) and
+	// ImportRateLimit (RATE_LIMIT_IMPORTS, default 20).
+	RateLimit         int
+	CreatePRRateLimit int
+	ImportRateLimit   int
+	// TrustedProxies are the proxy IPs or CIDRs whose X-Forwarded-For the
+	// client IP is taken from (TRUSTED_PROXIES, comma-separated). Empty
+	// trusts none, so the client IP is the connection's peer address.
+	TrustedProxies []string
+
+	// CORSAllowedOrigins are the origins allowed with credentials
+	// (CORS_ALLOWED_ORIGINS, comma-separated). Empty allows every origin.
+	CORSAllowedOrigins []string
+	// CORSMaxAge is how long preflights are cached, in seconds
+	// (CORS_MAX_AGE, default 600)
+	CORSMaxAge int
+
+	// WebhookURL receives a signed POST whenever create-pr opens a pull
+	// request (WEBHOOK_URL), in addition to a request's callback_url. The
+	// body is signed with WebhookSecret (WEBHOOK_SECRET) and each delivery
+	// gives up after WebhookTimeout (WEBHOOK_TIMEOUT, default 5s).
+	WebhookURL     string
+	WebhookSecret  string
+	WebhookTimeout time.Duration
+
+	// MetricsEnabled serves the server's own Prometheus metrics on /metrics
+	// (METRICS_ENABLED)
+	MetricsEnabled bool
+
+	// LogFormat is "json" or "text" (LOG_FORMAT, default json)
+	LogFormat string
+	// LogLevel is the minimum level logged (LOG_LEVEL, default info)
+	LogLevel string
+	// LogEmoji keeps the emoji on startup and summary lines (LOG_EMOJI)
+	LogEmoji bool
+}
+
+// Load reads the configuration from the environment. Unset variables take
+// their defaults; set but invalid ones are reported together.
+func Load() (*Config, error) {
+	var errs []error
+	cfg := &Config{
+		DatabaseURL:          os.Getenv("DATABASE_URL"),
+		Port:                 stringEnv("PORT", "8000"),
+		GitHubToken:          os.Getenv("GITHUB_TOKEN"),
+		GitHubHost:           hostEnv("GITHUB_HOST", "github.com"),
+		GitHubAPIURL:         strings.TrimSuffix(os.Getenv("GITHUB_API_URL"), "/"),
+		GitLabToken:          os.Getenv("GITLAB_TOKEN"),
+		GitLabHost:           hostEnv("GITLAB_HOST", "gitlab.com"),
+		OTLPEndpoint:         os.Getenv("OTLP_ENDPOINT"),
+		WebhookURL:           os.Getenv("WEBHOOK_URL"),
+		WebhookSecret:        os.Getenv("WEBHOOK_SECRET"),
+		GitSigningKeyFile:    os.Getenv("GIT_SIGNING_KEY_FILE"),
+		GitSigningKeyID:      os.Getenv("GIT_SIGNING_KEY_ID"),
+		GitSigningPassphrase: os.Getenv("GIT_SIGNING_PASSPHRASE"),
+		GitAuthorName:        os.Getenv("GIT_AUTHOR_NAME"),
+		GitAuthorEmail:       os.Getenv("GIT_AUTHOR_EMAIL"),
+		APIKeys:              map[string]string{},
+		LogFormat:            strings.ToLower(stringEnv("LOG_FORMAT", "json")),
+		LogLevel:             strings.ToLower(stringEnv("LOG_LEVEL", "info")),
+	}
+
+	if cfg.DatabaseURL == "" {
+		errs = append(errs, errors.New("DATABASE_URL is required"))
+	}
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port <= 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT must be a port number, got %q", cfg.Port))
+	}
+
+	cfg.APIMaxAttempts = intEnv("API_MAX_ATTEMPTS", 4, &errs)
+	cfg.JobWorkers = intEnv("JOB_WORKERS", 4, &errs)
+	cfg.JobQueueSize = intEnv("JOB_QUEUE_SIZE", 100, &errs)
+	cfg.CORSMaxAge = intEnv("CORS_MAX_AGE", 600, &errs)
+	cfg.RateLimit = countEnv("RATE_LIMIT", 300, &errs)
+	cfg.CreatePRRateLimit = countEnv("RATE_LIMIT_CREATE_PR", 10, &errs)
+	cfg.ImportRateLimit = countEnv("RATE_LIMIT_IMPORTS", 20, &errs)
+
+	cfg.CloneTimeout = durationEnv("CLONE_TIMEOUT", 2*time.Minute, &errs)
+	cfg.ShutdownTimeout = durationEnv("SHUTDOWN_TIMEOUT", 25*time.Second, &errs)
+	cfg.WebhookTimeout = durationEnv("WEBHOOK_TIMEOUT", 5*time.Second, &errs)
+	if cfg.WebhookURL != "" {
+		if u, err := url.Parse(cfg.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("WEBHOOK_URL must be an http or https URL, got %q", cfg.WebhookURL))
+		}
+	}
+	cfg.MaxCloneBytes = bytesEnv("MAX_CLONE_BYTES", 0, &errs)
+	cfg.MaxUploadBytes = bytesEnv("MAX_UPLOAD_BYTES", 100<<20, &errs)
+	cfg.CloneCacheSize = countEnv("CLONE_CACHE_SIZE", 20, &errs)
+	cfg.CloneCacheTTL = durationEnv("CLONE_CACHE_TTL", time.Hour, &errs)
+	cfg.DefaultBranchTTL = durationEnv("DEFAULT_BRANCH_TTL", time.Hour, &errs)
+	if cfg.MaxUploadBytes == 0 {
+		errs = append(errs, errors.New("MAX_UPLOAD_BYTES must be positive"))
+	}
+
+	if cfg.LogFormat != "json" && cfg.LogFormat != "text" {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT must be json or text, got %q", cfg.LogFormat))
+	}
+	switch cfg.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", cfg.LogLevel))
+	}
+	cfg.MetricsEnabled = boolEnv("METRICS_ENABLED", &errs)
+	cfg.SignCommits = boolEnv("GIT_SIGN_COMMITS", &errs)
+	if cfg.SignCommits && cfg.GitSigningKeyFile == "" {
+		errs = append(errs, errors.New("GIT_SIGNING_KEY_FILE is required when GIT_SIGN_COMMITS is set"))
+	}
+	cfg.LogEmoji = boolEnv("LOG_EMOJI", &errs)
+	cfg.FormatCode = boolEnv("FORMAT_CODE", &errs)
+	cfg.CompileJava = boolEnv("COMPILE_JAVA", &errs)
+	if cfg.GitAuthorEmail != "" {
+		if addr, err := mail.ParseAddress(cfg.GitAuthorEmail); err != nil || addr.Address != cfg.GitAuthorEmail {
+			errs = append(errs, fmt.Errorf("GIT_AUTHOR_EMAIL must be an address like bot@example.com, got %q", cfg.GitAuthorEmail))
+		}
+	}
+
+	for _, pair := range splitList(os.Getenv("API_KEYS")) {
+		key, org, ok := strings.Cut(pair, ":")
+		if !ok || key == "" || org == "" {
+			errs = append(errs, errors.New("API_KEYS entries must look like key:org"))
+			continue
+		}
+		cfg.APIKeys[key] = org
+	}
+	cfg.ScanIncludeTestFiles = boolEnv("SCAN_INCLUDE_TEST_FILES", &errs)
+	for _, pattern := range splitList(os.Getenv("SCAN_EXCLUDE_PATTERNS")) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("SCAN_EXCLUDE_PATTERNS has an invalid pattern %q", pattern))
+			continue
+		}
+		cfg.ScanExcludePatterns = append(cfg.ScanExcludePatterns, pattern)
+	}
+	for _, proxy := range splitList(os.Getenv("TRUSTED_PROXIES")) {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("TRUSTED_PROXIES has an invalid IP or CIDR %q", proxy))
+			continue
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, proxy)
+	}
+	for _, origin := range splitList(os.Getenv("CORS_ALLOWED_ORIGINS")) {
+		cfg.CORSAllowedOrigins = append(cfg.CORSAllowedOrigins, strings.TrimSuffix(origin, "/"))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// stringEnv reads name, or def when unset
+func stringEnv(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// hostEnv reads a host name, dropping any scheme and trailing slash
+func hostEnv(name, def string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(os.Getenv(name), "https://"), "http://")
+	if host = strings.TrimSuffix(host, "/"); host != "" {
+		return host
+	}
+	return def
+}
+
+// bytesEnv reads a non-negative byte count, or def when unset
+func bytesEnv(name string, def int64, errs *[]error) int64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		*errs = append(*errs, fmt.Errorf("%s must be a byte count, got %q", name, v))
+		return def
+	}
+	return n
+}
+
+// intEnv reads a positive integer, or def when unset
+func intEnv(name string, def int, errs *[]error) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		*errs = append(*errs, fmt.Errorf("%s must be a positive integer, got %q", name, v))
+		return def
+	}
+	return n
+}
+
+// countEnv reads a non-negative integer, or def when unset
+func countEnv(name string, def int, errs *[]error) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		*errs = append(*errs, fmt.Errorf("%s must be a non-negative integer, got %q", name, v))
+		return def
+	}
+	return n
+}
+
+// boolEnv reads a boolean such as "true" or "0", or false when unset
+func boolEnv(name string, errs *[]error) bool {
+	v := os.Getenv(name)
+	if v == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s must be true or false, got %q", name, v))
+		return false
+	}
+	return b
+}
+
+// durationEnv reads a positive duration such as "90s", or def when unset
+func durationEnv(name string, def time.Duration, errs *[]error) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		*errs = append(*errs, fmt.Errorf("%s must be a positive duration such as 90s, got %q", name, v))
+		return def
+	}
+	return d
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
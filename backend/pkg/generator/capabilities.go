@@ -0,0 +1,84 @@
+package generator
+
+import (
+    "fmt"
+    "strings"
+
+    "observability-copilot/pkg/scanner"
+    "observability-copilot/pkg/togglespec"
+)
+
+// Capability is what plans can be generated for services of one language
+type Capability struct {
+    Language string `json:"language"`
+    // Frameworks are the web frameworks detected and instrumented
+    Frameworks []string `json:"frameworks"`
+    Metrics    bool     `json:"metrics"`
+    Traces     bool     `json:"traces"`
+    // LogLibraries are the logging libraries whose records get trace
+    // context in the logs modes
+    LogLibraries []string `json:"log_libraries"`
+    // Modes are the telemetry modes whose signals are all generated
+    Modes []string `json:"modes"`
+}
+
+// Capabilities lists, in scanner.SupportedLanguages order, the languages
+// with a generator, taken from the generators and log correlators the
+// plans are dispatched to
+func Capabilities() []Capability {
+    capabilities := []Capability{}
+    for _, language := range scanner.SupportedLanguages {
+        generator, ok := generators[language]
+        if !ok {
+            continue
+        }
+        libraries := append([]string{}, logCorrelators[language].Libraries...)
+        capability := Capability{
+            Language:     language,
+            Frameworks:   scanner.LanguageFrameworks(language),
+            Metrics:      generator.Metrics,
+            Traces:       generator.Traces,
+            LogLibraries: libraries,
+            Modes:        []string{},
+        }
+        for _, mode := range togglespec.Modes {
+            metrics, traces, logs, _ := togglespec.ModeSignals(mode)
+            if metrics && !generator.Metrics || traces && !generator.Traces || logs && len(libraries) == 0 {
+                continue
+            }
+            capability.Modes = append(capability.Modes, mode)
+        }
+        capabilities = append(capabilities, capability)
+    }
+    return capabilities
+}
+
+// CheckMode returns an error naming what's missing when plans for mode
+// can't be generated for language, so requests can be refused before the
+// repo is cloned
+func CheckMode(language, mode string) error {
+    if err := togglespec.ValidateMode(mode); err != nil {
+        return err
+    }
+    for _, capability := range Capabilities() {
+        if capability.Language != language {
+            continue
+        }
+        for _, supported := range capability.Modes {
+            if supported == mode {
+                return nil
+            }
+        }
+        return fmt.Errorf("telemetry_mode %q isn't supported for %s services, which support %s", mode, language, strings.Join(capability.Modes, ", "))
+    }
+    return fmt.Errorf("no generator for %s services, supported languages are %s", language, strings.Join(supportedLanguages(), ", "))
+}
+
+// supportedLanguages lists the languages with a generator
+func supportedLanguages() []string {
+    languages := []string{}
+    for _, capability := range Capabilities() {
+        languages = append(languages, capability.Language)
+    }
+    return languages
+}
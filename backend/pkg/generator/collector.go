@@ -0,0 +1,79 @@
+package generator
+
+import (
+    "fmt"
+    "net"
+    "strings"
+)
+
+// collectorConfigFile is the name of the generated collector config
+const collectorConfigFile = "otel-collector-config.yaml"
+
+// receiverPorts returns the OTLP gRPC and HTTP receiver ports, taking the
+// gRPC port from the app's exporter endpoint so the two always match
+func (o Options) receiverPorts() (grpcPort, httpPort string) {
+    grpcPort, httpPort = "4317", "4318"
+    if _, port, err := net.SplitHostPort(o.endpointHost()); err == nil && port != httpPort {
+        grpcPort = port
+    }
+    return grpcPort, httpPort
+}
+
+// generateCollectorConfig creates an OpenTelemetry Collector config that
+// receives the service's OTLP traces, batches them and exports them to the
+// debug exporter and, when CollectorExporter is set, an OTLP backend.
+func generateCollectorConfig(opts Options) FileChange {
+    grpcPort, httpPort := opts.receiverPorts()
+
+    exporters := `  debug:
+    verbosity: basic
+`
+    names := []string{"debug"}
+    if opts.CollectorExporter != "" {
+        backend := strings.TrimPrefix(strings.TrimPrefix(opts.CollectorExporter, "https://"), "http://")
+        exporters += fmt.Sprintf(`  otlp:
+    endpoint: %s
+    tls:
+      insecure: %t
+`, backend, !strings.HasPrefix(opts.CollectorExporter, "https://"))
+        names = append(names, "otlp")
+    }
+
+    tls := ""
+    if !opts.insecure() {
+        tls = `
+        # The app exports over TLS: mount a certificate for the receiver
+        tls:
+          cert_file: /etc/otelcol/tls/tls.crt
+          key_file: /etc/otelcol/tls/tls.key`
+    }
+
+    content := fmt.Sprintf(`# OpenTelemetry Collector config generated by Observability Copilot.
+# The app exports traces to %s.
+receivers:
+  otlp:
+    protocols:
+      grpc:
+        endpoint: 0.0.0.0:%s%s
+      http:
+        endpoint: 0.0.0.0:%s
+
+processors:
+  batch: {}
+
+exporters:
+%s
+service:
+  pipelines:
+    traces:
+      receivers: [otlp]
+      processors: [batch]
+      exporters: [%s]
+`, opts.endpointHost(), grpcPort, tls, httpPort, exporters, strings.Join(names, ", "))
+
+    return FileChange{
+        Path:    opts.path(collectorConfigFile),
+        Action:  "create",
+        Content: content,
+    }
+}
@@ -0,0 +1,169 @@
+package generator
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+
+    "observability-copilot/pkg/scanner"
+)
+
+// goGORMDecl matches a GORM database opened into a variable, capturing it,
+// e.g. db, err := gorm.Open(...)
+var goGORMDecl = regexp.MustCompile(`^(\w+)\s*(?:,\s*\w+\s*)?:?=\s*gorm\.Open\(`)
+
+// generateDatabaseTracing traces the queries of the database client the
+// scan found, along with a note on what was or wasn't done. Go's
+// database/sql is opened through otelsql and GORM gets its OpenTelemetry
+// plugin; SQLAlchemy is instrumented with the tracer; Spring Boot and
+// Quarkus turn on their JDBC instrumentation.
+func generateDatabaseTracing(framework string, candidates []scanner.Candidate, opts Options) ([]FileChange, string) {
+    db, ok := findCandidate(candidates, "database")
+    if !ok {
+        return []FileChange{}, ""
+    }
+
+    var changes []FileChange
+    switch {
+    case framework == "Go" && db.Framework == "database/sql":
+        changes = generateGoSQLTracing(db, opts)
+    case framework == "Go" && db.Framework == "GORM":
+        changes = generateGORMTracing(db, opts)
+    case framework == "Python" && db.Framework == "SQLAlchemy":
+        changes = generateSQLAlchemyTracing(candidates, opts)
+    case framework == "Java" && db.Framework == "JDBC":
+        changes = generateJDBCTracing(opts)
+    }
+    if len(changes) == 0 {
+        return []FileChange{}, fmt.Sprintf("query tracing isn't generated for %s %s yet", framework, db.Framework)
+    }
+    return changes, fmt.Sprintf("%s queries are traced", db.Framework)
+}
+
+// goModFor returns the go.mod a candidate's module requirements go into
+func goModFor(c scanner.Candidate, opts Options) string {
+    if c.Manifest != "" {
+        return c.Manifest
+    }
+    return opts.path("go.mod")
+}
+
+// generateGoSQLTracing swaps each sql.Open call for otelsql.Open, which
+// takes the same arguments and returns a *sql.DB whose queries are traced.
+// Lines already calling otelsql.Open yield the same change, so rescans of
+// an instrumented service can still remove it.
+func generateGoSQLTracing(db scanner.Candidate, opts Options) []FileChange {
+    changes := []FileChange{}
+    for _, m := range db.Matches {
+        original, traced := m.Text, m.Text
+        if strings.Contains(m.Text, "otelsql.Open(") {
+            original = strings.Replace(m.Text, "otelsql.Open(", "sql.Open(", 1)
+        } else {
+            traced = strings.Replace(m.Text, "sql.Open(", "otelsql.Open(", 1)
+        }
+        changes = append(changes, FileChange{
+            Path:            m.File,
+            Action:          "replace",
+            MatchLine:       original,
+            Content:         traced,
+            Imports:         []string{`"github.com/XSAM/otelsql"`},
+            ReplacedImports: []string{`"database/sql"`},
+        })
+    }
+    if len(changes) == 0 {
+        return changes
+    }
+    return append([]FileChange{{
+        Path:    goModFor(db, opts),
+        Action:  "append",
+        Content: "\nrequire github.com/XSAM/otelsql v0.26.0",
+    }}, changes...)
+}
+
+// generateGORMTracing registers GORM's OpenTelemetry plugin on each
+// database right after it is opened
+func generateGORMTracing(db scanner.Candidate, opts Options) []FileChange {
+    changes := []FileChange{}
+    for _, m := range db.Matches {
+        decl := goGORMDecl.FindStringSubmatch(m.Text)
+        if decl == nil {
+            continue
+        }
+        changes = append(changes, FileChange{
+            Path:   m.File,
+            Action: "modify",
+            Content: fmt.Sprintf(`
+// Trace GORM queries
+if %[1]s != nil {
+    if err := %[1]s.Use(tracing.NewPlugin()); err != nil {
+        log.Printf("Failed to trace database queries: %%v", err)
+    }
+}
+`, decl[1]),
+            LineAfter: m.Text,
+            Imports:   []string{`"log"`, `"gorm.io/plugin/opentelemetry/tracing"`},
+        })
+    }
+    if len(changes) == 0 {
+        return changes
+    }
+    return append([]FileChange{{
+        Path:    goModFor(db, opts),
+        Action:  "append",
+        Content: "\nrequire gorm.io/plugin/opentelemetry v0.1.8",
+    }}, changes...)
+}
+
+// generateSQLAlchemyTracing instruments SQLAlchemy where the tracer is set
+// up, so engines created once it is imported are traced: otel_config.py,
+// or a Django project's settings module
+func generateSQLAlchemyTracing(candidates []scanner.Candidate, opts Options) []FileChange {
+    config := opts.path("otel_config.py")
+    if c, ok := findCandidate(candidates, "http"); ok && c.Framework == "Django" {
+        config = c.Files[0]
+    }
+    return []FileChange{
+        {
+            Path:    opts.path("requirements.txt"),
+            Action:  "append",
+            Content: "\nopentelemetry-instrumentation-sqlalchemy>=0.41b0",
+        },
+        {
+            Path:   config,
+            Action: "append",
+            Content: `
+# Trace SQLAlchemy queries of the engines created from here on
+from opentelemetry.instrumentation.sqlalchemy import SQLAlchemyInstrumentor
+SQLAlchemyInstrumentor().instrument()
+`,
+        },
+    }
+}
+
+// generateJDBCTracing turns on the JDBC instrumentation of the Spring Boot
+// starter or Quarkus. Micronaut's needs a JDBC module of its own and isn't
+// generated.
+func generateJDBCTracing(opts Options) []FileChange {
+    switch opts.WebFramework {
+    case "Quarkus":
+        return []FileChange{{
+            Path:   opts.path(javaPropertiesFile),
+            Action: "append",
+            Content: `
+# Trace JDBC queries
+quarkus.datasource.jdbc.telemetry=true
+`,
+        }}
+    case "Micronaut":
+        return nil
+    }
+    return []FileChange{{
+        Path:   opts.path(javaOTelPropertiesFile),
+        Action: "append",
+        Content: `
+# Trace JDBC queries
+otel.instrumentation.jdbc.enabled=true
+otel.instrumentation.jdbc-datasource.enabled=true
+`,
+    }}
+}
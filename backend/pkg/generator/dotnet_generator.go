@@ -0,0 +1,130 @@
+package generator
+
+import (
+    "fmt"
+    "path"
+    "strings"
+
+    "observability-copilot/pkg/scanner"
+)
+
+func generateDotnetInstrumentation(service, mode string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error) {
+    plan := &InstrumentationPlan{
+        Framework:   ".NET",
+        Service:     service,
+        Mode:        mode,
+        Description: fmt.Sprintf("Add OpenTelemetry instrumentation for %s (mode: %s)", service, mode),
+    }
+
+    c, ok := findCandidate(candidates, "http")
+    if !ok || c.Manifest == "" {
+        return nil, fmt.Errorf("no ASP.NET Core project found for %s", service)
+    }
+    program := c.Files[0]
+
+    // Add package references to the project file
+    packages := []string{
+        `<PackageReference Include="OpenTelemetry.Extensions.Hosting" Version="1.7.0" />`,
+        `<PackageReference Include="OpenTelemetry.Instrumentation.AspNetCore" Version="1.7.0" />`,
+    }
+    if mode == "traces" || mode == "both" {
+        packages = append(packages,
+            `<PackageReference Include="OpenTelemetry.Instrumentation.Http" Version="1.7.0" />`,
+            `<PackageReference Include="OpenTelemetry.Exporter.OpenTelemetryProtocol" Version="1.7.0" />`,
+        )
+    }
+    if mode == "metrics" || mode == "both" {
+        packages = append(packages, `<PackageReference Include="OpenTelemetry.Exporter.Prometheus.AspNetCore" Version="1.7.0-rc.1" />`)
+    }
+
+    plan.Changes = append(plan.Changes, FileChange{
+        Path:   c.Manifest,
+        Action: "modify",
+        Content: `
+  <!-- OpenTelemetry dependencies -->
+  <ItemGroup>
+    ` + strings.Join(packages, "\n    ") + `
+  </ItemGroup>`,
+        LineAfter: "<Project",
+    })
+
+    // Generate instrumentation code
+    plan.Changes = append(plan.Changes, generateDotnetTelemetry(service, mode, path.Dir(program), opts))
+
+    plan.Changes = append(plan.Changes, FileChange{
+        Path:   program,
+        Action: "modify",
+        Content: `
+// Register OpenTelemetry services
+builder.AddObservability();`,
+        LineAfter: "WebApplication.CreateBuilder(",
+    })
+
+    if mode == "metrics" || mode == "both" {
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:   program,
+            Action: "modify",
+            Content: `
+// Expose Prometheus metrics endpoint
+app.MapPrometheusScrapingEndpoint();`,
+            LineAfter: "builder.Build()",
+        })
+    }
+
+    return plan, nil
+}
+
+func generateDotnetTelemetry(service, mode, dir string, opts Options) FileChange {
+    var providers string
+    if mode == "traces" || mode == "both" {
+        providers += fmt.Sprintf(`
+            .WithTracing(tracing => tracing
+                .AddAspNetCoreInstrumentation()
+                .AddHttpClientInstrumentation()
+                .AddOtlpExporter(options =>
+                {
+                    options.Endpoint = new Uri("%s");
+                }))`, opts.endpointURL())
+    }
+    if mode == "metrics" || mode == "both" {
+        providers += `
+            .WithMetrics(metrics => metrics
+                .AddMeter(Telemetry.Meter.Name)
+                .AddAspNetCoreInstrumentation()
+                .AddPrometheusExporter())`
+    }
+
+    code := fmt.Sprintf(`// OpenTelemetry Configuration
+using System.Diagnostics;
+using System.Diagnostics.Metrics;
+using OpenTelemetry.Metrics;
+using OpenTelemetry.Resources;
+using OpenTelemetry.Trace;
+
+public static class Telemetry
+{
+    public const string ServiceName = "%s";
+
+    // Use Telemetry.ActivitySource.StartActivity(...) for custom spans
+    public static readonly ActivitySource ActivitySource = new(ServiceName);
+
+    // Use Telemetry.Meter.CreateCounter<long>(...) for custom metrics
+    public static readonly Meter Meter = new(ServiceName);
+
+    public static WebApplicationBuilder AddObservability(this WebApplicationBuilder builder)
+    {
+        builder.Services.AddOpenTelemetry()
+            .ConfigureResource(resource => resource.AddService(ServiceName))%s;
+
+        Console.WriteLine("✅ OpenTelemetry initialized");
+        return builder;
+    }
+}
+`, service, providers)
+
+    return FileChange{
+        Path:    path.Join(dir, "Telemetry.cs"),
+        Action:  "create",
+        Content: code,
+    }
+}
@@ -2,15 +2,73 @@ package generator
 
 import (
     "fmt"
+    "log/slog"
+    "path"
+    "regexp"
+    "strings"
+
+    "observability-copilot/pkg/scanner"
+    "observability-copilot/pkg/togglespec"
 )
 
+// DefaultOTLPEndpoint is the in-cluster collector used when no endpoint is given
+const DefaultOTLPEndpoint = "otel-collector.observability.svc.cluster.local:4317"
+
 type FileChange struct {
     Path      string `json:"path"`
     Content   string `json:"content"`
     Action    string `json:"action"`
     LineAfter string `json:"line_after"`
+
+    // MatchLine and EndLine select the lines a "delete" or "replace" change
+    // edits: the first line containing MatchLine through the next line
+    // containing EndLine, or only the MatchLine line when EndLine is empty.
+    // A "delete" without MatchLine deletes the generated file itself.
+    MatchLine string `json:"match_line,omitempty"`
+    EndLine   string `json:"end_line,omitempty"`
+
+    // Regexp makes LineAfter, MatchLine and EndLine regular expressions
+    // rather than substrings, to pick one site out of several similar
+    // lines. Go sources, which are edited through the AST, ignore it.
+    Regexp bool `json:"regexp,omitempty"`
+
+    // Imports lists Go import specs (`"path"` or `name "path"`) the change
+    // needs; they are merged into the file's import declaration.
+    Imports []string `json:"imports,omitempty"`
+
+    // ReplacedImports lists Go import specs the lines a "replace" change
+    // swaps out used. They are dropped once nothing refers to them, and
+    // restored when the change is undone.
+    ReplacedImports []string `json:"replaced_imports,omitempty"`
+
+    // CallArgs are Go expressions a "modify" change appends to the
+    // arguments of the call containing LineAfter, e.g. server options for
+    // grpc.NewServer. Arguments the call already has are not added again.
+    CallArgs []string `json:"call_args,omitempty"`
+
+    // WrapHandler is a Go call a "modify" change wraps the handler the
+    // file serves in: the handler passed to http.ListenAndServe or
+    // ListenAndServeTLS, or an http.Server's Handler. HandlerPlaceholder,
+    // its first argument, stands for the handler, http.DefaultServeMux
+    // when it is nil.
+    WrapHandler string `json:"wrap_handler,omitempty"`
+
+    // Chain splices a "modify" change's Content, a method call, into the
+    // builder chain LineAfter starts, e.g. Router::new(), rather than on a
+    // line of its own: ChainStart right after LineAfter, ChainEnd after the
+    // chain's last call.
+    Chain string `json:"chain,omitempty"`
 }
 
+// Where Chain splices a call into a builder chain
+const (
+    ChainStart = "start"
+    ChainEnd   = "end"
+)
+
+// HandlerPlaceholder stands for the served handler in WrapHandler
+const HandlerPlaceholder = "{handler}"
+
 type InstrumentationPlan struct {
     RepoID      string       `json:"repo_id"`
     Framework   string       `json:"framework"`
@@ -18,26 +76,340 @@ type InstrumentationPlan struct {
     Mode        string       `json:"mode"`
     Changes     []FileChange `json:"changes"`
     Description string       `json:"description"`
+
+    // Logs is set when the plan correlates the service's logs with its
+    // traces. A plan adding nothing else has mode "logs".
+    Logs bool `json:"logs,omitempty"`
+
+    // Candidates are the scanned sites the plan was generated against,
+    // with the lines they were found on
+    Candidates []scanner.Candidate `json:"candidates,omitempty"`
 }
 
-func Generate(framework, service, mode string) (*InstrumentationPlan, error) {
-    switch framework {
-    case "Go":
-        return generateGoInstrumentation(service, mode)
-    case "Python":
-        return generatePythonInstrumentation(service, mode)
-    case "Java":
-        return generateJavaInstrumentation(service, mode)
-    case "Node.js":
-        return generateNodeInstrumentation(service, mode)
-    default:
+// Options tunes the generated instrumentation
+type Options struct {
+    // OTLPEndpoint is the collector address, e.g. "collector:4317" or
+    // "https://collector.example.com:4317". An https:// scheme enables TLS.
+    OTLPEndpoint string
+
+    // Dir is the service's module directory relative to the repo root.
+    // Manifests and generated files are placed there.
+    Dir string
+
+    // SamplingRate is the fraction of traces to sample, in [0, 1].
+    // Nil samples every trace.
+    SamplingRate *float64
+
+    // ModulePath is the scanned Go module's path from go.mod. Go metrics
+    // are generated as a package of the module imported under it, or into
+    // the main file when it is empty.
+    ModulePath string
+
+    // WebFramework is the scanned web framework, e.g. "Chi" or "FastAPI".
+    // It picks the snippets when no http candidate says otherwise.
+    WebFramework string
+
+    // CollectorConfig adds an OpenTelemetry Collector config receiving the
+    // service's traces when the plan adds tracing.
+    CollectorConfig bool
+
+    // CollectorExporter is the OTLP backend the collector forwards traces
+    // to, e.g. "tempo:4317". Empty only logs them with the debug exporter.
+    CollectorExporter string
+
+    // PrometheusConfig adds a Prometheus scrape job for the service when
+    // the plan adds metrics.
+    PrometheusConfig bool
+
+    // MetricsPort is the port the service serves metrics on, defaulting
+    // to AppPort and then DefaultMetricsPort. A gRPC-only Go service gets
+    // a metrics listener on it, defaulting to DefaultGRPCMetricsPort.
+    MetricsPort int
+
+    // MetricsNamespace and MetricsSubsystem prefix the names of the
+    // generated HTTP metrics, as in namespace_subsystem_http_requests_total.
+    // Go and Python set them as the client's Namespace and Subsystem
+    // fields. Java, .NET and Ruby keep their platform's built-in metric
+    // names. Empty keeps the bare names.
+    MetricsNamespace string
+    MetricsSubsystem string
+
+    // AppPort is the port the scan found the service listening on. The
+    // generated metrics routes are served by the app itself, so they are
+    // scraped there unless MetricsPort says otherwise.
+    AppPort int
+
+    // DatabaseTracing traces the queries of the database client the scan
+    // found, e.g. through otelsql for Go's database/sql, when the plan adds
+    // tracing.
+    DatabaseTracing bool
+
+    // GrafanaDashboard adds a starter Grafana dashboard for the service's
+    // HTTP metrics when the plan adds metrics.
+    GrafanaDashboard bool
+
+    // Logger receives the generated plan's summary, typically tagged with
+    // the request ID. Nil uses slog's default logger.
+    Logger *slog.Logger
+}
+
+// logger returns the Logger, defaulting to slog's
+func (o Options) logger() *slog.Logger {
+    if o.Logger == nil {
+        return slog.Default()
+    }
+    return o.Logger
+}
+
+// path resolves a module-relative file to a repo-relative path
+func (o Options) path(name string) string {
+    return path.Join(o.Dir, name)
+}
+
+// endpointHost returns the OTLP endpoint as host:port without a scheme
+func (o Options) endpointHost() string {
+    host := strings.TrimPrefix(o.OTLPEndpoint, "https://")
+    return strings.TrimPrefix(host, "http://")
+}
+
+// endpointURL returns the OTLP endpoint with an explicit http(s) scheme
+func (o Options) endpointURL() string {
+    if o.insecure() {
+        return "http://" + o.endpointHost()
+    }
+    return "https://" + o.endpointHost()
+}
+
+// samplingRate returns the configured trace sampling ratio, defaulting to 1
+func (o Options) samplingRate() float64 {
+    if o.SamplingRate == nil {
+        return 1
+    }
+    return *o.SamplingRate
+}
+
+// metricName returns a generated metric's full name, prefixed with the
+// metrics namespace and subsystem
+func (o Options) metricName(name string) string {
+    parts := []string{}
+    for _, part := range []string{o.MetricsNamespace, o.MetricsSubsystem, name} {
+        if part != "" {
+            parts = append(parts, part)
+        }
+    }
+    return strings.Join(parts, "_")
+}
+
+// metricNamePart matches a valid metrics namespace or subsystem
+var metricNamePart = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ValidateMetricsNamespace checks that the metrics namespace and subsystem,
+// when set, make valid Prometheus metric name prefixes
+func ValidateMetricsNamespace(namespace, subsystem string) error {
+    if namespace != "" && !metricNamePart.MatchString(namespace) {
+        return fmt.Errorf("invalid metrics_namespace %q: use letters, digits and underscores, not starting with a digit", namespace)
+    }
+    if subsystem != "" && !metricNamePart.MatchString(subsystem) {
+        return fmt.Errorf("invalid metrics_subsystem %q: use letters, digits and underscores, not starting with a digit", subsystem)
+    }
+    return nil
+}
+
+// insecure reports whether the exporter should skip TLS
+func (o Options) insecure() bool {
+    return !strings.HasPrefix(o.OTLPEndpoint, "https://")
+}
+
+func Generate(framework, service, mode string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error) {
+    if opts.OTLPEndpoint == "" {
+        opts.OTLPEndpoint = DefaultOTLPEndpoint
+    }
+
+    log := opts.logger().With("framework", framework, "service", service)
+
+    // Turning observability off removes what the generator added
+    if mode == "none" {
+        plan, err := generateRemoval(framework, service, candidates, opts)
+        if err != nil {
+            return nil, err
+        }
+        log.Debug("generated removal plan", "changes", len(plan.Changes))
+        return plan, nil
+    }
+
+    // Logs combine with the other signals, e.g. "metrics+logs"
+    metrics, traces, logs, ok := togglespec.ModeSignals(mode)
+    if !ok {
+        return nil, togglespec.ValidateMode(mode)
+    }
+    requested := mode
+    mode = togglespec.ModeFor(metrics, traces, false)
+
+    // Only add signals the scan didn't already find wired up. The plan
+    // keeps the requested mode when none are pending: "none" would mean
+    // removal.
+    mode = pendingMode(mode, candidates)
+    logChanges, logNote := []FileChange{}, ""
+    if logs {
+        logChanges, logNote = generateLogCorrelation(framework, candidates, opts)
+    }
+    if mode == "" && len(logChanges) == 0 {
+        log.Debug("service already instrumented, nothing to generate")
+        description := fmt.Sprintf("%s is already instrumented", service)
+        if logNote != "" {
+            description += "; " + logNote
+        }
+        return &InstrumentationPlan{
+            Framework:   framework,
+            Service:     service,
+            Mode:        requested,
+            Changes:     []FileChange{},
+            Description: description,
+        }, nil
+    }
+
+    var plan *InstrumentationPlan
+    if mode == "" {
+        plan = &InstrumentationPlan{
+            Framework:   framework,
+            Service:     service,
+            Mode:        "logs",
+            Changes:     []FileChange{},
+            Description: fmt.Sprintf("Correlate the logs of %s with its traces", service),
+        }
+    } else {
+        var err error
+        if plan, err = generateFor(framework, service, mode, candidates, opts); err != nil {
+            return nil, err
+        }
+    }
+    if opts.CollectorConfig && (plan.Mode == "traces" || plan.Mode == "both") {
+        plan.Changes = append(plan.Changes, generateCollectorConfig(opts))
+    }
+    if opts.PrometheusConfig && (plan.Mode == "metrics" || plan.Mode == "both") {
+        plan.Changes = append(plan.Changes, generatePrometheusConfig(framework, service, candidates, opts))
+    }
+    if opts.GrafanaDashboard && (plan.Mode == "metrics" || plan.Mode == "both") {
+        dashboard, err := generateGrafanaDashboard(framework, service, opts)
+        if err != nil {
+            return nil, err
+        }
+        plan.Changes = append(plan.Changes, dashboard)
+    }
+    if opts.DatabaseTracing && (plan.Mode == "traces" || plan.Mode == "both") {
+        databaseChanges, databaseNote := generateDatabaseTracing(framework, candidates, opts)
+        plan.Changes = append(plan.Changes, databaseChanges...)
+        if databaseNote != "" {
+            plan.Description += "; " + databaseNote
+        }
+    }
+    if len(logChanges) > 0 {
+        plan.Changes = append(plan.Changes, logChanges...)
+        plan.Logs = true
+    }
+    if logNote != "" && plan.Mode != "logs" {
+        plan.Description += "; " + logNote
+    }
+    log.Debug("generated instrumentation plan", "mode", plan.Mode, "logs", plan.Logs, "changes", len(plan.Changes))
+    return plan, nil
+}
+
+// generateFor dispatches to the language's generator
+func generateFor(framework, service, mode string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error) {
+    generator, ok := generators[framework]
+    if !ok {
         return nil, fmt.Errorf("unsupported framework: %s", framework)
     }
+    return generator.Generate(service, mode, candidates, opts)
+}
+
+// languageGenerator generates the plans of one language, for the signals
+// it has snippets for
+type languageGenerator struct {
+    Generate func(service, mode string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error)
+    Metrics  bool
+    Traces   bool
+}
+
+// generators are keyed by the language a service was detected as
+var generators = map[string]languageGenerator{
+    "Go":      {Generate: generateGoInstrumentation, Metrics: true, Traces: true},
+    "Python":  {Generate: generatePythonForWeb, Metrics: true, Traces: true},
+    "Java":    {Generate: generateJavaInstrumentation, Metrics: true, Traces: true},
+    "Node.js": {Generate: generateNodeInstrumentation, Metrics: true, Traces: true},
+    ".NET":    {Generate: generateDotnetInstrumentation, Metrics: true, Traces: true},
+    "Rust":    {Generate: generateRustInstrumentation, Metrics: true, Traces: true},
+    "Ruby":    {Generate: generateRubyInstrumentation, Metrics: true, Traces: true},
+}
+
+// generatePythonForWeb picks the Python web framework from the http
+// candidate, then opts, defaulting to Flask
+func generatePythonForWeb(service, mode string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error) {
+    web := "Flask"
+    if opts.WebFramework != "" {
+        web = opts.WebFramework
+    }
+    if c, ok := findCandidate(candidates, "http"); ok {
+        web = c.Framework
+    }
+    return generatePythonInstrumentation(service, web, mode, candidates, opts)
 }
 
+// logCorrelator adds the active span's IDs to the records of a language's
+// Libraries
+type logCorrelator struct {
+    Libraries []string
+    Generate  func(logs scanner.Candidate, candidates []scanner.Candidate, opts Options) ([]FileChange, string)
+}
 
+// logCorrelators are keyed by language
+var logCorrelators = map[string]logCorrelator{
+    "Go": {Libraries: []string{"slog", "zap"}, Generate: generateGoLogCorrelation},
+}
 
+// generateLogCorrelation adds the active span's trace_id and span_id to
+// the records of the logger the scan found, for the languages and logging
+// libraries supported, along with a note on what was or wasn't done
+func generateLogCorrelation(framework string, candidates []scanner.Candidate, opts Options) ([]FileChange, string) {
+    logs, ok := findCandidate(candidates, "logs")
+    if !ok {
+        return []FileChange{}, "no logger setup was found to correlate with traces"
+    }
+    correlator := logCorrelators[framework]
+    for _, library := range correlator.Libraries {
+        if logs.Framework == library {
+            return correlator.Generate(logs, candidates, opts)
+        }
+    }
+    return []FileChange{}, fmt.Sprintf("log correlation isn't generated for %s %s yet, so its logger was left unchanged", framework, logs.Framework)
+}
 
-func generateNodeInstrumentation(service, mode string) (*InstrumentationPlan, error) {
-    return nil, fmt.Errorf("Node.js instrumentation not implemented yet")
+// findCandidate returns the first candidate of the given kind, if any
+func findCandidate(candidates []scanner.Candidate, kind string) (scanner.Candidate, bool) {
+    for _, c := range candidates {
+        if c.Kind == kind && len(c.Files) > 0 {
+            return c, true
+        }
+    }
+    return scanner.Candidate{}, false
+}
+
+// pendingMode drops signals that already have metrics or traces candidates,
+// returning "" when none are left
+func pendingMode(mode string, candidates []scanner.Candidate) string {
+    _, hasMetrics := findCandidate(candidates, "metrics")
+    _, hasTraces := findCandidate(candidates, "traces")
+    wantMetrics := (mode == "metrics" || mode == "both") && !hasMetrics
+    wantTraces := (mode == "traces" || mode == "both") && !hasTraces
+
+    switch {
+    case wantMetrics && wantTraces:
+        return "both"
+    case wantMetrics:
+        return "metrics"
+    case wantTraces:
+        return "traces"
+    default:
+        return ""
+    }
 }
@@ -2,9 +2,130 @@ package generator
 
 import (
     "fmt"
+    "go/format"
+    "path"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+
+    "observability-copilot/pkg/scanner"
 )
 
-func generateGoInstrumentation(service, mode string) (*InstrumentationPlan, error) {
+// goRouter describes how instrumentation hooks into a Go HTTP framework
+type goRouter struct {
+    Anchor     string // statement that constructs the router; empty means the top of main()
+    Var        string // router variable assumed when the scan didn't find one
+    Module     string // OTel contrib module required in go.mod
+    Import     string // import path of the tracing middleware
+    Middleware string // middleware registration, formatted with the service name
+    Metrics    string // /metrics endpoint registration
+
+    MetricsMiddleware string   // declaration of the middleware recording the HTTP metrics
+    MetricsImports    []string // imports MetricsMiddleware needs
+    UseMetrics        string   // registration of the metrics middleware
+
+    // Routers without middleware registration wrap the handler the server
+    // serves instead; see FileChange.WrapHandler
+    WrapTracing string // tracing wrapper, formatted with the service name
+    WrapMetrics string // metrics middleware wrapper
+
+    // Mux constructs a mux served in place of the default one, which
+    // MuxMetrics registers the /metrics endpoint on instead of Metrics
+    Mux        string
+    MuxMetrics string
+}
+
+// routerPlaceholder stands for the router variable in Middleware and Metrics
+const routerPlaceholder = "{router}"
+
+// withVar fills the router variable into a snippet, defaulting to r.Var
+func (r goRouter) withVar(snippet, name string) string {
+    if name == "" {
+        name = r.Var
+    }
+    return strings.ReplaceAll(snippet, routerPlaceholder, name)
+}
+
+var goRouters = map[string]goRouter{
+    "Gin": {
+        Anchor:     "gin.Default()",
+        Var:        "router",
+        Module:     "go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin v0.46.1",
+        Import:     "go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin",
+        Middleware: `{router}.Use(otelgin.Middleware("%s"))`,
+        Metrics:    `{router}.GET("/metrics", gin.WrapH(promhttp.Handler()))`,
+
+        MetricsMiddleware: ginMetricsMiddleware,
+        MetricsImports:    []string{`"strconv"`, `"time"`, `"github.com/gin-gonic/gin"`},
+        UseMetrics:        `{router}.Use({middleware}())`,
+    },
+    "Echo": {
+        Anchor:     "echo.New()",
+        Var:        "e",
+        Module:     "go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho v0.46.1",
+        Import:     "go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho",
+        Middleware: `{router}.Use(otelecho.Middleware("%s"))`,
+        Metrics:    `{router}.GET("/metrics", echo.WrapHandler(promhttp.Handler()))`,
+
+        MetricsMiddleware: echoMetricsMiddleware,
+        MetricsImports:    []string{`"strconv"`, `"time"`, `"github.com/labstack/echo/v4"`},
+        UseMetrics:        `{router}.Use({middleware})`,
+    },
+    "Chi": {
+        Anchor: "chi.NewRouter()",
+        Var:    "r",
+        Module: "go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.46.1",
+        Import: "go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp",
+        Middleware: `{router}.Use(func(next http.Handler) http.Handler {
+    return otelhttp.NewHandler(next, "%s")
+})`,
+        Metrics: `{router}.Handle("/metrics", promhttp.Handler())`,
+
+        MetricsMiddleware: httpMetricsMiddleware(`        endpoint := r.URL.Path
+        if rc := chi.RouteContext(r.Context()); rc != nil && rc.RoutePattern() != "" {
+            endpoint = rc.RoutePattern()
+        }`),
+        MetricsImports: []string{`"net/http"`, `"strconv"`, `"time"`, `"github.com/go-chi/chi/v5"`},
+        UseMetrics:     `{router}.Use({middleware})`,
+    },
+    "Gorilla Mux": {
+        Anchor:     "mux.NewRouter()",
+        Var:        "r",
+        Module:     "go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux v0.46.1",
+        Import:     "go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux",
+        Middleware: `{router}.Use(otelmux.Middleware("%s"))`,
+        Metrics:    `{router}.Handle("/metrics", promhttp.Handler())`,
+
+        MetricsMiddleware: httpMetricsMiddleware(`        endpoint := r.URL.Path
+        if route := mux.CurrentRoute(r); route != nil {
+            if template, err := route.GetPathTemplate(); err == nil {
+                endpoint = template
+            }
+        }`),
+        MetricsImports: []string{`"net/http"`, `"strconv"`, `"time"`, `"github.com/gorilla/mux"`},
+        UseMetrics:     `{router}.Use({middleware})`,
+    },
+    "net/http": {
+        Anchor: "",
+        Module: "go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.46.1",
+        Import: "go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp",
+        Metrics: `http.Handle("/metrics", promhttp.Handler())`,
+
+        // net/http has no route templates before Go 1.22, so requests are
+        // recorded under their path
+        MetricsMiddleware: httpMetricsMiddleware(`        endpoint := r.URL.Path`),
+        MetricsImports:    []string{`"net/http"`, `"strconv"`, `"time"`},
+
+        WrapTracing: `otelhttp.NewHandler({handler}, "%s")`,
+        WrapMetrics: `{middleware}({handler})`,
+
+        Mux:        "http.NewServeMux()",
+        MuxMetrics: `{router}.Handle("/metrics", promhttp.Handler())`,
+    },
+}
+
+func generateGoInstrumentation(service, mode string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error) {
     plan := &InstrumentationPlan{
         Framework:   "Go",
         Service:     service,
@@ -12,53 +133,135 @@ func generateGoInstrumentation(service, mode string) (*InstrumentationPlan, erro
         Description: fmt.Sprintf("Add OpenTelemetry instrumentation for %s (mode: %s)", service, mode),
     }
 
-    // Always add OTel dependencies
+    // Default to the scanned framework, or Gin, in main.go when the scan
+    // found no router
+    framework := "Gin"
+    if _, known := goRouters[opts.WebFramework]; known {
+        framework = opts.WebFramework
+    }
+    entry := opts.path("main.go")
+    gomod := opts.path("go.mod")
+    metricsPath, routerVar := "", ""
+    if c, ok := findCandidate(candidates, "http"); ok {
+        if _, known := goRouters[c.Framework]; known {
+            framework = c.Framework
+        }
+        entry = c.Files[0]
+        if c.Manifest != "" {
+            gomod = c.Manifest
+        }
+        metricsPath = c.MetricsPath
+        routerVar = c.RouterVar
+    }
+    router := goRouters[framework]
+    router.Middleware = router.withVar(router.Middleware, routerVar)
+    router.Metrics = router.withVar(router.Metrics, routerVar)
+    router.UseMetrics = router.withVar(router.UseMetrics, routerVar)
+
+    // A net/http app serving a mux of its own, rather than passing nil for
+    // http.DefaultServeMux, gets the endpoint on that mux
+    metricsRouter := router
+    if routerVar != "" && router.Mux != "" {
+        metricsRouter.Anchor = router.Mux
+        metricsRouter.Metrics = router.withVar(router.MuxMetrics, routerVar)
+    }
+
+    // A gRPC server gets interceptors. Without an HTTP router alongside it,
+    // main() starts the tracer and metrics get a listener of their own.
+    server, hasGRPC := findCandidate(candidates, "grpc")
+    _, hasHTTP := findCandidate(candidates, "http")
+    grpcOnly := hasGRPC && !hasHTTP
+    if grpcOnly {
+        entry = server.Files[0]
+        if server.Manifest != "" {
+            gomod = server.Manifest
+        }
+    }
+
+    // Add dependencies for the selected signals
+    requires := []string{}
+    if mode == "traces" || mode == "both" {
+        requires = append(requires,
+            "go.opentelemetry.io/otel v1.21.0",
+            "go.opentelemetry.io/otel/sdk v1.21.0",
+            "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc v1.21.0",
+        )
+        if !grpcOnly {
+            requires = append(requires, router.Module)
+        }
+        if hasGRPC {
+            requires = append(requires, "go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc v0.46.1")
+        }
+    }
+    if mode == "metrics" || mode == "both" {
+        requires = append(requires, "github.com/prometheus/client_golang v1.17.0")
+        if hasGRPC {
+            requires = append(requires, "github.com/grpc-ecosystem/go-grpc-prometheus v1.2.0")
+        }
+    }
+
     plan.Changes = append(plan.Changes, FileChange{
-        Path:   "go.mod",
+        Path:   gomod,
         Action: "append",
         Content: `
 require (
-    go.opentelemetry.io/otel v1.21.0
-    go.opentelemetry.io/otel/sdk v1.21.0
-    go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc v1.21.0
-    go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin v0.46.1
+    ` + strings.Join(requires, "\n    ") + `
 )`,
     })
 
+    // Routes go in first: each statement is inserted right after the
+    // router's construction, so the middleware added later lands above
+    // them, as Chi requires. A route already serving promhttp is reused
+    // rather than registering the handler twice.
+    if (mode == "metrics" || mode == "both") && !grpcOnly {
+        if metricsPath == "" {
+            plan.Changes = append(plan.Changes, generateGoMetricsEndpoint(entry, metricsRouter))
+        } else {
+            plan.Description += fmt.Sprintf("; metrics are served on the existing %s route", metricsPath)
+        }
+    }
+
     // Generate tracer initialization code
     if mode == "traces" || mode == "both" {
-        plan.Changes = append(plan.Changes, generateGoTracerInit(service))
-        plan.Changes = append(plan.Changes, generateGoMiddleware(service))
+        plan.Changes = append(plan.Changes, generateGoTracerInit(service, entry, opts), generateGoTracerStartup(entry))
+        if !grpcOnly {
+            plan.Changes = append(plan.Changes, generateGoMiddleware(service, entry, router))
+        }
+        if hasGRPC {
+            plan.Changes = append(plan.Changes, generateGoGRPCTracing(server.Files[0]))
+        }
     }
 
     // Generate Prometheus metrics code
-    if mode == "metrics" || mode == "both" {
-        plan.Changes = append(plan.Changes, generateGoMetrics(service))
+    if (mode == "metrics" || mode == "both") && grpcOnly {
+        port := grpcMetricsPort(opts)
+        plan.Changes = append(plan.Changes, generateGoGRPCMetrics(server.Files[0], port))
+        plan.Description += fmt.Sprintf("; gRPC metrics are served on :%d/metrics", port)
+    } else if mode == "metrics" || mode == "both" {
+        if hasGRPC {
+            plan.Changes = append(plan.Changes, generateGoGRPCMetrics(server.Files[0], 0))
+        }
+        plan.Changes = append(plan.Changes, generateGoMetrics(entry, router, opts)...)
     }
 
     return plan, nil
 }
 
-func generateGoTracerInit(service string) FileChange {
-    code := fmt.Sprintf(`
-import (
-    "context"
-    "log"
-    "go.opentelemetry.io/otel"
-    "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-    "go.opentelemetry.io/otel/sdk/resource"
-    sdktrace "go.opentelemetry.io/otel/sdk/trace"
-    semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
-)
+func generateGoTracerInit(service, entry string, opts Options) FileChange {
+    exporterOpts := fmt.Sprintf(`otlptracegrpc.WithEndpoint("%s"),`, opts.endpointHost())
+    if opts.insecure() {
+        exporterOpts += `
+        otlptracegrpc.WithInsecure(),`
+    }
 
+    code := fmt.Sprintf(`
 // initTracer initializes the OpenTelemetry tracer
 func initTracer() (*sdktrace.TracerProvider, error) {
     ctx := context.Background()
     
     // Create OTLP exporter
     exporter, err := otlptracegrpc.New(ctx,
-        otlptracegrpc.WithEndpoint("otel-collector.observability.svc.cluster.local:4317"),
-        otlptracegrpc.WithInsecure(),
+        %s
     )
     if err != nil {
         return nil, err
@@ -67,6 +270,7 @@ func initTracer() (*sdktrace.TracerProvider, error) {
     // Create tracer provider
     tp := sdktrace.NewTracerProvider(
         sdktrace.WithBatcher(exporter),
+        sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(%g))),
         sdktrace.WithResource(resource.NewWithAttributes(
             semconv.SchemaURL,
             semconv.ServiceNameKey.String("%s"),
@@ -76,51 +280,202 @@ func initTracer() (*sdktrace.TracerProvider, error) {
     otel.SetTracerProvider(tp)
     log.Println("✅ OpenTelemetry tracer initialized")
     return tp, nil
-}`, service)
+}`, exporterOpts, opts.samplingRate(), service)
 
     return FileChange{
-        Path:      "main.go",
-        Action:    "append",
-        Content:   code,
-        LineAfter: "import (",
+        Path:    entry,
+        Action:  "append",
+        Content: code,
+        Imports: []string{
+            `"context"`,
+            `"log"`,
+            `"go.opentelemetry.io/otel"`,
+            `"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"`,
+            `"go.opentelemetry.io/otel/sdk/resource"`,
+            `sdktrace "go.opentelemetry.io/otel/sdk/trace"`,
+            `semconv "go.opentelemetry.io/otel/semconv/v1.21.0"`,
+        },
     }
 }
 
-func generateGoMiddleware(service string) FileChange {
-    code := fmt.Sprintf(`
-import "go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
-
-// Add to main() function after router creation:
+// goTracerStartup starts the tracer and flushes it when main() returns
+const goTracerStartup = `
 // Initialize tracer
 tp, err := initTracer()
 if err != nil {
-    log.Fatalf("Failed to initialize tracer: %%v", err)
+    log.Fatalf("Failed to initialize tracer: %v", err)
 }
 defer func() {
     if err := tp.Shutdown(context.Background()); err != nil {
-        log.Printf("Error shutting down tracer: %%v", err)
+        log.Printf("Error shutting down tracer: %v", err)
     }
 }()
+`
 
-// Add OTel middleware to Gin router
-router.Use(otelgin.Middleware("%s"))
-`, service)
-
+// generateGoTracerStartup starts the tracer at the top of main(), in entry
+// or the file of its package declaring main, so that the deferred shutdown
+// runs when the program exits rather than when the router is built
+func generateGoTracerStartup(entry string) FileChange {
     return FileChange{
-        Path:      "main.go",
+        Path:    entry,
+        Action:  "modify",
+        Content: goTracerStartup,
+        Imports: []string{`"context"`, `"log"`},
+    }
+}
+
+func generateGoMiddleware(service, entry string, router goRouter) FileChange {
+    code := ""
+    if router.Middleware != "" {
+        code = fmt.Sprintf(`
+// Add OTel middleware to the router
+%s
+`, fmt.Sprintf(router.Middleware, service))
+    }
+
+    imports := []string{strconv.Quote(router.Import)}
+    if strings.Contains(router.Middleware, "http.") {
+        imports = append(imports, `"net/http"`)
+    }
+
+    change := FileChange{
+        Path:      entry,
         Action:    "modify",
         Content:   code,
-        LineAfter: "router := gin.Default()",
+        LineAfter: router.Anchor,
+        Imports:   imports,
+    }
+    if router.WrapTracing != "" {
+        change.WrapHandler = fmt.Sprintf(router.WrapTracing, service)
     }
+    return change
 }
 
-func generateGoMetrics(service string) FileChange {
-    code := `
-import (
-    "github.com/prometheus/client_golang/prometheus"
-    "github.com/prometheus/client_golang/prometheus/promhttp"
+// goMetricsPackage is the generated metrics package's name and directory in
+// the module. It is not "metrics" so it stays clear of a package of the
+// service's own; applyPlan refuses to create over an existing file.
+const goMetricsPackage = "httpmetrics"
+
+// Placeholders in MetricsMiddleware for the middleware's name and the
+// collectors it records into
+const (
+    middlewarePlaceholder = "{middleware}"
+    totalPlaceholder      = "{total}"
+    durationPlaceholder   = "{duration}"
 )
 
+// ginMetricsMiddleware records Gin requests under their route template
+const ginMetricsMiddleware = `
+// {middleware} records the count and duration of every request
+func {middleware}() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        start := time.Now()
+        c.Next()
+
+        endpoint := c.FullPath()
+        if endpoint == "" {
+            endpoint = "unmatched"
+        }
+        {total}.WithLabelValues(c.Request.Method, endpoint, strconv.Itoa(c.Writer.Status())).Inc()
+        {duration}.WithLabelValues(c.Request.Method, endpoint).Observe(time.Since(start).Seconds())
+    }
+}
+`
+
+// echoMetricsMiddleware records Echo requests under their route template,
+// with the status of the error a handler returns
+const echoMetricsMiddleware = `
+// {middleware} records the count and duration of every request
+func {middleware}(next echo.HandlerFunc) echo.HandlerFunc {
+    return func(c echo.Context) error {
+        start := time.Now()
+        err := next(c)
+
+        status := c.Response().Status
+        if he, ok := err.(*echo.HTTPError); ok {
+            status = he.Code
+        }
+        {total}.WithLabelValues(c.Request().Method, c.Path(), strconv.Itoa(status)).Inc()
+        {duration}.WithLabelValues(c.Request().Method, c.Path()).Observe(time.Since(start).Seconds())
+        return err
+    }
+}
+`
+
+// httpMetricsMiddleware records net/http requests, with endpoint naming
+// the route the request was served by
+func httpMetricsMiddleware(endpoint string) string {
+    return `
+// {middleware} records the count and duration of every request
+func {middleware}(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        rec := &metricsRecorder{ResponseWriter: w, status: http.StatusOK}
+        next.ServeHTTP(rec, r)
+
+` + endpoint + `
+        {total}.WithLabelValues(r.Method, endpoint, strconv.Itoa(rec.status)).Inc()
+        {duration}.WithLabelValues(r.Method, endpoint).Observe(time.Since(start).Seconds())
+    })
+}
+
+// metricsRecorder captures the status code a handler writes
+type metricsRecorder struct {
+    http.ResponseWriter
+    status int
+}
+
+func (r *metricsRecorder) WriteHeader(status int) {
+    r.status = status
+    r.ResponseWriter.WriteHeader(status)
+}
+`
+}
+
+// goMetricOpts sets the metrics namespace and subsystem in the generated
+// collectors' options
+func goMetricOpts(code string, opts Options) string {
+    fields := ""
+    if opts.MetricsNamespace != "" {
+        fields += fmt.Sprintf("Namespace: %q,\n", opts.MetricsNamespace)
+    }
+    if opts.MetricsSubsystem != "" {
+        fields += fmt.Sprintf("Subsystem: %q,\n", opts.MetricsSubsystem)
+    }
+    if fields == "" {
+        return code
+    }
+    return strings.ReplaceAll(code, "Name: ", fields+"Name: ")
+}
+
+// generateGoMetrics declares the HTTP metrics and the middleware recording
+// them in a package of their own, imported under the module path, and
+// registers the middleware on the router. Without a module path they are
+// appended to the main file instead.
+func generateGoMetrics(entry string, router goRouter, opts Options) []FileChange {
+    register := FileChange{
+        Path:      entry,
+        Action:    "modify",
+        LineAfter: router.Anchor,
+        Imports:   []string{},
+    }
+    if strings.Contains(router.UseMetrics, "http.") {
+        register.Imports = append(register.Imports, `"net/http"`)
+    }
+
+    if opts.ModulePath == "" {
+        useGoMetrics(&register, router, "metricsMiddleware")
+        middleware := strings.NewReplacer(
+            middlewarePlaceholder, "metricsMiddleware",
+            totalPlaceholder, "httpRequestsTotal",
+            durationPlaceholder, "httpRequestDuration",
+        ).Replace(router.MetricsMiddleware)
+
+        return []FileChange{
+            {
+                Path:   entry,
+                Action: "append",
+                Content: goMetricOpts(`
 var (
     httpRequestsTotal = prometheus.NewCounterVec(
         prometheus.CounterOpts{
@@ -144,16 +499,297 @@ func init() {
     prometheus.MustRegister(httpRequestsTotal)
     prometheus.MustRegister(httpRequestDuration)
 }
+`, opts) + middleware,
+                Imports: append([]string{`"github.com/prometheus/client_golang/prometheus"`}, router.MetricsImports...),
+            },
+            register,
+        }
+    }
+
+    // Standard library imports go in a group above the others
+    std, deps := []string{}, []string{`"github.com/prometheus/client_golang/prometheus"`}
+    for _, imp := range router.MetricsImports {
+        if strings.Contains(strings.SplitN(imp, "/", 2)[0], ".") {
+            deps = append(deps, imp)
+        } else {
+            std = append(std, imp)
+        }
+    }
+    sort.Strings(std)
+    sort.Strings(deps)
+    imports := strings.Join(deps, "\n    ")
+    if len(std) > 0 {
+        imports = strings.Join(std, "\n    ") + "\n\n    " + imports
+    }
+    middleware := strings.NewReplacer(
+        middlewarePlaceholder, "Middleware",
+        totalPlaceholder, "HTTPRequestsTotal",
+        durationPlaceholder, "HTTPRequestDuration",
+    ).Replace(router.MetricsMiddleware)
+    code := `// Package httpmetrics declares the service's Prometheus metrics and the
+// middleware recording them. They are registered with the default registry
+// when the package is imported.
+package httpmetrics
+
+import (
+    ` + imports + `
+)
+
+var (
+    // HTTPRequestsTotal counts HTTP requests by method, endpoint and status
+    HTTPRequestsTotal = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Name: "http_requests_total",
+            Help: "Total number of HTTP requests",
+        },
+        []string{"method", "endpoint", "status"},
+    )
+
+    // HTTPRequestDuration observes HTTP request durations by method and
+    // endpoint
+    HTTPRequestDuration = prometheus.NewHistogramVec(
+        prometheus.HistogramOpts{
+            Name:    "http_request_duration_seconds",
+            Help:    "HTTP request duration in seconds",
+            Buckets: prometheus.DefBuckets,
+        },
+        []string{"method", "endpoint"},
+    )
+)
+
+func init() {
+    prometheus.MustRegister(HTTPRequestsTotal)
+    prometheus.MustRegister(HTTPRequestDuration)
+}
+` + middleware
+
+    code = goMetricOpts(code, opts)
+
+    // The file is written as-is, so it is gofmt-formatted here
+    if formatted, err := format.Source([]byte(code)); err == nil {
+        code = string(formatted)
+    }
+
+    useGoMetrics(&register, router, goMetricsPackage + ".Middleware")
+    register.Imports = append(register.Imports, strconv.Quote(path.Join(opts.ModulePath, goMetricsPackage)))
+
+    return []FileChange{
+        {
+            Path:    opts.path(path.Join(goMetricsPackage, goMetricsPackage+".go")),
+            Action:  "create",
+            Content: code,
+        },
+        register,
+    }
+}
 
-// Add to main() after router creation:
+// useGoMetrics registers the metrics middleware named middleware with the
+// router, or wraps the served handler in it
+func useGoMetrics(register *FileChange, router goRouter, middleware string) {
+    if router.UseMetrics != "" {
+        register.Content = fmt.Sprintf(`
+// Record request metrics
+%s
+`, strings.ReplaceAll(router.UseMetrics, middlewarePlaceholder, middleware))
+    }
+    if router.WrapMetrics != "" {
+        register.WrapHandler = strings.ReplaceAll(router.WrapMetrics, middlewarePlaceholder, middleware)
+    }
+}
+
+func generateGoMetricsEndpoint(entry string, router goRouter) FileChange {
+    code := fmt.Sprintf(`
 // Expose Prometheus metrics endpoint
-router.GET("/metrics", gin.WrapH(promhttp.Handler()))
-`
+%s
+`, router.Metrics)
+
+    imports := []string{`"github.com/prometheus/client_golang/prometheus/promhttp"`}
+    if strings.HasPrefix(router.Metrics, "http.") {
+        imports = append(imports, `"net/http"`)
+    }
 
     return FileChange{
-        Path:      "main.go",
-        Action:    "append",
+        Path:      entry,
+        Action:    "modify",
         Content:   code,
-        LineAfter: "import (",
+        LineAfter: router.Anchor,
+        Imports:   imports,
     }
 }
+
+// grpcServerAnchor is the call that constructs a gRPC server
+const grpcServerAnchor = "grpc.NewServer("
+
+// generateGoGRPCTracing chains the otelgrpc interceptors onto the gRPC
+// server
+func generateGoGRPCTracing(file string) FileChange {
+    return FileChange{
+        Path:      file,
+        Action:    "modify",
+        LineAfter: grpcServerAnchor,
+        CallArgs: []string{
+            "grpc.ChainUnaryInterceptor(otelgrpc.UnaryServerInterceptor())",
+            "grpc.ChainStreamInterceptor(otelgrpc.StreamServerInterceptor())",
+        },
+        Imports: []string{
+            `"google.golang.org/grpc"`,
+            `"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"`,
+        },
+    }
+}
+
+// generateGoGRPCMetrics chains the go-grpc-prometheus interceptors onto the
+// gRPC server. A non-zero port also serves /metrics on a listener of its
+// own, since the gRPC server can't route HTTP.
+func generateGoGRPCMetrics(file string, port int) FileChange {
+    change := FileChange{
+        Path:      file,
+        Action:    "modify",
+        LineAfter: grpcServerAnchor,
+        CallArgs: []string{
+            "grpc.ChainUnaryInterceptor(grpc_prometheus.UnaryServerInterceptor)",
+            "grpc.ChainStreamInterceptor(grpc_prometheus.StreamServerInterceptor)",
+        },
+        Imports: []string{
+            `"google.golang.org/grpc"`,
+            `grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"`,
+        },
+    }
+    if port != 0 {
+        change.Content = fmt.Sprintf(`
+// Serve Prometheus metrics on a separate listener
+go func() {
+    mux := http.NewServeMux()
+    mux.Handle("/metrics", promhttp.Handler())
+    if err := http.ListenAndServe(":%d", mux); err != nil {
+        log.Printf("Metrics listener stopped: %%v", err)
+    }
+}()
+`, port)
+        change.Imports = append(change.Imports,
+            `"log"`,
+            `"net/http"`,
+            `"github.com/prometheus/client_golang/prometheus/promhttp"`,
+        )
+    }
+    return change
+}
+
+// goSlogLoggerDecl matches a logger declared from slog.New, capturing its
+// variable
+var goSlogLoggerDecl = regexp.MustCompile(`^(\w+)\s*:=\s*slog\.New\(`)
+
+// goTraceLogHandler wraps a slog handler, adding the trace_id and span_id
+// of the span in each record's context
+const goTraceLogHandler = `
+// traceLogHandler adds the trace_id and span_id of the active span to log
+// records, so logs written with slog's *Context methods (InfoContext,
+// ErrorContext, ...) can be joined with their traces
+type traceLogHandler struct {
+    slog.Handler
+}
+
+func (h traceLogHandler) Handle(ctx context.Context, record slog.Record) error {
+    if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+        record.AddAttrs(
+            slog.String("trace_id", sc.TraceID().String()),
+            slog.String("span_id", sc.SpanID().String()),
+        )
+    }
+    return h.Handler.Handle(ctx, record)
+}
+
+func (h traceLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    return traceLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceLogHandler) WithGroup(name string) slog.Handler {
+    return traceLogHandler{h.Handler.WithGroup(name)}
+}
+`
+
+// goZapTraceLogger adds the trace_id and span_id of the span in a context
+// to a zap logger, which has no context of its own to read them from
+const goZapTraceLogger = `
+// withTraceContext returns logger with the trace_id and span_id of the
+// span in ctx, so its entries can be joined with their traces, e.g.
+// withTraceContext(r.Context(), logger).Info("order placed")
+func withTraceContext(ctx context.Context, logger *zap.Logger) *zap.Logger {
+    sc := trace.SpanContextFromContext(ctx)
+    if !sc.IsValid() {
+        return logger
+    }
+    return logger.With(
+        zap.String("trace_id", sc.TraceID().String()),
+        zap.String("span_id", sc.SpanID().String()),
+    )
+}
+`
+
+// generateGoLogCorrelation correlates a Go service's logs with its traces.
+// A slog logger is wrapped in a handler adding the active span's IDs right
+// after it is set up, or the default logger at the top of main() when it
+// is only declared at package level. Zap entries carry no context, so zap
+// gets a helper adding the IDs to a logger for a request's context.
+func generateGoLogCorrelation(logs scanner.Candidate, candidates []scanner.Candidate, opts Options) ([]FileChange, string) {
+    gomod := opts.path("go.mod")
+    if logs.Manifest != "" {
+        gomod = logs.Manifest
+    }
+    changes := []FileChange{{
+        Path:    gomod,
+        Action:  "append",
+        Content: "\nrequire go.opentelemetry.io/otel/trace v1.21.0",
+    }}
+    imports := []string{`"context"`, `"go.opentelemetry.io/otel/trace"`}
+
+    if logs.Framework == "zap" {
+        changes = append(changes, FileChange{
+            Path:    logs.Files[0],
+            Action:  "append",
+            Content: goZapTraceLogger,
+            Imports: append(imports, `"go.uber.org/zap"`),
+        })
+        return changes, "zap loggers get trace IDs through withTraceContext(ctx, logger)"
+    }
+
+    // The default logger is wrapped at the top of main() unless a logger
+    // is set up in a function
+    file, anchor := opts.path("main.go"), ""
+    if c, ok := findCandidate(candidates, "http"); ok {
+        file = c.Files[0]
+    } else if c, ok := findCandidate(candidates, "grpc"); ok {
+        file = c.Files[0]
+    }
+    wrap := "slog.SetDefault(slog.New(traceLogHandler{slog.Default().Handler()}))"
+    for _, m := range logs.Matches {
+        if decl := goSlogLoggerDecl.FindStringSubmatch(m.Text); decl != nil {
+            file, anchor = m.File, m.Text
+            wrap = fmt.Sprintf("%s = slog.New(traceLogHandler{%s.Handler()})", decl[1], decl[1])
+            break
+        }
+        if anchor == "" && strings.HasPrefix(m.Text, "slog.SetDefault(") {
+            file, anchor = m.File, m.Text
+        }
+    }
+
+    changes = append(changes,
+        FileChange{
+            Path:    file,
+            Action:  "append",
+            Content: goTraceLogHandler,
+            Imports: append(imports, `"log/slog"`),
+        },
+        FileChange{
+            Path:   file,
+            Action: "modify",
+            Content: fmt.Sprintf(`
+// Add the active span's trace_id and span_id to log records
+%s
+`, wrap),
+            LineAfter: anchor,
+            Imports:   []string{`"log/slog"`},
+        },
+    )
+    return changes, "slog records written with a context carry its trace_id and span_id"
+}
@@ -0,0 +1,118 @@
+package generator
+
+import (
+    "encoding/json"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// grafanaDashboardFile is the name of the generated dashboard
+const grafanaDashboardFile = "grafana-dashboard.json"
+
+// httpMetrics are the Prometheus names of a framework's request metrics
+type httpMetrics struct {
+    Requests    string // counter of requests
+    Duration    string // histogram of request durations, without _bucket
+    StatusLabel string // label holding the response status code
+}
+
+// frameworkHTTPMetrics returns the request metrics the generated code
+// exposes. Java, .NET and Ruby use their platform's built-in HTTP server
+// metrics rather than the http_requests_total pair the other generators
+// register under the metrics namespace.
+func frameworkHTTPMetrics(framework string, opts Options) httpMetrics {
+    switch framework {
+    case "Java":
+        return httpMetrics{"http_server_requests_seconds_count", "http_server_requests_seconds", "status"}
+    case ".NET":
+        return httpMetrics{"http_server_request_duration_seconds_count", "http_server_request_duration_seconds", "http_response_status_code"}
+    case "Ruby":
+        return httpMetrics{"http_server_requests_total", "http_server_request_duration_seconds", "code"}
+    default:
+        return httpMetrics{opts.metricName("http_requests_total"), opts.metricName("http_request_duration_seconds"), "status"}
+    }
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// generateGrafanaDashboard creates a starter Grafana dashboard with request
+// rate, error rate and latency percentile panels for the service. Series are
+// selected by the Prometheus job, which defaults to the service name as in
+// the generated scrape config.
+func generateGrafanaDashboard(framework, service string, opts Options) (FileChange, error) {
+    m := frameworkHTTPMetrics(framework, opts)
+    sel := `job="$job"`
+
+    latency := []map[string]interface{}{}
+    for i, p := range []int{50, 95, 99} {
+        latency = append(latency, map[string]interface{}{
+            "refId":        string(rune('A' + i)),
+            "expr":         fmt.Sprintf(`histogram_quantile(0.%d, sum by (le) (rate(%s_bucket{%s}[5m])))`, p, m.Duration, sel),
+            "legendFormat": fmt.Sprintf("p%d", p),
+        })
+    }
+
+    panels := []map[string]interface{}{
+        grafanaPanel(1, "Request rate", "reqps", 0, []map[string]interface{}{{
+            "refId":        "A",
+            "expr":         fmt.Sprintf(`sum by (method) (rate(%s{%s}[5m]))`, m.Requests, sel),
+            "legendFormat": "{{method}}",
+        }}),
+        grafanaPanel(2, "Error rate", "percentunit", 12, []map[string]interface{}{{
+            "refId":        "A",
+            "expr":         fmt.Sprintf(`sum(rate(%s{%s,%s=~"5.."}[5m])) / sum(rate(%s{%s}[5m]))`, m.Requests, sel, m.StatusLabel, m.Requests, sel),
+            "legendFormat": "5xx",
+        }}),
+        grafanaPanel(3, "Latency", "s", 0, latency),
+    }
+    panels[2]["gridPos"] = map[string]int{"h": 8, "w": 24, "x": 0, "y": 8}
+
+    dashboard := map[string]interface{}{
+        "title":         service + " HTTP",
+        "uid":           strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(service), "-"), "-") + "-http",
+        "tags":          []string{"observability-copilot"},
+        "schemaVersion": 39,
+        "time":          map[string]string{"from": "now-1h", "to": "now"},
+        "refresh":       "30s",
+        "templating": map[string]interface{}{
+            "list": []map[string]interface{}{{
+                "name":  "job",
+                "label": "Prometheus job",
+                "type":  "textbox",
+                "query": service,
+                "current": map[string]string{
+                    "text":  service,
+                    "value": service,
+                },
+            }},
+        },
+        "panels": panels,
+    }
+
+    content, err := json.MarshalIndent(dashboard, "", "  ")
+    if err != nil {
+        return FileChange{}, err
+    }
+    return FileChange{
+        Path:    opts.path(grafanaDashboardFile),
+        Action:  "create",
+        Content: string(content) + "\n",
+    }, nil
+}
+
+// grafanaPanel returns a half-width time series panel in the first row
+func grafanaPanel(id int, title, unit string, x int, targets []map[string]interface{}) map[string]interface{} {
+    return map[string]interface{}{
+        "id":         id,
+        "type":       "timeseries",
+        "title":      title,
+        "datasource": map[string]string{"type": "prometheus"},
+        "gridPos":    map[string]int{"h": 8, "w": 12, "x": x, "y": 0},
+        "fieldConfig": map[string]interface{}{
+            "defaults":  map[string]string{"unit": unit},
+            "overrides": []interface{}{},
+        },
+        "targets": targets,
+    }
+}
@@ -1,8 +1,73 @@
 package generator
 
-import "fmt"
+import (
+    "fmt"
+    "strings"
 
-func generateJavaInstrumentation(service, mode string) (*InstrumentationPlan, error) {
+    "observability-copilot/pkg/scanner"
+)
+
+// javaPropertiesFile is where Quarkus reads its configuration from
+const javaPropertiesFile = "src/main/resources/application.properties"
+
+// javaOTelPropertiesFile is where the Spring Boot starter's OpenTelemetry
+// configuration is generated
+const javaOTelPropertiesFile = "src/main/resources/application-otel.properties"
+
+// javaYAMLFile is where Micronaut reads its configuration from
+const javaYAMLFile = "src/main/resources/application.yml"
+
+// javaDependency is a Maven coordinate. An empty Version leaves it to the
+// framework's BOM or plugin.
+type javaDependency struct {
+    Group, Artifact, Version string
+}
+
+// javaBuildFile returns the build file the scan found, defaulting to pom.xml
+func javaBuildFile(candidates []scanner.Candidate, opts Options) string {
+    for _, c := range candidates {
+        if c.Kind == "http" && c.Manifest != "" {
+            return c.Manifest
+        }
+    }
+    return opts.path("pom.xml")
+}
+
+// javaDependencyChange adds deps to the build: as <dependency> elements
+// after <dependencies> in a pom.xml, or as implementation lines after
+// "dependencies {" in a Gradle build
+func javaDependencyChange(build, comment string, deps []javaDependency) FileChange {
+    var b strings.Builder
+    if strings.HasSuffix(build, ".gradle") || strings.HasSuffix(build, ".gradle.kts") {
+        fmt.Fprintf(&b, "\n    // %s\n", comment)
+        for _, d := range deps {
+            coord := d.Group + ":" + d.Artifact
+            if d.Version != "" {
+                coord += ":" + d.Version
+            }
+            if strings.HasSuffix(build, ".kts") {
+                fmt.Fprintf(&b, "    implementation(\"%s\")\n", coord)
+            } else {
+                fmt.Fprintf(&b, "    implementation '%s'\n", coord)
+            }
+        }
+        return FileChange{Path: build, Action: "modify", Content: b.String(), LineAfter: "dependencies {"}
+    }
+
+    fmt.Fprintf(&b, "\n<!-- %s -->", comment)
+    for _, d := range deps {
+        fmt.Fprintf(&b, "\n<dependency>\n    <groupId>%s</groupId>\n    <artifactId>%s</artifactId>", d.Group, d.Artifact)
+        if d.Version != "" {
+            fmt.Fprintf(&b, "\n    <version>%s</version>", d.Version)
+        }
+        b.WriteString("\n</dependency>")
+    }
+    // Applying the change puts it in the project's own <dependencies>,
+    // not the one inside <dependencyManagement>
+    return FileChange{Path: build, Action: "modify", Content: b.String(), LineAfter: "<dependencies>"}
+}
+
+func generateJavaInstrumentation(service, mode string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error) {
     plan := &InstrumentationPlan{
         Framework:   "Java",
         Service:     service,
@@ -10,64 +75,41 @@ func generateJavaInstrumentation(service, mode string) (*InstrumentationPlan, er
         Description: fmt.Sprintf("Add OpenTelemetry instrumentation for %s (mode: %s)", service, mode),
     }
 
-    // Add dependencies to pom.xml
+    build := javaBuildFile(candidates, opts)
+    switch opts.WebFramework {
+    case "Quarkus":
+        plan.Changes = generateQuarkusChanges(service, mode, build, opts)
+        return plan, nil
+    case "Micronaut":
+        plan.Changes = generateMicronautChanges(service, mode, build, opts)
+        return plan, nil
+    }
+
+    // Add dependencies to the Maven or Gradle build
     if mode == "traces" || mode == "both" {
-        plan.Changes = append(plan.Changes, FileChange{
-            Path:   "pom.xml",
-            Action: "append",
-            Content: `
-<!-- OpenTelemetry dependencies -->
-<dependency>
-    <groupId>io.opentelemetry</groupId>
-    <artifactId>opentelemetry-api</artifactId>
-    <version>1.32.0</version>
-</dependency>
-<dependency>
-    <groupId>io.opentelemetry</groupId>
-    <artifactId>opentelemetry-sdk</artifactId>
-    <version>1.32.0</version>
-</dependency>
-<dependency>
-    <groupId>io.opentelemetry</groupId>
-    <artifactId>opentelemetry-exporter-otlp</artifactId>
-    <version>1.32.0</version>
-</dependency>
-<dependency>
-    <groupId>io.opentelemetry.instrumentation</groupId>
-    <artifactId>opentelemetry-spring-boot-starter</artifactId>
-    <version>2.0.0</version>
-</dependency>`,
-            LineAfter: "<dependencies>",
-        })
+        plan.Changes = append(plan.Changes, javaDependencyChange(build, "OpenTelemetry dependencies", []javaDependency{
+            {"io.opentelemetry", "opentelemetry-api", "1.32.0"},
+            {"io.opentelemetry", "opentelemetry-sdk", "1.32.0"},
+            {"io.opentelemetry", "opentelemetry-exporter-otlp", "1.32.0"},
+            {"io.opentelemetry.instrumentation", "opentelemetry-spring-boot-starter", "2.0.0"},
+        }))
 
-        plan.Changes = append(plan.Changes, generateJavaTracerConfig(service))
+        plan.Changes = append(plan.Changes, generateJavaTracerConfig(service, opts))
     }
 
     if mode == "metrics" || mode == "both" {
-        plan.Changes = append(plan.Changes, FileChange{
-            Path:   "pom.xml",
-            Action: "append",
-            Content: `
-<!-- Micrometer Prometheus dependencies -->
-<dependency>
-    <groupId>io.micrometer</groupId>
-    <artifactId>micrometer-registry-prometheus</artifactId>
-    <version>1.12.0</version>
-</dependency>
-<dependency>
-    <groupId>org.springframework.boot</groupId>
-    <artifactId>spring-boot-starter-actuator</artifactId>
-</dependency>`,
-            LineAfter: "<dependencies>",
-        })
+        plan.Changes = append(plan.Changes, javaDependencyChange(build, "Micrometer Prometheus dependencies", []javaDependency{
+            {"io.micrometer", "micrometer-registry-prometheus", "1.12.0"},
+            {"org.springframework.boot", "spring-boot-starter-actuator", ""},
+        }))
 
-        plan.Changes = append(plan.Changes, generateJavaMetricsConfig(service))
+        plan.Changes = append(plan.Changes, generateJavaMetricsConfig(service, opts))
     }
 
     return plan, nil
 }
 
-func generateJavaTracerConfig(service string) FileChange {
+func generateJavaTracerConfig(service string, opts Options) FileChange {
     code := fmt.Sprintf(`# OpenTelemetry Configuration
 # Add to src/main/resources/application.properties
 
@@ -76,7 +118,7 @@ otel.service.name=%s
 
 # OTLP exporter configuration
 otel.traces.exporter=otlp
-otel.exporter.otlp.endpoint=http://otel-collector.observability.svc.cluster.local:4317
+otel.exporter.otlp.endpoint=%s
 otel.exporter.otlp.protocol=grpc
 
 # Enable auto-instrumentation
@@ -84,21 +126,22 @@ otel.instrumentation.spring-boot.enabled=true
 otel.instrumentation.spring-webmvc.enabled=true
 otel.instrumentation.spring-web.enabled=true
 
-# Sampling (always on for development, adjust for production)
-otel.traces.sampler=always_on
+# Sampling (ratio of traces kept, honouring the parent's decision)
+otel.traces.sampler=parentbased_traceidratio
+otel.traces.sampler.arg=%g
 
 # Log level
 logging.level.io.opentelemetry=INFO
-`, service)
+`, service, opts.endpointURL(), opts.samplingRate())
 
     return FileChange{
-        Path:    "src/main/resources/application-otel.properties",
+        Path:    opts.path(javaOTelPropertiesFile),
         Action:  "create",
         Content: code,
     }
 }
 
-func generateJavaMetricsConfig(service string) FileChange {
+func generateJavaMetricsConfig(service string, opts Options) FileChange {
     code := `# Prometheus Metrics Configuration
 # Add to src/main/resources/application.properties
 
@@ -125,8 +168,99 @@ management.endpoints.web.base-path=/actuator
 `
 
     return FileChange{
-        Path:    "src/main/resources/application-metrics.properties",
+        Path:    opts.path("src/main/resources/application-metrics.properties"),
         Action:  "create",
         Content: code,
     }
 }
+
+// generateQuarkusChanges adds the Quarkus OpenTelemetry and Micrometer
+// extensions, whose versions come from the Quarkus BOM, and configures
+// them in application.properties
+func generateQuarkusChanges(service, mode, build string, opts Options) []FileChange {
+    var changes []FileChange
+
+    if mode == "traces" || mode == "both" {
+        changes = append(changes, javaDependencyChange(build, "Quarkus OpenTelemetry extension", []javaDependency{
+            {"io.quarkus", "quarkus-opentelemetry", ""},
+        }))
+
+        changes = append(changes, FileChange{
+            Path:   opts.path(javaPropertiesFile),
+            Action: "append",
+            Content: fmt.Sprintf(`
+# OpenTelemetry Configuration
+quarkus.application.name=%s
+quarkus.otel.exporter.otlp.endpoint=%s
+quarkus.otel.traces.sampler=parentbased_traceidratio
+quarkus.otel.traces.sampler.arg=%g
+`, service, opts.endpointURL(), opts.samplingRate()),
+        })
+    }
+
+    if mode == "metrics" || mode == "both" {
+        changes = append(changes, javaDependencyChange(build, "Quarkus Micrometer Prometheus extension", []javaDependency{
+            {"io.quarkus", "quarkus-micrometer-registry-prometheus", ""},
+        }))
+
+        changes = append(changes, FileChange{
+            Path:   opts.path(javaPropertiesFile),
+            Action: "append",
+            Content: `
+# Prometheus Metrics Configuration (metrics available at /q/metrics)
+quarkus.micrometer.export.prometheus.enabled=true
+quarkus.micrometer.binder.http-server.enabled=true
+`,
+        })
+    }
+
+    return changes
+}
+
+// generateMicronautChanges adds the Micronaut OpenTelemetry tracing and
+// Micrometer Prometheus modules, whose versions come from the Micronaut BOM,
+// and configures them in application.yml. Keys are written flattened so
+// they don't clash with an existing top-level micronaut: block.
+func generateMicronautChanges(service, mode, build string, opts Options) []FileChange {
+    var changes []FileChange
+
+    if mode == "traces" || mode == "both" {
+        changes = append(changes, javaDependencyChange(build, "Micronaut OpenTelemetry tracing", []javaDependency{
+            {"io.micronaut.tracing", "micronaut-tracing-opentelemetry-http", ""},
+        }))
+
+        changes = append(changes, FileChange{
+            Path:   opts.path(javaYAMLFile),
+            Action: "append",
+            Content: fmt.Sprintf(`
+# OpenTelemetry Configuration
+otel.service.name: %s
+otel.traces.exporter: otlp
+otel.exporter.otlp.endpoint: %s
+otel.traces.sampler: parentbased_traceidratio
+otel.traces.sampler.arg: %g
+`, service, opts.endpointURL(), opts.samplingRate()),
+        })
+    }
+
+    if mode == "metrics" || mode == "both" {
+        changes = append(changes, javaDependencyChange(build, "Micronaut Micrometer Prometheus registry", []javaDependency{
+            {"io.micronaut.micrometer", "micronaut-micrometer-registry-prometheus", ""},
+        }))
+
+        changes = append(changes, FileChange{
+            Path:   opts.path(javaYAMLFile),
+            Action: "append",
+            Content: `
+# Prometheus Metrics Configuration (metrics available at /prometheus)
+micronaut.metrics.enabled: true
+micronaut.metrics.binders.web.enabled: true
+micronaut.metrics.export.prometheus.enabled: true
+micronaut.metrics.export.prometheus.descriptions: true
+endpoints.prometheus.sensitive: false
+`,
+        })
+    }
+
+    return changes
+}
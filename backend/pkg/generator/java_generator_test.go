@@ -0,0 +1,102 @@
+package generator
+
+import (
+    "strings"
+    "testing"
+
+    "observability-copilot/pkg/scanner"
+)
+
+func TestGenerateJavaDependencies(t *testing.T) {
+    tests := []struct {
+        name      string
+        manifest  string
+        dir       string
+        wantPath  string
+        wantAfter string
+        wantLines []string
+    }{
+        {
+            name:      "maven",
+            manifest:  "pom.xml",
+            wantPath:  "pom.xml",
+            wantAfter: "<dependencies>",
+            wantLines: []string{
+                "<artifactId>opentelemetry-api</artifactId>",
+                "<artifactId>micrometer-registry-prometheus</artifactId>",
+            },
+        },
+        {
+            name:      "gradle spring boot",
+            manifest:  "build.gradle",
+            wantPath:  "build.gradle",
+            wantAfter: "dependencies {",
+            wantLines: []string{
+                "implementation 'io.opentelemetry:opentelemetry-api:1.32.0'",
+                "implementation 'io.opentelemetry.instrumentation:opentelemetry-spring-boot-starter:2.0.0'",
+                "implementation 'io.micrometer:micrometer-registry-prometheus:1.12.0'",
+                "implementation 'org.springframework.boot:spring-boot-starter-actuator'",
+            },
+        },
+        {
+            name:      "gradle kotlin dsl",
+            manifest:  "build.gradle.kts",
+            wantPath:  "build.gradle.kts",
+            wantAfter: "dependencies {",
+            wantLines: []string{
+                `implementation("io.opentelemetry:opentelemetry-api:1.32.0")`,
+                `implementation("org.springframework.boot:spring-boot-starter-actuator")`,
+            },
+        },
+        {
+            name:      "gradle in a subdirectory",
+            manifest:  "services/orders/build.gradle",
+            dir:       "services/orders",
+            wantPath:  "services/orders/build.gradle",
+            wantAfter: "dependencies {",
+            wantLines: []string{"implementation 'io.opentelemetry:opentelemetry-sdk:1.32.0'"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            candidates := []scanner.Candidate{{
+                Kind:      "http",
+                Framework: "Spring Boot",
+                Manifest:  tt.manifest,
+                Files:     []string{"src/main/java/com/example/Application.java"},
+            }}
+            plan, err := Generate("Java", "orders", "both", candidates, Options{Dir: tt.dir, WebFramework: "Spring Boot"})
+            if err != nil {
+                t.Fatalf("Generate() error = %v", err)
+            }
+
+            var content string
+            for _, change := range plan.Changes {
+                if change.Action != "modify" {
+                    continue
+                }
+                if change.Path != tt.wantPath {
+                    t.Errorf("dependency change path = %q, want %q", change.Path, tt.wantPath)
+                }
+                if change.LineAfter != tt.wantAfter {
+                    t.Errorf("dependency change anchored after %q, want %q", change.LineAfter, tt.wantAfter)
+                }
+                content += change.Content
+            }
+            if content == "" {
+                t.Fatal("plan has no dependency changes")
+            }
+            for _, line := range tt.wantLines {
+                if !strings.Contains(content, line) {
+                    t.Errorf("dependencies missing %q in:\n%s", line, content)
+                }
+            }
+            if strings.HasSuffix(tt.wantPath, "gradle") || strings.HasSuffix(tt.wantPath, ".kts") {
+                if strings.Contains(content, "<dependency>") {
+                    t.Errorf("Gradle build got Maven XML:\n%s", content)
+                }
+            }
+        })
+    }
+}
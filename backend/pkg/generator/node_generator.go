@@ -0,0 +1,482 @@
+package generator
+
+import (
+    "fmt"
+    "path"
+    "regexp"
+    "strings"
+
+    "observability-copilot/pkg/scanner"
+)
+
+func generateNodeInstrumentation(service, mode string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error) {
+    plan := &InstrumentationPlan{
+        Framework:   "Node.js",
+        Service:     service,
+        Mode:        mode,
+        Description: fmt.Sprintf("Add OpenTelemetry instrumentation for %s (mode: %s)", service, mode),
+    }
+
+    // Fall back to a conventional Express entrypoint when the scan found
+    // nothing; Fastify, Koa and Hapi get their own variants below
+    framework := "Express"
+    entry := opts.path("index.js")
+    if c, ok := findCandidate(candidates, "http"); ok {
+        framework = c.Framework
+        entry = c.Files[0]
+    }
+    app := nodeEntrypoint(framework, entry, candidates)
+    dir := path.Dir(app.File)
+
+    // Add dependencies to package.json
+    if mode == "traces" || mode == "both" {
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:   opts.path("package.json"),
+            Action: "modify",
+            Content: `
+    "@opentelemetry/api": "^1.7.0",
+    "@opentelemetry/sdk-node": "^0.45.1",
+    "@opentelemetry/auto-instrumentations-node": "^0.40.0",
+    "@opentelemetry/exporter-trace-otlp-grpc": "^0.45.1",
+    "@opentelemetry/resources": "^1.18.1",
+    "@opentelemetry/semantic-conventions": "^1.18.1",`,
+            LineAfter: `"dependencies": {`,
+        })
+        if framework == "Koa" {
+            plan.Changes = append(plan.Changes, FileChange{
+                Path:   opts.path("package.json"),
+                Action: "modify",
+                Content: `
+    "@opentelemetry/instrumentation-koa": "^0.36.0",`,
+                LineAfter: `"dependencies": {`,
+            })
+        }
+    }
+
+    if mode == "metrics" || mode == "both" {
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:   opts.path("package.json"),
+            Action: "modify",
+            Content: `
+    "prom-client": "^15.0.0",`,
+            LineAfter: `"dependencies": {`,
+        })
+    }
+
+    // Generate instrumentation code
+    if mode == "traces" || mode == "both" {
+        plan.Changes = append(plan.Changes, generateNodeTracer(service, framework, dir, opts))
+        plan.Changes = append(plan.Changes, generateNodeTracerMiddleware(framework, app))
+    }
+
+    if mode == "metrics" || mode == "both" {
+        plan.Changes = append(plan.Changes, generateNodeMetrics(service, framework, dir, opts))
+        plan.Changes = append(plan.Changes, generateNodeMetricsMiddleware(framework, app))
+    }
+
+    return plan, nil
+}
+
+// nodeApp is where the entrypoint changes go: the file and the line they
+// are inserted after, and the app variable they register middleware on
+type nodeApp struct {
+    File   string
+    Anchor string
+    Regexp bool
+    Var    string
+}
+
+// nodeAppDecl matches the declaration the scan found the app constructed
+// in, e.g. `const server = Fastify({`, capturing the app variable
+var nodeAppDecl = regexp.MustCompile(`^(?:export\s+)?(?:(?:const|let|var)\s+)?(\w+)(?:\s*:\s*[\w.<>]+)?\s*=[^=]`)
+
+// nodeEntrypoint finds the statement constructing the app. The changes go
+// after the whole statement, so an app built over several lines with
+// options isn't split. An app the scan didn't see assigned, or Hapi, falls
+// back to the framework's anchor in entry and an app named "app".
+func nodeEntrypoint(framework, entry string, candidates []scanner.Candidate) nodeApp {
+    if c, ok := findCandidate(candidates, "http"); ok && framework != "Hapi" {
+        for _, m := range c.Matches {
+            if decl := nodeAppDecl.FindStringSubmatch(m.Text); decl != nil {
+                return nodeApp{File: m.File, Anchor: m.Text, Var: decl[1]}
+            }
+        }
+    }
+    anchor, isRegexp := nodeAppAnchor(framework)
+    return nodeApp{File: entry, Anchor: anchor, Regexp: isRegexp, Var: "app"}
+}
+
+// appPlaceholder stands for the app variable in the entrypoint snippets
+const appPlaceholder = "{app}"
+
+// change inserts an entrypoint snippet, with the app variable filled in
+func (a nodeApp) change(code string) FileChange {
+    return FileChange{
+        Path:      a.File,
+        Action:    "modify",
+        Content:   strings.ReplaceAll(code, appPlaceholder, a.Var),
+        LineAfter: a.Anchor,
+        Regexp:    a.Regexp,
+    }
+}
+
+// nodeAppAnchor is the line the entrypoint changes are inserted after when
+// the scan didn't find the app's declaration: the line that constructs the
+// app, or for Hapi the line that loads it, since Hapi.server() usually
+// spans several lines
+func nodeAppAnchor(framework string) (anchor string, isRegexp bool) {
+    switch framework {
+    case "Fastify":
+        return "fastify(", false
+    case "Koa":
+        return "new Koa(", false
+    case "Hapi":
+        return `require\(\s*['"]@hapi/hapi['"]\s*\)`, true
+    default:
+        return "express()", false
+    }
+}
+
+func generateNodeTracer(service, framework, dir string, opts Options) FileChange {
+    imports := ""
+    instrumentations := "[getNodeAutoInstrumentations()]"
+    if framework == "Koa" {
+        // Koa middleware and router layers are traced by the standalone
+        // instrumentation-koa package rather than the bundled copy
+        imports = "const { KoaInstrumentation } = require('@opentelemetry/instrumentation-koa');\n"
+        instrumentations = `[
+    getNodeAutoInstrumentations({ '@opentelemetry/instrumentation-koa': { enabled: false } }),
+    new KoaInstrumentation(),
+  ]`
+    }
+
+    code := fmt.Sprintf(`// OpenTelemetry Tracer Initialization
+const { NodeSDK } = require('@opentelemetry/sdk-node');
+const { getNodeAutoInstrumentations } = require('@opentelemetry/auto-instrumentations-node');
+%sconst { OTLPTraceExporter } = require('@opentelemetry/exporter-trace-otlp-grpc');
+const { Resource } = require('@opentelemetry/resources');
+const { SemanticResourceAttributes } = require('@opentelemetry/semantic-conventions');
+const { trace, context, SpanKind, SpanStatusCode } = require('@opentelemetry/api');
+
+const sdk = new NodeSDK({
+  resource: new Resource({
+    [SemanticResourceAttributes.SERVICE_NAME]: '%s',
+  }),
+  traceExporter: new OTLPTraceExporter({
+    url: '%s',
+  }),
+  instrumentations: %s,
+});
+
+sdk.start();
+console.log('✅ OpenTelemetry tracer initialized');
+
+process.on('SIGTERM', () => {
+  sdk.shutdown().finally(() => process.exit(0));
+});
+
+const tracer = trace.getTracer('%s');
+`, imports, service, opts.endpointURL(), instrumentations, service)
+
+    switch framework {
+    case "Fastify":
+        code += `
+// Register span hooks on a Fastify instance
+function registerTracing(app) {
+  app.addHook('onRequest', (request, reply, done) => {
+    request.otelSpan = tracer.startSpan(` + "`${request.method} ${request.routerPath || request.url}`" + `, {
+      kind: SpanKind.SERVER,
+    });
+    done();
+  });
+
+  app.addHook('onResponse', (request, reply, done) => {
+    const span = request.otelSpan;
+    if (span) {
+      span.setAttribute('http.status_code', reply.statusCode);
+      if (reply.statusCode >= 500) {
+        span.setStatus({ code: SpanStatusCode.ERROR });
+      }
+      span.end();
+    }
+    done();
+  });
+}
+
+module.exports = { registerTracing };
+`
+    case "Koa":
+        code += `
+// Koa middleware that wraps each request in a server span
+async function tracingMiddleware(ctx, next) {
+  const span = tracer.startSpan(` + "`${ctx.method} ${ctx.path}`" + `, { kind: SpanKind.SERVER });
+  let status;
+  try {
+    await context.with(trace.setSpan(context.active(), span), next);
+    status = ctx.status;
+  } catch (err) {
+    status = err.status || 500;
+    span.recordException(err);
+    throw err;
+  } finally {
+    span.setAttribute('http.status_code', status);
+    if (status >= 500) {
+      span.setStatus({ code: SpanStatusCode.ERROR });
+    }
+    span.end();
+  }
+}
+
+module.exports = { tracingMiddleware };
+`
+    case "Hapi":
+        code += `
+// Register span hooks on a Hapi server
+function registerTracing(server) {
+  server.ext('onRequest', (request, h) => {
+    request.plugins.otel = {
+      span: tracer.startSpan(` + "`${request.method.toUpperCase()} ${request.path}`" + `, { kind: SpanKind.SERVER }),
+    };
+    return h.continue;
+  });
+
+  server.events.on('response', (request) => {
+    const span = request.plugins.otel && request.plugins.otel.span;
+    if (span) {
+      const response = request.response || {};
+      const status = response.isBoom ? response.output.statusCode : response.statusCode;
+      span.setAttribute('http.status_code', status);
+      if (status >= 500) {
+        span.setStatus({ code: SpanStatusCode.ERROR });
+      }
+      span.end();
+    }
+  });
+}
+
+// Wrap Hapi.server so every server created afterwards is traced
+function instrumentHapi(Hapi) {
+  const createServer = Hapi.server;
+  Hapi.server = (options) => {
+    const server = createServer(options);
+    registerTracing(server);
+    return server;
+  };
+}
+
+module.exports = { registerTracing, instrumentHapi };
+`
+    default:
+        code += `
+// Express middleware that wraps each request in a server span
+function tracingMiddleware(req, res, next) {
+  const span = tracer.startSpan(` + "`${req.method} ${req.path}`" + `, { kind: SpanKind.SERVER });
+  res.on('finish', () => {
+    span.setAttribute('http.status_code', res.statusCode);
+    if (res.statusCode >= 500) {
+      span.setStatus({ code: SpanStatusCode.ERROR });
+    }
+    span.end();
+  });
+  context.with(trace.setSpan(context.active(), span), next);
+}
+
+module.exports = { tracingMiddleware };
+`
+    }
+
+    return FileChange{
+        Path:    path.Join(dir, "tracing.js"),
+        Action:  "create",
+        Content: code,
+    }
+}
+
+func generateNodeTracerMiddleware(framework string, app nodeApp) FileChange {
+    code := `
+// Add OpenTelemetry tracing middleware
+{app}.use(require('./tracing').tracingMiddleware);
+`
+    switch framework {
+    case "Fastify":
+        code = `
+// Add OpenTelemetry tracing hooks
+require('./tracing').registerTracing({app});
+`
+    case "Hapi":
+        code = `
+// Add OpenTelemetry tracing hooks to every Hapi server
+require('./tracing').instrumentHapi(require('@hapi/hapi'));
+`
+    }
+
+    return app.change(code)
+}
+
+func generateNodeMetrics(service, framework, dir string, opts Options) FileChange {
+    // prom-client has no namespace option, so the names carry it
+    code := fmt.Sprintf(`// Prometheus Metrics
+const client = require('prom-client');
+
+const register = new client.Registry();
+client.collectDefaultMetrics({ register });
+
+const httpRequestsTotal = new client.Counter({
+  name: '%s',
+  help: 'Total number of HTTP requests',
+  labelNames: ['method', 'endpoint', 'status'],
+  registers: [register],
+});
+
+const httpRequestDuration = new client.Histogram({
+  name: '%s',
+  help: 'HTTP request duration in seconds',
+  labelNames: ['method', 'endpoint'],
+  registers: [register],
+});
+`, opts.metricName("http_requests_total"), opts.metricName("http_request_duration_seconds"))
+
+    switch framework {
+    case "Fastify":
+        code += `
+// Register metrics hooks and the /metrics route on a Fastify instance
+function registerMetrics(app) {
+  app.addHook('onRequest', (request, reply, done) => {
+    request.metricsStart = process.hrtime.bigint();
+    done();
+  });
+
+  app.addHook('onResponse', (request, reply, done) => {
+    const endpoint = request.routerPath || 'unknown';
+    const duration = Number(process.hrtime.bigint() - request.metricsStart) / 1e9;
+    httpRequestsTotal.labels(request.method, endpoint, String(reply.statusCode)).inc();
+    httpRequestDuration.labels(request.method, endpoint).observe(duration);
+    done();
+  });
+
+  app.get('/metrics', async (request, reply) => {
+    reply.header('Content-Type', register.contentType);
+    return register.metrics();
+  });
+
+  console.log('✅ Prometheus metrics initialized');
+}
+
+module.exports = { register, registerMetrics };
+`
+    case "Koa":
+        code += `
+// Koa middleware that records request count and duration and serves /metrics
+async function metricsMiddleware(ctx, next) {
+  if (ctx.method === 'GET' && ctx.path === '/metrics') {
+    ctx.set('Content-Type', register.contentType);
+    ctx.body = await register.metrics();
+    return;
+  }
+
+  const end = httpRequestDuration.startTimer();
+  let status;
+  try {
+    await next();
+    status = ctx.status;
+  } catch (err) {
+    status = err.status || 500;
+    throw err;
+  } finally {
+    const endpoint = ctx._matchedRoute || 'unknown';
+    httpRequestsTotal.labels(ctx.method, endpoint, String(status)).inc();
+    end({ method: ctx.method, endpoint });
+  }
+}
+
+module.exports = { register, metricsMiddleware };
+`
+    case "Hapi":
+        code += `
+// Register metrics hooks and the /metrics route on a Hapi server
+function registerMetrics(server) {
+  server.events.on('response', (request) => {
+    const response = request.response || {};
+    const status = response.isBoom ? response.output.statusCode : response.statusCode;
+    const endpoint = request.route ? request.route.path : 'unknown';
+    const method = request.method.toUpperCase();
+    httpRequestsTotal.labels(method, endpoint, String(status)).inc();
+    httpRequestDuration.labels(method, endpoint).observe((request.info.responded - request.info.received) / 1000);
+  });
+
+  server.route({
+    method: 'GET',
+    path: '/metrics',
+    handler: async (request, h) => h.response(await register.metrics()).type(register.contentType),
+  });
+}
+
+// Wrap Hapi.server so every server created afterwards is measured
+function instrumentHapi(Hapi) {
+  const createServer = Hapi.server;
+  Hapi.server = (options) => {
+    const server = createServer(options);
+    registerMetrics(server);
+    return server;
+  };
+}
+
+module.exports = { register, registerMetrics, instrumentHapi };
+`
+    default:
+        code += `
+// Express middleware that records request count and duration
+function metricsMiddleware(req, res, next) {
+  const end = httpRequestDuration.startTimer();
+  res.on('finish', () => {
+    const endpoint = req.route ? req.route.path : 'unknown';
+    httpRequestsTotal.labels(req.method, endpoint, String(res.statusCode)).inc();
+    end({ method: req.method, endpoint });
+  });
+  next();
+}
+
+// Expose Prometheus metrics endpoint
+async function metricsHandler(req, res) {
+  res.set('Content-Type', register.contentType);
+  res.end(await register.metrics());
+}
+
+module.exports = { register, metricsMiddleware, metricsHandler };
+`
+    }
+
+    return FileChange{
+        Path:    path.Join(dir, "metrics.js"),
+        Action:  "create",
+        Content: code,
+    }
+}
+
+func generateNodeMetricsMiddleware(framework string, app nodeApp) FileChange {
+    code := `
+// Add Prometheus metrics middleware and endpoint
+const metrics = require('./metrics');
+{app}.use(metrics.metricsMiddleware);
+{app}.get('/metrics', metrics.metricsHandler);
+`
+    switch framework {
+    case "Fastify":
+        code = `
+// Add Prometheus metrics hooks and endpoint
+require('./metrics').registerMetrics({app});
+`
+    case "Koa":
+        code = `
+// Add Prometheus metrics middleware and endpoint
+{app}.use(require('./metrics').metricsMiddleware);
+`
+    case "Hapi":
+        code = `
+// Add Prometheus metrics hooks and endpoint to every Hapi server
+require('./metrics').instrumentHapi(require('@hapi/hapi'));
+`
+    }
+
+    return app.change(code)
+}
@@ -0,0 +1,83 @@
+package generator
+
+import (
+    "fmt"
+
+    "observability-copilot/pkg/scanner"
+)
+
+// prometheusConfigFile is the name of the generated scrape config snippet
+const prometheusConfigFile = "prometheus-scrape.yaml"
+
+// DefaultMetricsPort is the port scraped when neither Options.MetricsPort
+// nor Options.AppPort is set
+const DefaultMetricsPort = 8080
+
+// DefaultGRPCMetricsPort is the port of the metrics listener generated next
+// to a Go gRPC server, when Options.MetricsPort is not set
+const DefaultGRPCMetricsPort = 9464
+
+// grpcMetricsPort is the port the metrics listener of a gRPC-only Go
+// service serves on
+func grpcMetricsPort(opts Options) int {
+    if opts.MetricsPort != 0 {
+        return opts.MetricsPort
+    }
+    return DefaultGRPCMetricsPort
+}
+
+// metricsPath is the route the instrumented service serves metrics on: a
+// route the scan already found, or the one the language's generator adds
+func metricsPath(framework string, candidates []scanner.Candidate, opts Options) string {
+    if c, ok := findCandidate(candidates, "http"); ok && c.MetricsPath != "" {
+        return c.MetricsPath
+    }
+    if framework == "Java" {
+        switch opts.WebFramework {
+        case "Quarkus":
+            return "/q/metrics"
+        case "Micronaut":
+            return "/prometheus"
+        }
+        return "/actuator/prometheus"
+    }
+    return "/metrics"
+}
+
+// generatePrometheusConfig creates a scrape_configs job for the service's
+// metrics endpoint, to merge into the Prometheus config
+func generatePrometheusConfig(framework, service string, candidates []scanner.Candidate, opts Options) FileChange {
+    port := opts.MetricsPort
+    if port == 0 {
+        port = opts.AppPort
+    }
+    if port == 0 {
+        port = DefaultMetricsPort
+    }
+
+    // A Go gRPC service without an HTTP router serves metrics on a
+    // listener of its own rather than the app's port
+    _, hasGRPC := findCandidate(candidates, "grpc")
+    _, hasHTTP := findCandidate(candidates, "http")
+    if framework == "Go" && hasGRPC && !hasHTTP {
+        port = grpcMetricsPort(opts)
+    }
+
+    content := fmt.Sprintf(`# Prometheus scrape job generated by Observability Copilot.
+# Merge into the scrape_configs of your prometheus.yml.
+scrape_configs:
+  - job_name: %s
+    metrics_path: %s
+    scrape_interval: 15s
+    static_configs:
+      - targets: ['%s:%d']
+        labels:
+          service: %s
+`, service, metricsPath(framework, candidates, opts), service, port, service)
+
+    return FileChange{
+        Path:    opts.path(prometheusConfigFile),
+        Action:  "create",
+        Content: content,
+    }
+}
@@ -1,8 +1,17 @@
 package generator
 
-import "fmt"
+import (
+    "fmt"
+    "path"
+    "regexp"
+    "strings"
 
-func generatePythonInstrumentation(service, mode string) (*InstrumentationPlan, error) {
+    "observability-copilot/pkg/scanner"
+)
+
+// generatePythonInstrumentation targets Flask unless framework is "FastAPI"
+// or "Django"
+func generatePythonInstrumentation(service, framework, mode string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error) {
     plan := &InstrumentationPlan{
         Framework:   "Python",
         Service:     service,
@@ -10,24 +19,33 @@ func generatePythonInstrumentation(service, mode string) (*InstrumentationPlan,
         Description: fmt.Sprintf("Add OpenTelemetry instrumentation for %s (mode: %s)", service, mode),
     }
 
+    if framework == "Django" {
+        return generateDjangoInstrumentation(plan, service, mode, candidates, opts)
+    }
+
+    instrumentation := "opentelemetry-instrumentation-flask>=0.41b0"
+    if framework == "FastAPI" {
+        instrumentation = "opentelemetry-instrumentation-fastapi>=0.41b0"
+    }
+
     // Add dependencies to requirements.txt
     if mode == "traces" || mode == "both" {
         plan.Changes = append(plan.Changes, FileChange{
-            Path:   "requirements.txt",
+            Path:   opts.path("requirements.txt"),
             Action: "append",
             Content: `
 # OpenTelemetry dependencies
 opentelemetry-api>=1.20.0
 opentelemetry-sdk>=1.20.0
 opentelemetry-exporter-otlp-proto-grpc>=1.20.0
-opentelemetry-instrumentation-flask>=0.41b0
+` + instrumentation + `
 opentelemetry-instrumentation-requests>=0.41b0`,
         })
     }
 
     if mode == "metrics" || mode == "both" {
         plan.Changes = append(plan.Changes, FileChange{
-            Path:   "requirements.txt",
+            Path:   opts.path("requirements.txt"),
             Action: "append",
             Content: `
 # Prometheus dependencies
@@ -35,19 +53,81 @@ prometheus-client>=0.19.0`,
         })
     }
 
+    // A metrics route the app already has is kept rather than defined
+    // again, which Flask refuses at startup
+    metricsPath := ""
+    if c, ok := findCandidate(candidates, "http"); ok {
+        metricsPath = c.MetricsPath
+    }
+
     // Generate instrumentation code
-    if mode == "traces" || mode == "both" {
-        plan.Changes = append(plan.Changes, generatePythonTracer(service))
+    traces := mode == "traces" || mode == "both"
+    metrics := mode == "metrics" || mode == "both"
+    if framework == "FastAPI" {
+        if traces {
+            plan.Changes = append(plan.Changes, generateFastAPITracer(service, opts))
+        }
+        if metrics {
+            plan.Changes = append(plan.Changes, generateFastAPIMetrics(metricsPath, opts))
+        }
+    } else {
+        if traces {
+            plan.Changes = append(plan.Changes, generatePythonTracer(service, opts))
+        }
+        if metrics {
+            plan.Changes = append(plan.Changes, generatePythonMetrics(service, metricsPath, opts))
+        }
     }
 
-    if mode == "metrics" || mode == "both" {
-        plan.Changes = append(plan.Changes, generatePythonMetrics(service))
+    // Call it on the app. Each call is inserted right below the app's
+    // creation, so the metrics go in first to end up below the tracer.
+    if metrics {
+        plan.Changes = append(plan.Changes, pythonAppCall(candidates, "Prometheus metrics", "metrics_config", "setup_metrics")...)
+    }
+    if traces {
+        plan.Changes = append(plan.Changes, pythonAppCall(candidates, "OpenTelemetry tracing", "otel_config", "init_tracer")...)
     }
 
     return plan, nil
 }
 
-func generatePythonTracer(service string) FileChange {
+// pythonAppDecl matches the line creating the Flask or FastAPI app, e.g.
+// `app = Flask(__name__)`, capturing the app variable
+var pythonAppDecl = regexp.MustCompile(`^(\w+)\s*(?::[^=]*)?=\s*[\w.]*(?:Flask|FastAPI)\(`)
+
+// pythonAppCall imports fn from module and calls it on the app right after
+// the statement creating it. An app not assigned to a variable is left to
+// wire by hand as the generated module describes.
+func pythonAppCall(candidates []scanner.Candidate, comment, module, fn string) []FileChange {
+    c, ok := findCandidate(candidates, "http")
+    if !ok {
+        return nil
+    }
+    for _, m := range c.Matches {
+        decl := pythonAppDecl.FindStringSubmatch(strings.TrimSpace(m.Text))
+        if decl == nil {
+            continue
+        }
+        return []FileChange{{
+            Path:   m.File,
+            Action: "modify",
+            Content: fmt.Sprintf(`
+# %s
+from %s import %s
+%s(%s)
+`, comment, module, fn, fn, decl[1]),
+            LineAfter: m.Text,
+        }}
+    }
+    return nil
+}
+
+func generatePythonTracer(service string, opts Options) FileChange {
+    insecure := "False"
+    if opts.insecure() {
+        insecure = "True"
+    }
+
     code := fmt.Sprintf(`
 # OpenTelemetry Tracer Initialization
 from opentelemetry import trace
@@ -55,57 +135,95 @@ from opentelemetry.sdk.trace import TracerProvider
 from opentelemetry.sdk.trace.export import BatchSpanProcessor
 from opentelemetry.exporter.otlp.proto.grpc.trace_exporter import OTLPSpanExporter
 from opentelemetry.sdk.resources import Resource
+from opentelemetry.sdk.trace.sampling import ParentBasedTraceIdRatio
 from opentelemetry.instrumentation.flask import FlaskInstrumentor
 
-def init_tracer():
-    """Initialize OpenTelemetry tracer"""
+def init_tracer(app):
+    """Initialize OpenTelemetry tracer and instrument the Flask app"""
     resource = Resource.create({"service.name": "%s"})
     
-    tracer_provider = TracerProvider(resource=resource)
+    tracer_provider = TracerProvider(
+        resource=resource,
+        sampler=ParentBasedTraceIdRatio(%g)
+    )
     
     # OTLP exporter
     otlp_exporter = OTLPSpanExporter(
-        endpoint="http://otel-collector.observability.svc.cluster.local:4317",
-        insecure=True
+        endpoint="%s",
+        insecure=%s
     )
     
     tracer_provider.add_span_processor(BatchSpanProcessor(otlp_exporter))
     trace.set_tracer_provider(tracer_provider)
     
-    # Auto-instrument Flask (if using Flask)
-    FlaskInstrumentor().instrument()
+    # Auto-instrument Flask
+    FlaskInstrumentor().instrument_app(app)
     
     print("✅ OpenTelemetry tracer initialized")
 
-# Call this in your main app file before app.run()
-# init_tracer()
-`, service)
+# Called in your main app file after app = Flask(__name__):
+# init_tracer(app)
+`, service, opts.samplingRate(), opts.endpointURL(), insecure)
 
     return FileChange{
-        Path:    "otel_config.py",
+        Path:    opts.path("otel_config.py"),
         Action:  "create",
         Content: code,
     }
 }
 
-func generatePythonMetrics(service string) FileChange {
+// metricArgsPlaceholder stands for the metrics' namespace and subsystem
+// arguments in the Python metric definitions
+const metricArgsPlaceholder = "{metric_args}"
+
+// pythonMetricArgs passes the metrics namespace and subsystem to a
+// prometheus_client metric
+func pythonMetricArgs(opts Options) string {
+    args := ""
+    if opts.MetricsNamespace != "" {
+        args += fmt.Sprintf(",\n    namespace='%s'", opts.MetricsNamespace)
+    }
+    if opts.MetricsSubsystem != "" {
+        args += fmt.Sprintf(",\n    subsystem='%s'", opts.MetricsSubsystem)
+    }
+    return args
+}
+
+// generatePythonMetrics records Flask requests and, unless metricsPath
+// already serves them, exposes the metrics on /metrics
+func generatePythonMetrics(service, metricsPath string, opts Options) FileChange {
+    imports := `from prometheus_client import Counter, Histogram, generate_latest
+from flask import Response, request`
+    route := `
+    @app.route('/metrics')
+    def metrics():
+        """Expose Prometheus metrics endpoint"""
+        return Response(generate_latest(), mimetype='text/plain')
+    `
+    if metricsPath != "" {
+        imports = `from prometheus_client import Counter, Histogram
+from flask import request`
+        route = fmt.Sprintf(`
+    # The app's existing %s route serves these metrics
+    `, metricsPath)
+    }
+
     code := `
 # Prometheus Metrics
-from prometheus_client import Counter, Histogram, start_http_server, generate_latest
-from flask import Response
+` + imports + `
 import time
 
 # Define metrics
 http_requests_total = Counter(
     'http_requests_total',
     'Total HTTP requests',
-    ['method', 'endpoint', 'status']
+    ['method', 'endpoint', 'status']{metric_args}
 )
 
 http_request_duration_seconds = Histogram(
     'http_request_duration_seconds',
     'HTTP request duration',
-    ['method', 'endpoint']
+    ['method', 'endpoint']{metric_args}
 )
 
 def setup_metrics(app):
@@ -130,20 +248,266 @@ def setup_metrics(app):
         ).observe(duration)
         
         return response
+    ` + route + `
+    print("✅ Prometheus metrics initialized")
+
+# Called in your main app file after the app is created:
+# setup_metrics(app)
+`
+
+    return FileChange{
+        Path:    opts.path("metrics_config.py"),
+        Action:  "create",
+        Content: strings.ReplaceAll(code, metricArgsPlaceholder, pythonMetricArgs(opts)),
+    }
+}
+
+func generateFastAPITracer(service string, opts Options) FileChange {
+    insecure := "False"
+    if opts.insecure() {
+        insecure = "True"
+    }
+
+    code := fmt.Sprintf(`
+# OpenTelemetry Tracer Initialization
+from opentelemetry import trace
+from opentelemetry.sdk.trace import TracerProvider
+from opentelemetry.sdk.trace.export import BatchSpanProcessor
+from opentelemetry.exporter.otlp.proto.grpc.trace_exporter import OTLPSpanExporter
+from opentelemetry.sdk.resources import Resource
+from opentelemetry.sdk.trace.sampling import ParentBasedTraceIdRatio
+from opentelemetry.instrumentation.fastapi import FastAPIInstrumentor
+
+def init_tracer(app):
+    """Initialize OpenTelemetry tracer and instrument the FastAPI app"""
+    resource = Resource.create({"service.name": "%s"})
     
-    @app.route('/metrics')
-    def metrics():
-        """Expose Prometheus metrics endpoint"""
-        return Response(generate_latest(), mimetype='text/plain')
+    tracer_provider = TracerProvider(
+        resource=resource,
+        sampler=ParentBasedTraceIdRatio(%g)
+    )
+    
+    # OTLP exporter
+    otlp_exporter = OTLPSpanExporter(
+        endpoint="%s",
+        insecure=%s
+    )
+    
+    tracer_provider.add_span_processor(BatchSpanProcessor(otlp_exporter))
+    trace.set_tracer_provider(tracer_provider)
+    
+    # Auto-instrument FastAPI
+    FastAPIInstrumentor.instrument_app(app)
+    
+    print("✅ OpenTelemetry tracer initialized")
+
+# Called in your main app file after app = FastAPI():
+# init_tracer(app)
+`, service, opts.samplingRate(), opts.endpointURL(), insecure)
+
+    return FileChange{
+        Path:    opts.path("otel_config.py"),
+        Action:  "create",
+        Content: code,
+    }
+}
+
+// generateFastAPIMetrics records FastAPI requests and, unless metricsPath
+// already serves them, mounts the metrics app on /metrics
+func generateFastAPIMetrics(metricsPath string, opts Options) FileChange {
+    imports := "from prometheus_client import Counter, Histogram, make_asgi_app"
+    mount := `
+    # Expose Prometheus metrics endpoint as an ASGI sub-app
+    app.mount("/metrics", make_asgi_app())
+    `
+    if metricsPath != "" {
+        imports = "from prometheus_client import Counter, Histogram"
+        mount = fmt.Sprintf(`
+    # The app's existing %s route serves these metrics
+    `, metricsPath)
+    }
+
+    code := `
+# Prometheus Metrics
+` + imports + `
+import time
+
+# Define metrics
+http_requests_total = Counter(
+    'http_requests_total',
+    'Total HTTP requests',
+    ['method', 'endpoint', 'status']{metric_args}
+)
+
+http_request_duration_seconds = Histogram(
+    'http_request_duration_seconds',
+    'HTTP request duration',
+    ['method', 'endpoint']{metric_args}
+)
+
+def setup_metrics(app):
+    """Setup Prometheus metrics for FastAPI app"""
     
+    @app.middleware("http")
+    async def record_metrics(request, call_next):
+        start_time = time.time()
+        response = await call_next(request)
+        route = request.scope.get("route")
+        endpoint = route.path if route else 'unknown'
+        
+        http_requests_total.labels(
+            method=request.method,
+            endpoint=endpoint,
+            status=response.status_code
+        ).inc()
+        
+        http_request_duration_seconds.labels(
+            method=request.method,
+            endpoint=endpoint
+        ).observe(time.time() - start_time)
+        
+        return response
+    ` + mount + `
     print("✅ Prometheus metrics initialized")
 
-# Call this in your main app file:
+# Called in your main app file after the app is created:
 # setup_metrics(app)
 `
 
     return FileChange{
-        Path:    "metrics_config.py",
+        Path:    opts.path("metrics_config.py"),
+        Action:  "create",
+        Content: strings.ReplaceAll(code, metricArgsPlaceholder, pythonMetricArgs(opts)),
+    }
+}
+
+// generateDjangoInstrumentation hooks tracing and django-prometheus into the
+// project's settings module and the urls.py next to it
+func generateDjangoInstrumentation(plan *InstrumentationPlan, service, mode string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error) {
+    c, ok := findCandidate(candidates, "http")
+    if !ok {
+        return nil, fmt.Errorf("no Django settings module found for %s", service)
+    }
+    settings := c.Files[0]
+    urls := path.Join(path.Dir(settings), "urls.py")
+
+    // Add dependencies to requirements.txt
+    if mode == "traces" || mode == "both" {
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:   opts.path("requirements.txt"),
+            Action: "append",
+            Content: `
+# OpenTelemetry dependencies
+opentelemetry-api>=1.20.0
+opentelemetry-sdk>=1.20.0
+opentelemetry-exporter-otlp-proto-grpc>=1.20.0
+opentelemetry-instrumentation-django>=0.41b0
+opentelemetry-instrumentation-requests>=0.41b0`,
+        })
+    }
+
+    if mode == "metrics" || mode == "both" {
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:   opts.path("requirements.txt"),
+            Action: "append",
+            Content: `
+# Prometheus dependencies
+django-prometheus>=2.3.1`,
+        })
+    }
+
+    if mode == "traces" || mode == "both" {
+        plan.Changes = append(plan.Changes, generateDjangoTracer(service, opts))
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:   settings,
+            Action: "append",
+            Content: `
+
+# Initialize OpenTelemetry tracing
+from otel_config import init_tracer
+init_tracer()
+`,
+        })
+    }
+
+    if mode == "metrics" || mode == "both" {
+        // The Before/After middleware must wrap every other middleware
+        code := `
+
+# Prometheus metrics
+INSTALLED_APPS += ['django_prometheus']
+MIDDLEWARE = (
+    ['django_prometheus.middleware.PrometheusBeforeMiddleware']
+    + list(MIDDLEWARE)
+    + ['django_prometheus.middleware.PrometheusAfterMiddleware']
+)
+`
+        // django-prometheus has a single namespace setting for both
+        if namespace := opts.metricName(""); namespace != "" {
+            code += fmt.Sprintf("PROMETHEUS_METRIC_NAMESPACE = '%s'\n", namespace)
+        }
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:    settings,
+            Action:  "append",
+            Content: code,
+        })
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:   urls,
+            Action: "append",
+            Content: `
+
+# Expose Prometheus metrics endpoint at /metrics
+from django.urls import include, path
+urlpatterns += [path('', include('django_prometheus.urls'))]
+`,
+        })
+    }
+
+    return plan, nil
+}
+
+func generateDjangoTracer(service string, opts Options) FileChange {
+    insecure := "False"
+    if opts.insecure() {
+        insecure = "True"
+    }
+
+    code := fmt.Sprintf(`
+# OpenTelemetry Tracer Initialization
+from opentelemetry import trace
+from opentelemetry.sdk.trace import TracerProvider
+from opentelemetry.sdk.trace.export import BatchSpanProcessor
+from opentelemetry.exporter.otlp.proto.grpc.trace_exporter import OTLPSpanExporter
+from opentelemetry.sdk.resources import Resource
+from opentelemetry.sdk.trace.sampling import ParentBasedTraceIdRatio
+from opentelemetry.instrumentation.django import DjangoInstrumentor
+
+def init_tracer():
+    """Initialize OpenTelemetry tracer and instrument Django"""
+    resource = Resource.create({"service.name": "%s"})
+    
+    tracer_provider = TracerProvider(
+        resource=resource,
+        sampler=ParentBasedTraceIdRatio(%g)
+    )
+    
+    # OTLP exporter
+    otlp_exporter = OTLPSpanExporter(
+        endpoint="%s",
+        insecure=%s
+    )
+    
+    tracer_provider.add_span_processor(BatchSpanProcessor(otlp_exporter))
+    trace.set_tracer_provider(tracer_provider)
+    
+    # Auto-instrument Django requests
+    DjangoInstrumentor().instrument()
+    
+    print("✅ OpenTelemetry tracer initialized")
+`, service, opts.samplingRate(), opts.endpointURL(), insecure)
+
+    return FileChange{
+        Path:    opts.path("otel_config.py"),
         Action:  "create",
         Content: code,
     }
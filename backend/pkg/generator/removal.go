@@ -0,0 +1,78 @@
+package generator
+
+import (
+    "fmt"
+    "path"
+
+    "observability-copilot/pkg/scanner"
+)
+
+// generateRemoval plans the removal of the instrumentation the generator
+// would add to the service. Each change carries the generated code, so
+// applying it only removes code that still matches what the tool wrote:
+// "delete" removes a generated file whose content is unchanged, "remove"
+// cuts a generated snippet out of a file and lines a "replace" swapped are
+// swapped back. Hand-written instrumentation doesn't match and is left
+// alone. go.mod is not touched; go mod tidy drops the requirements nothing
+// imports any more.
+func generateRemoval(framework, service string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error) {
+    // The scan of an instrumented Go service finds the generated metrics
+    // route and would have it reused, leaving it out of the plan. Without
+    // it the route is planned, and only removed if it matches exactly.
+    if framework == "Go" {
+        candidates = withoutMetricsPath(candidates)
+    }
+    generated, err := generateFor(framework, service, "both", candidates, opts)
+    if err != nil {
+        return nil, err
+    }
+    dashboard, err := generateGrafanaDashboard(framework, service, opts)
+    if err != nil {
+        return nil, err
+    }
+    // Query tracing comes out first, so a generated config file it appended
+    // to is back to what was generated when that is deleted
+    databaseChanges, _ := generateDatabaseTracing(framework, candidates, opts)
+    generated.Changes = append(databaseChanges, generated.Changes...)
+    logChanges, _ := generateLogCorrelation(framework, candidates, opts)
+    generated.Changes = append(generated.Changes, logChanges...)
+    generated.Changes = append(generated.Changes,
+        generateCollectorConfig(opts),
+        generatePrometheusConfig(framework, service, candidates, opts),
+        dashboard,
+    )
+
+    plan := &InstrumentationPlan{
+        Framework:   framework,
+        Service:     service,
+        Mode:        "none",
+        Changes:     []FileChange{},
+        Description: fmt.Sprintf("Remove the observability instrumentation generated for %s", service),
+    }
+    for _, change := range generated.Changes {
+        switch {
+        case path.Base(change.Path) == "go.mod":
+            continue
+        case change.Action == "create":
+            change.Action = "delete"
+        case change.Action == "replace":
+            // Swapped lines are swapped back
+            change.MatchLine, change.Content = change.Content, change.MatchLine
+            change.Imports, change.ReplacedImports = change.ReplacedImports, change.Imports
+        default:
+            change.Action = "remove"
+        }
+        plan.Changes = append(plan.Changes, change)
+    }
+    return plan, nil
+}
+
+// withoutMetricsPath copies candidates with no existing metrics route
+func withoutMetricsPath(candidates []scanner.Candidate) []scanner.Candidate {
+    cleared := make([]scanner.Candidate, len(candidates))
+    for i, c := range candidates {
+        c.MetricsPath = ""
+        cleared[i] = c
+    }
+    return cleared
+}
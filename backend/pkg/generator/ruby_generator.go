@@ -0,0 +1,138 @@
+package generator
+
+import (
+    "fmt"
+    "net"
+    "path"
+
+    "observability-copilot/pkg/scanner"
+)
+
+func generateRubyInstrumentation(service, mode string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error) {
+    plan := &InstrumentationPlan{
+        Framework:   "Ruby",
+        Service:     service,
+        Mode:        mode,
+        Description: fmt.Sprintf("Add OpenTelemetry instrumentation for %s (mode: %s)", service, mode),
+    }
+
+    c, ok := findCandidate(candidates, "http")
+    if !ok {
+        return nil, fmt.Errorf("no Rails or Sinatra application found for %s", service)
+    }
+    gemfile := c.Manifest
+    if gemfile == "" {
+        gemfile = opts.path("Gemfile")
+    }
+
+    // Add gems to the Gemfile
+    if mode == "traces" || mode == "both" {
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:   gemfile,
+            Action: "append",
+            Content: `
+# OpenTelemetry
+gem 'opentelemetry-sdk', '~> 1.4'
+gem 'opentelemetry-exporter-otlp', '~> 0.26'
+gem 'opentelemetry-instrumentation-all', '~> 0.60'
+`,
+        })
+    }
+
+    if mode == "metrics" || mode == "both" {
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:   gemfile,
+            Action: "append",
+            Content: `
+# Prometheus metrics
+gem 'prometheus-client', '~> 4.2'
+`,
+        })
+    }
+
+    // Rails loads config/initializers on boot; a Sinatra app requires the
+    // telemetry file right after Sinatra itself
+    initializer := opts.path("config/initializers/observability.rb")
+    if c.Framework == "Sinatra" {
+        app := c.Files[0]
+        initializer = path.Join(path.Dir(app), "telemetry.rb")
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:   app,
+            Action: "modify",
+            Content: `
+require_relative 'telemetry'`,
+            LineAfter: "sinatra",
+        })
+    }
+    plan.Changes = append(plan.Changes, FileChange{
+        Path:    initializer,
+        Action:  "create",
+        Content: generateRubyTelemetry(service, mode, c.Framework, opts),
+    })
+
+    return plan, nil
+}
+
+// rubyTracesEndpoint is the OTLP/HTTP traces URL, the only protocol the
+// Ruby exporter speaks, on the collector host's standard HTTP port
+func (o Options) rubyTracesEndpoint() string {
+    host := o.endpointHost()
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
+    }
+    scheme := "https"
+    if o.insecure() {
+        scheme = "http"
+    }
+    _, httpPort := o.receiverPorts()
+    return fmt.Sprintf("%s://%s/v1/traces", scheme, net.JoinHostPort(host, httpPort))
+}
+
+func generateRubyTelemetry(service, mode, framework string, opts Options) string {
+    code := "# OpenTelemetry and Prometheus setup\n"
+
+    if mode == "traces" || mode == "both" {
+        code += fmt.Sprintf(`
+require 'opentelemetry/sdk'
+require 'opentelemetry/exporter/otlp'
+require 'opentelemetry/instrumentation/all'
+
+OpenTelemetry::SDK.configure do |c|
+  c.service_name = '%s'
+  c.add_span_processor(
+    OpenTelemetry::SDK::Trace::Export::BatchSpanProcessor.new(
+      OpenTelemetry::Exporter::OTLP::Exporter.new(endpoint: '%s')
+    )
+  )
+  c.use_all
+end
+
+# Sampling (ratio of traces kept, honouring the parent's decision)
+OpenTelemetry.tracer_provider.sampler = OpenTelemetry::SDK::Trace::Samplers.parent_based(
+  root: OpenTelemetry::SDK::Trace::Samplers.trace_id_ratio_based(%g)
+)
+
+puts '✅ OpenTelemetry initialized'
+`, service, opts.rubyTracesEndpoint(), opts.samplingRate())
+    }
+
+    if mode == "metrics" || mode == "both" {
+        // Middleware added to Sinatra::Base is inherited by every app
+        app := "Rails.application.config.middleware"
+        requires := ""
+        if framework == "Sinatra" {
+            app = "Sinatra::Base"
+            requires = "require 'sinatra/base'\n"
+        }
+        code += fmt.Sprintf(`
+%srequire 'prometheus/middleware/collector'
+require 'prometheus/middleware/exporter'
+
+# Record request counts and durations, and serve them on /metrics
+%s.use Prometheus::Middleware::Collector
+%s.use Prometheus::Middleware::Exporter
+`, requires, app, app)
+    }
+
+    return code
+}
@@ -0,0 +1,285 @@
+package generator
+
+import (
+    "fmt"
+    "strings"
+
+    "observability-copilot/pkg/scanner"
+)
+
+func generateRustInstrumentation(service, mode string, candidates []scanner.Candidate, opts Options) (*InstrumentationPlan, error) {
+    plan := &InstrumentationPlan{
+        Framework:   "Rust",
+        Service:     service,
+        Mode:        mode,
+        Description: fmt.Sprintf("Add OpenTelemetry instrumentation for %s (mode: %s)", service, mode),
+    }
+
+    c, ok := findCandidate(candidates, "http")
+    if !ok {
+        return nil, fmt.Errorf("no Actix or Axum application found for %s", service)
+    }
+    router := c.Files[0]
+
+    // Add crates to Cargo.toml
+    if mode == "traces" || mode == "both" {
+        deps := `
+opentelemetry = "0.21"
+opentelemetry_sdk = { version = "0.21", features = ["rt-tokio"] }
+opentelemetry-otlp = "0.14"`
+        if c.Framework == "Actix" {
+            deps += `
+actix-web-opentelemetry = "0.16"`
+        }
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:      c.Manifest,
+            Action:    "modify",
+            Content:   deps,
+            LineAfter: "[dependencies]",
+        })
+    }
+
+    if mode == "metrics" || mode == "both" {
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:   c.Manifest,
+            Action: "modify",
+            Content: `
+prometheus = "0.13"
+lazy_static = "1.4"`,
+            LineAfter: "[dependencies]",
+        })
+    }
+
+    // Generate the telemetry module and declare it at the crate root
+    plan.Changes = append(plan.Changes, generateRustTelemetry(service, mode, c.Framework, opts))
+    plan.Changes = append(plan.Changes, FileChange{
+        Path:   opts.path("src/main.rs"),
+        Action: "append",
+        Content: `
+mod telemetry;
+`,
+    })
+
+    if mode == "traces" || mode == "both" {
+        plan.Changes = append(plan.Changes, FileChange{
+            Path:   opts.path("src/main.rs"),
+            Action: "modify",
+            Content: `
+    // Initialize OpenTelemetry tracer
+    let _tracer = crate::telemetry::init_tracer().expect("failed to initialize tracer");`,
+            LineAfter: "fn main",
+        })
+        plan.Changes = append(plan.Changes, generateRustMiddleware(c.Framework, router))
+    }
+
+    if mode == "metrics" || mode == "both" {
+        plan.Changes = append(plan.Changes, generateRustMetricsRoute(c.Framework, router), generateRustMetricsMiddleware(c.Framework, router))
+    }
+
+    return plan, nil
+}
+
+func generateRustTelemetry(service, mode, framework string, opts Options) FileChange {
+    code := "// OpenTelemetry and Prometheus setup\n"
+
+    if mode == "traces" || mode == "both" {
+        code += fmt.Sprintf(`
+use opentelemetry::KeyValue;
+use opentelemetry_otlp::WithExportConfig;
+use opentelemetry_sdk::{runtime, trace, Resource};
+
+/// Installs a global OTLP tracer provider
+pub fn init_tracer() -> Result<trace::Tracer, opentelemetry::trace::TraceError> {
+    let tracer = opentelemetry_otlp::new_pipeline()
+        .tracing()
+        .with_exporter(
+            opentelemetry_otlp::new_exporter()
+                .tonic()
+                .with_endpoint("%s"),
+        )
+        .with_trace_config(
+            trace::config().with_resource(Resource::new(vec![KeyValue::new("service.name", "%s")])),
+        )
+        .install_batch(runtime::Tokio)?;
+
+    println!("✅ OpenTelemetry tracer initialized");
+    Ok(tracer)
+}
+`, opts.endpointURL(), service)
+
+        if framework == "Axum" {
+            code += fmt.Sprintf(`
+/// Axum middleware that wraps each request in a server span
+pub async fn trace_middleware(
+    req: axum::extract::Request,
+    next: axum::middleware::Next,
+) -> axum::response::Response {
+    use opentelemetry::trace::{Span, Tracer};
+
+    let tracer = opentelemetry::global::tracer("%s");
+    let mut span = tracer.start(format!("{} {}", req.method(), req.uri().path()));
+    let response = next.run(req).await;
+    span.set_attribute(KeyValue::new("http.status_code", response.status().as_u16() as i64));
+    span.end();
+    response
+}
+`, service)
+        }
+    }
+
+    if mode == "metrics" || mode == "both" {
+        code += fmt.Sprintf(`
+use lazy_static::lazy_static;
+use prometheus::{register_histogram_vec, register_int_counter_vec, Encoder, HistogramVec, IntCounterVec, TextEncoder};
+
+lazy_static! {
+    pub static ref HTTP_REQUESTS_TOTAL: IntCounterVec = register_int_counter_vec!(
+        "%s",
+        "Total number of HTTP requests",
+        &["method", "endpoint", "status"]
+    )
+    .unwrap();
+    pub static ref HTTP_REQUEST_DURATION: HistogramVec = register_histogram_vec!(
+        "%s",
+        "HTTP request duration in seconds",
+        &["method", "endpoint"]
+    )
+    .unwrap();
+}
+
+/// Records a finished request in the HTTP metrics
+fn record_request(method: &str, endpoint: &str, status: u16, start: std::time::Instant) {
+    HTTP_REQUESTS_TOTAL
+        .with_label_values(&[method, endpoint, &status.to_string()])
+        .inc();
+    HTTP_REQUEST_DURATION
+        .with_label_values(&[method, endpoint])
+        .observe(start.elapsed().as_secs_f64());
+}
+
+fn render_metrics() -> (String, Vec<u8>) {
+    let encoder = TextEncoder::new();
+    let mut buffer = Vec::new();
+    encoder.encode(&prometheus::gather(), &mut buffer).unwrap_or_default();
+    (encoder.format_type().to_string(), buffer)
+}
+`, opts.metricName("http_requests_total"), opts.metricName("http_request_duration_seconds"))
+
+        if framework == "Actix" {
+            code += `
+/// Prometheus metrics endpoint
+pub async fn metrics_handler() -> actix_web::HttpResponse {
+    let (content_type, body) = render_metrics();
+    actix_web::HttpResponse::Ok().content_type(content_type).body(body)
+}
+
+/// Actix middleware, for App::wrap_fn, recording the count and duration of
+/// every request under its route pattern
+pub fn record_metrics<S, B>(
+    req: actix_web::dev::ServiceRequest,
+    srv: &S,
+) -> impl std::future::Future<Output = Result<actix_web::dev::ServiceResponse<B>, actix_web::Error>>
+where
+    S: actix_web::dev::Service<
+        actix_web::dev::ServiceRequest,
+        Response = actix_web::dev::ServiceResponse<B>,
+        Error = actix_web::Error,
+    >,
+{
+    let method = req.method().to_string();
+    let start = std::time::Instant::now();
+    let response = actix_web::dev::Service::call(srv, req);
+    async move {
+        let response = response.await?;
+        let request = response.request();
+        let endpoint = request
+            .match_pattern()
+            .unwrap_or_else(|| request.path().to_string());
+        record_request(&method, &endpoint, response.status().as_u16(), start);
+        Ok(response)
+    }
+}
+`
+        } else {
+            code += `
+/// Prometheus metrics endpoint
+pub async fn metrics_handler() -> impl axum::response::IntoResponse {
+    let (content_type, body) = render_metrics();
+    ([(axum::http::header::CONTENT_TYPE, content_type)], body)
+}
+
+/// Axum middleware recording the count and duration of every request under
+/// its route template
+pub async fn metrics_middleware(
+    req: axum::extract::Request,
+    next: axum::middleware::Next,
+) -> axum::response::Response {
+    let method = req.method().to_string();
+    let endpoint = req
+        .extensions()
+        .get::<axum::extract::MatchedPath>()
+        .map(|path| path.as_str().to_string())
+        .unwrap_or_else(|| req.uri().path().to_string());
+    let start = std::time::Instant::now();
+    let response = next.run(req).await;
+    record_request(&method, &endpoint, response.status().as_u16(), start);
+    response
+}
+`
+        }
+    }
+
+    return FileChange{
+        Path:    opts.path("src/telemetry.rs"),
+        Action:  "create",
+        Content: code,
+    }
+}
+
+// The builder calls the entrypoint changes are spliced into
+const (
+    actixAppAnchor  = "App::new()"
+    axumRouteAnchor = "Router::new()"
+)
+
+// rustChainCall splices call into the router's builder chain. Actix
+// middleware and routes go right after App::new(); Axum layers only wrap
+// the routes added before them, so they go after the chain's last call.
+func rustChainCall(framework, router, call string) FileChange {
+    change := FileChange{
+        Path:      router,
+        Action:    "modify",
+        Content:   call,
+        LineAfter: actixAppAnchor,
+        Chain:     ChainStart,
+    }
+    if framework != "Actix" {
+        change.LineAfter = axumRouteAnchor
+        if strings.HasPrefix(call, ".layer(") {
+            change.Chain = ChainEnd
+        }
+    }
+    return change
+}
+
+func generateRustMiddleware(framework, router string) FileChange {
+    if framework == "Actix" {
+        return rustChainCall(framework, router, ".wrap(actix_web_opentelemetry::RequestTracing::new())")
+    }
+    return rustChainCall(framework, router, ".layer(axum::middleware::from_fn(crate::telemetry::trace_middleware))")
+}
+
+func generateRustMetricsRoute(framework, router string) FileChange {
+    if framework == "Actix" {
+        return rustChainCall(framework, router, `.route("/metrics", actix_web::web::get().to(crate::telemetry::metrics_handler))`)
+    }
+    return rustChainCall(framework, router, `.route("/metrics", axum::routing::get(crate::telemetry::metrics_handler))`)
+}
+
+// generateRustMetricsMiddleware records every request in the HTTP metrics
+func generateRustMetricsMiddleware(framework, router string) FileChange {
+    if framework == "Actix" {
+        return rustChainCall(framework, router, ".wrap_fn(crate::telemetry::record_metrics)")
+    }
+    return rustChainCall(framework, router, ".layer(axum::middleware::from_fn(crate::telemetry::metrics_middleware))")
+}
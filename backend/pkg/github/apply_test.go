@@ -0,0 +1,160 @@
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"observability-copilot/pkg/generator"
+)
+
+func TestApplyPlanActions(t *testing.T) {
+	const app = "import os\n\napp = Flask(__name__)\n\n# metrics start\nmetrics = PrometheusMetrics(app)\nmetrics.info('app', 'v1')\n# metrics end\n\nif __name__ == '__main__':\n    app.run(debug=True)\n"
+
+	tests := []struct {
+		name    string
+		src     string // app.py before the plan, absent when empty
+		change  generator.FileChange
+		want    string // app.py after the plan, absent when empty
+		wantErr string
+	}{
+		{
+			name:   "delete matched line",
+			src:    app,
+			change: generator.FileChange{Path: "app.py", Action: "delete", MatchLine: "metrics.info("},
+			want:   "import os\n\napp = Flask(__name__)\n\n# metrics start\nmetrics = PrometheusMetrics(app)\n# metrics end\n\nif __name__ == '__main__':\n    app.run(debug=True)\n",
+		},
+		{
+			name:   "delete region through end line",
+			src:    app,
+			change: generator.FileChange{Path: "app.py", Action: "delete", MatchLine: "# metrics start", EndLine: "# metrics end"},
+			want:   "import os\n\napp = Flask(__name__)\n\n\nif __name__ == '__main__':\n    app.run(debug=True)\n",
+		},
+		{
+			name:   "delete regexp match",
+			src:    app,
+			change: generator.FileChange{Path: "app.py", Action: "delete", MatchLine: `^\s+app\.run\(`, Regexp: true},
+			want:   "import os\n\napp = Flask(__name__)\n\n# metrics start\nmetrics = PrometheusMetrics(app)\nmetrics.info('app', 'v1')\n# metrics end\n\nif __name__ == '__main__':\n",
+		},
+		{
+			name:   "delete already gone",
+			src:    app,
+			change: generator.FileChange{Path: "app.py", Action: "delete", MatchLine: "start_http_server("},
+			want:   app,
+		},
+		{
+			name:   "delete line of missing file",
+			change: generator.FileChange{Path: "app.py", Action: "delete", MatchLine: "metrics.info("},
+		},
+		{
+			name:   "delete generated file",
+			src:    "otel = True\n",
+			change: generator.FileChange{Path: "app.py", Action: "delete", Content: "otel = True\n"},
+		},
+		{
+			name:   "delete edited file keeps it",
+			src:    "otel = False\n",
+			change: generator.FileChange{Path: "app.py", Action: "delete", Content: "otel = True\n"},
+			want:   "otel = False\n",
+		},
+		{
+			name:   "replace line keeps indentation",
+			src:    app,
+			change: generator.FileChange{Path: "app.py", Action: "replace", MatchLine: "app.run(", Content: "app.run(host='0.0.0.0')"},
+			want:   "import os\n\napp = Flask(__name__)\n\n# metrics start\nmetrics = PrometheusMetrics(app)\nmetrics.info('app', 'v1')\n# metrics end\n\nif __name__ == '__main__':\n    app.run(host='0.0.0.0')\n",
+		},
+		{
+			name:   "replace region through end line",
+			src:    app,
+			change: generator.FileChange{Path: "app.py", Action: "replace", MatchLine: "# metrics start", EndLine: "# metrics end", Content: "start_http_server(8000)"},
+			want:   "import os\n\napp = Flask(__name__)\n\nstart_http_server(8000)\n\nif __name__ == '__main__':\n    app.run(debug=True)\n",
+		},
+		{
+			name:   "replace already applied",
+			src:    app,
+			change: generator.FileChange{Path: "app.py", Action: "replace", MatchLine: "import os", Content: "metrics = PrometheusMetrics(app)"},
+			want:   app,
+		},
+		{
+			name:    "replace missing line",
+			src:     app,
+			change:  generator.FileChange{Path: "app.py", Action: "replace", MatchLine: "start_http_server(", Content: "pass"},
+			want:    app,
+			wantErr: `line "start_http_server(" not found`,
+		},
+		{
+			name:    "replace missing end line",
+			src:     app,
+			change:  generator.FileChange{Path: "app.py", Action: "replace", MatchLine: "# metrics start", EndLine: "# tracing end", Content: "pass"},
+			want:    app,
+			wantErr: `end line "# tracing end" not found`,
+		},
+		{
+			name:    "replace invalid regexp",
+			src:     app,
+			change:  generator.FileChange{Path: "app.py", Action: "replace", MatchLine: "app.run(", Regexp: true, Content: "pass"},
+			want:    app,
+			wantErr: "invalid line pattern",
+		},
+		{
+			name:   "create",
+			change: generator.FileChange{Path: "app.py", Action: "create", Content: "otel = True\n"},
+			want:   "otel = True\n",
+		},
+		{
+			name:    "create over existing file",
+			src:     app,
+			change:  generator.FileChange{Path: "app.py", Action: "create", Content: "otel = True\n"},
+			want:    app,
+			wantErr: "file already exists",
+		},
+		{
+			name:   "append",
+			src:    "import os\n",
+			change: generator.FileChange{Path: "app.py", Action: "append", Content: "\nprint('ready')\n"},
+			want:   "import os\n\nprint('ready')\n",
+		},
+		{
+			name:   "modify",
+			src:    app,
+			change: generator.FileChange{Path: "app.py", Action: "modify", LineAfter: "app = Flask(", Content: "metrics.init_app(app)"},
+			want:   "import os\n\napp = Flask(__name__)\nmetrics.init_app(app)\n\n# metrics start\nmetrics = PrometheusMetrics(app)\nmetrics.info('app', 'v1')\n# metrics end\n\nif __name__ == '__main__':\n    app.run(debug=True)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.py")
+			if tt.src != "" {
+				if err := os.WriteFile(path, []byte(tt.src), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := applyPlan(dir, &generator.InstrumentationPlan{Changes: []generator.FileChange{tt.change}})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("applyPlan() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("applyPlan() error = %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if tt.want == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("app.py exists with %q, want it absent", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(data); got != tt.want {
+				t.Errorf("app.py =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package github
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"observability-copilot/pkg/generator"
+)
+
+// spliceChain splices a method call into the builder chain anchor starts,
+// e.g. `.route(...)` into `Router::new().route("/", get(root));`. The call
+// goes right after anchor, or after the chain's last call, on a line of its
+// own when the chain spans several lines.
+func spliceChain(filePath, anchor, content, where string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	src := string(data)
+	idx := strings.Index(src, anchor)
+	if idx < 0 {
+		return fmt.Errorf("anchor %q not found", anchor)
+	}
+	start := idx + len(anchor)
+	end := start + chainLength(src[start:])
+	call := strings.TrimSpace(content)
+
+	// A chain continued over several lines gets the call on a line of its
+	// own, indented as the chain's other calls
+	indent := ""
+	if chain := src[start:end]; strings.Contains(chain, "\n") {
+		for _, line := range strings.Split(chain, "\n")[1:] {
+			if strings.TrimSpace(line) != "" {
+				indent = leadingSpace(line)
+				break
+			}
+		}
+	}
+
+	at := end
+	if where == generator.ChainStart {
+		at = start
+		if rest := src[start:]; indent != "" && strings.TrimSpace(rest[:strings.Index(rest, "\n")]) != "" {
+			indent = ""
+		}
+	}
+	if indent != "" {
+		call = "\n" + indent + call
+	}
+	return os.WriteFile(filePath, []byte(src[:at]+call+src[at:]), 0644)
+}
+
+// chainLength returns the length of the method chain at the start of src:
+// up to the semicolon or comma ending it, or the bracket it is nested in,
+// without the whitespace before that
+func chainLength(src string) int {
+	depth, end := 0, 0
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '"':
+			// Skip string literals, which may hold brackets
+			for i++; i < len(src) && src[i] != '"'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth == 0 {
+				return end
+			}
+			depth--
+		case ';', ',':
+			if depth == 0 {
+				return end
+			}
+		}
+		if i < len(src) && !strings.ContainsRune(" \t\r\n", rune(src[i])) {
+			end = i + 1
+		}
+	}
+	return end
+}
+
+// removeChained takes a call spliceChain added back out, with the line
+// break put before it
+func removeChained(filePath string, change generator.FileChange) error {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	src := string(data)
+	call := strings.TrimSpace(change.Content)
+	idx := strings.Index(src, call)
+	if call == "" || idx < 0 {
+		return nil
+	}
+
+	start, end := idx, idx+len(call)
+	lineStart := strings.LastIndex(src[:start], "\n") + 1
+	lineEnd := len(src)
+	if n := strings.Index(src[end:], "\n"); n >= 0 {
+		lineEnd = end + n
+	}
+	if strings.TrimSpace(src[lineStart:start]) == "" && lineStart > 0 {
+		// The call had a line of its own; whatever followed it, such as the
+		// semicolon ending the chain, goes back on the line before
+		start = lineStart - 1
+		if strings.TrimSpace(src[end:lineEnd]) == "" {
+			end = lineEnd
+		}
+	}
+	return os.WriteFile(filePath, []byte(src[:start]+src[end:]), 0644)
+}
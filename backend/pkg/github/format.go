@@ -0,0 +1,79 @@
+package github
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+
+	"observability-copilot/pkg/generator"
+	"observability-copilot/pkg/logging"
+)
+
+// formatter is a command rewriting the files given as arguments in place
+type formatter struct {
+	Command    string
+	Args       []string
+	Extensions []string
+}
+
+// formatters are keyed by plan.Framework and run in order. They pick up
+// the project's own configuration, e.g. black's and isort's settings in
+// pyproject.toml or a .prettierrc, as when developers run them.
+var formatters = map[string][]formatter{
+	"Python": {
+		{Command: "isort", Args: []string{"-q"}, Extensions: []string{".py"}},
+		{Command: "black", Args: []string{"-q"}, Extensions: []string{".py"}},
+	},
+	"Node.js": {
+		{Command: "prettier", Args: []string{"--write"}, Extensions: []string{".js", ".cjs", ".mjs", ".ts", ".cts", ".mts"}},
+	},
+	"Java": {
+		{Command: "google-java-format", Args: []string{"--replace"}, Extensions: []string{".java"}},
+	},
+}
+
+// formatChanges runs the formatters for the plan's language over the files
+// it created in the checkout at dir, when Settings.FormatCode is set. Files
+// of the repo's the plan only inserts into are left alone, so the PR
+// doesn't reformat code it didn't write. Formatters that aren't installed
+// are skipped, and a formatter failing, e.g. on a file it can't parse,
+// leaves the files as they were for validation to report.
+func formatChanges(ctx context.Context, dir string, plan *generator.InstrumentationPlan) {
+	if !settings.FormatCode {
+		return
+	}
+	log := logging.FromContext(ctx)
+	for _, f := range formatters[plan.Framework] {
+		path, err := exec.LookPath(f.Command)
+		if err != nil {
+			continue
+		}
+		files := []string{}
+		for _, ext := range f.Extensions {
+			files = append(files, createdFiles(plan, ext)...)
+		}
+		if len(files) == 0 {
+			continue
+		}
+
+		cmd := exec.CommandContext(ctx, path, append(append([]string{}, f.Args...), files...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			log.Warn("formatter failed, leaving files unformatted", "formatter", f.Command, "error", err, "output", string(out))
+		}
+	}
+}
+
+// createdFiles lists the distinct paths with the given extension that the
+// plan creates
+func createdFiles(plan *generator.InstrumentationPlan, ext string) []string {
+	seen := map[string]bool{}
+	files := []string{}
+	for _, change := range plan.Changes {
+		if change.Action == "create" && strings.HasSuffix(change.Path, ext) && !seen[change.Path] {
+			seen[change.Path] = true
+			files = append(files, change.Path)
+		}
+	}
+	return files
+}
@@ -0,0 +1,345 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"observability-copilot/pkg/scanner"
+)
+
+// Identity used for instrumentation commits unless configured otherwise
+const (
+	botName  = "Observability Copilot Bot"
+	botEmail = "bot@observability-copilot.dev"
+)
+
+// ErrInvalidAuthor is returned when a commit author's name or email isn't
+// usable
+var ErrInvalidAuthor = errors.New("invalid commit author")
+
+// ValidateAuthor checks a commit author: email, when set, must be a bare
+// address such as bot@example.com, and neither may contain angle brackets
+// or line breaks, which would corrupt the commit header
+func ValidateAuthor(name, email string) error {
+	if strings.ContainsAny(name, "<>\n\r") {
+		return fmt.Errorf("%w: name %q", ErrInvalidAuthor, name)
+	}
+	if email == "" {
+		return nil
+	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email || strings.ContainsAny(email, "<>\n\r ") {
+		return fmt.Errorf("%w: email %q is not an address like bot@example.com", ErrInvalidAuthor, email)
+	}
+	return nil
+}
+
+// commitAuthor is who instrumentation commits are attributed to: the
+// given name and email, falling back to the configured ones and then the
+// bot's
+func commitAuthor(name, email string) *object.Signature {
+	author := &object.Signature{Name: botName, Email: botEmail, When: time.Now()}
+	if settings.AuthorName != "" {
+		author.Name = settings.AuthorName
+	}
+	if settings.AuthorEmail != "" {
+		author.Email = settings.AuthorEmail
+	}
+	if name != "" {
+		author.Name = name
+	}
+	if email != "" {
+		author.Email = email
+	}
+	return author
+}
+
+// cloneRepo clones repoURL into dir with baseBranch checked out as a local
+// branch. An empty baseBranch clones the remote's default branch.
+func cloneRepo(provider Provider, repoURL, dir, baseBranch string, shallow bool) (*git.Repository, error) {
+	auth := provider.Auth(repoURL)
+	opts := &git.CloneOptions{URL: repoURL, Auth: auth}
+	if shallow {
+		opts.Depth = 1
+	}
+
+	if baseBranch != "" {
+		exists, err := remoteBranchExists(auth, repoURL, baseBranch)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, &scanner.CloneError{
+				Kind:   scanner.ErrBranchNotFound,
+				URL:    repoURL,
+				Branch: baseBranch,
+				Err:    fmt.Errorf("base branch %q does not exist on the remote", baseBranch),
+			}
+		}
+		opts.ReferenceName = plumbing.NewBranchReferenceName(baseBranch)
+		opts.SingleBranch = true
+	}
+
+	repo, err := scanner.Clone(dir, opts)
+	if err != nil {
+		return nil, fmt.Errorf("git clone failed: %w", err)
+	}
+	return repo, nil
+}
+
+// remoteBranchExists reports whether branch is a head on the remote
+func remoteBranchExists(auth transport.AuthMethod, repoURL, branch string) (bool, error) {
+	refs, err := listRemote(auth, repoURL)
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	name := plumbing.NewBranchReferenceName(branch)
+	for _, ref := range refs {
+		if ref.Name() == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// listRemote returns the remote's refs, like git ls-remote --symref. An
+// empty repo returns transport.ErrEmptyRemoteRepository.
+func listRemote(auth transport.AuthMethod, repoURL string) ([]*plumbing.Reference, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{repoURL},
+	})
+
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil, fmt.Errorf("listing remote branches failed: %w", scanner.ClassifyGitError(err, repoURL, ""))
+	}
+	return refs, err
+}
+
+// defaultBranches remembers each repo URL's default branch
+var defaultBranches = struct {
+	sync.Mutex
+	entries map[string]cachedBranch
+}{entries: map[string]cachedBranch{}}
+
+type cachedBranch struct {
+	name     string
+	resolved time.Time
+}
+
+// defaultBranch returns the branch the remote's HEAD points at, such as
+// "main", "master" or "develop", remembered for DefaultBranchTTL
+func defaultBranch(auth transport.AuthMethod, repoURL string) (string, error) {
+	ttl := settings.DefaultBranchTTL
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+
+	defaultBranches.Lock()
+	cached, ok := defaultBranches.entries[repoURL]
+	defaultBranches.Unlock()
+	if ok && time.Since(cached.resolved) < ttl {
+		return cached.name, nil
+	}
+
+	refs, err := listRemote(auth, repoURL)
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return "", fmt.Errorf("repository %s has no branches", repoURL)
+	}
+	if err != nil {
+		return "", err
+	}
+	name := headBranch(refs)
+	if name == "" {
+		return "", fmt.Errorf("could not tell the default branch of %s; set base_branch", repoURL)
+	}
+
+	defaultBranches.Lock()
+	defaultBranches.entries[repoURL] = cachedBranch{name: name, resolved: time.Now()}
+	defaultBranches.Unlock()
+	return name, nil
+}
+
+// forgetDefaultBranch drops repoURL's remembered default branch, e.g.
+// once it turns out to have been renamed
+func forgetDefaultBranch(repoURL string) {
+	defaultBranches.Lock()
+	delete(defaultBranches.entries, repoURL)
+	defaultBranches.Unlock()
+}
+
+// headBranch returns the branch HEAD points at among refs. Remotes that
+// don't advertise HEAD as a symbolic ref get the branch at HEAD's commit,
+// preferring main and then master when several are.
+func headBranch(refs []*plumbing.Reference) string {
+	var head *plumbing.Reference
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD {
+			head = ref
+		}
+	}
+	if head == nil {
+		return ""
+	}
+	if head.Type() == plumbing.SymbolicReference {
+		if head.Target().IsBranch() {
+			return head.Target().Short()
+		}
+		return ""
+	}
+
+	matches := []string{}
+	for _, ref := range refs {
+		if ref.Name().IsBranch() && ref.Hash() == head.Hash() {
+			matches = append(matches, ref.Name().Short())
+		}
+	}
+	for _, preferred := range []string{"main", "master"} {
+		for _, name := range matches {
+			if name == preferred {
+				return name
+			}
+		}
+	}
+	if len(matches) > 0 {
+		sort.Strings(matches)
+		return matches[0]
+	}
+	return ""
+}
+
+// checkoutNewBranch creates branch at HEAD and checks it out
+func checkoutNewBranch(repo *git.Repository, branch string) error {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	err = wt.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
+		Create: true,
+	})
+	if err != nil {
+		return fmt.Errorf("git checkout failed: %w", err)
+	}
+	return nil
+}
+
+// hasChanges reports whether the worktree differs from HEAD
+func hasChanges(repo *git.Repository) (bool, error) {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return false, err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return false, fmt.Errorf("git status failed: %w", err)
+	}
+	return !status.IsClean(), nil
+}
+
+// commitAll stages every change in the worktree and commits it as author.
+// When commit signing is on the commit is signed, and committed by the
+// key's identity so the host can verify the signature.
+func commitAll(repo *git.Repository, message string, author *object.Signature) (plumbing.Hash, error) {
+	key, err := signingKey()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	committer := author
+	if key != nil {
+		if id := key.PrimaryIdentity(); id != nil && id.UserId.Email != "" {
+			committer = &object.Signature{Name: id.UserId.Name, Email: id.UserId.Email, When: author.When}
+		}
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := wt.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("git add failed: %w", err)
+	}
+
+	hash, err := wt.Commit(message, &git.CommitOptions{
+		Author:    author,
+		Committer: committer,
+		SignKey:   key,
+	})
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("git commit failed: %w", err)
+	}
+	return hash, nil
+}
+
+// pushBranch pushes branch to origin, overwriting the remote branch if force
+func pushBranch(repo *git.Repository, auth transport.AuthMethod, branch string, force bool) error {
+	spec := config.RefSpec(fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch))
+	err := repo.Push(&git.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{spec},
+		Auth:       auth,
+		Force:      force,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("git push failed: %w", err)
+	}
+	return nil
+}
+
+// worktreeDiff commits the worktree locally and returns the unified diff
+// against the checked-out commit along with the changed paths
+func worktreeDiff(repo *git.Repository) (string, []string, error) {
+	files := []string{}
+	changed, err := hasChanges(repo)
+	if err != nil || !changed {
+		return "", files, err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", nil, err
+	}
+	base, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", nil, err
+	}
+
+	hash, err := commitAll(repo, "Preview instrumentation changes", commitAuthor("", ""))
+	if err != nil {
+		return "", nil, err
+	}
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return "", nil, err
+	}
+
+	patch, err := base.Patch(commit)
+	if err != nil {
+		return "", nil, fmt.Errorf("git diff failed: %w", err)
+	}
+	for _, fp := range patch.FilePatches() {
+		from, to := fp.Files()
+		if to != nil {
+			files = append(files, to.Path())
+		} else if from != nil {
+			files = append(files, from.Path())
+		}
+	}
+	return patch.String(), files, nil
+}
@@ -0,0 +1,171 @@
+package github
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"observability-copilot/pkg/scanner"
+)
+
+// newRemote creates a repo whose default branch is main, with a develop
+// branch one commit ahead of it, and returns its path and each branch's
+// commit
+func newRemote(t *testing.T) (string, map[string]plumbing.Hash) {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit := func(file string) plumbing.Hash {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(file+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(file); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := wt.Commit("add "+file, &git.CommitOptions{
+			Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	heads := map[string]plumbing.Hash{"main": commit("README.md")}
+	err = wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("develop"), Create: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	heads["develop"] = commit("CHANGELOG.md")
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("main")}); err != nil {
+		t.Fatal(err)
+	}
+	return dir, heads
+}
+
+func TestCloneRepo(t *testing.T) {
+	remote, heads := newRemote(t)
+
+	tests := []struct {
+		name       string
+		baseBranch string
+		wantBranch string
+		wantErr    error
+	}{
+		{name: "default branch", baseBranch: "", wantBranch: "main"},
+		{name: "main base branch", baseBranch: "main", wantBranch: "main"},
+		{name: "non-main base branch", baseBranch: "develop", wantBranch: "develop"},
+		{name: "missing base branch", baseBranch: "release", wantErr: scanner.ErrBranchNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "clone")
+			repo, err := cloneRepo(githubProvider{}, remote, dir, tt.baseBranch, false)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("cloneRepo() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cloneRepo() error = %v", err)
+			}
+
+			head, err := repo.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := head.Name().Short(); got != tt.wantBranch {
+				t.Errorf("HEAD = %s, want %s", got, tt.wantBranch)
+			}
+			if head.Hash() != heads[tt.wantBranch] {
+				t.Errorf("HEAD at %s, want %s's commit %s", head.Hash(), tt.wantBranch, heads[tt.wantBranch])
+			}
+
+			// The instrumentation branch starts from the base branch
+			if err := checkoutNewBranch(repo, "observability/instrument"); err != nil {
+				t.Fatal(err)
+			}
+			head, err = repo.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if head.Hash() != heads[tt.wantBranch] {
+				t.Errorf("new branch at %s, want %s", head.Hash(), heads[tt.wantBranch])
+			}
+		})
+	}
+}
+
+func TestHeadBranch(t *testing.T) {
+	commit := plumbing.NewHash("1111111111111111111111111111111111111111")
+	other := plumbing.NewHash("2222222222222222222222222222222222222222")
+	branch := func(name string, hash plumbing.Hash) *plumbing.Reference {
+		return plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), hash)
+	}
+
+	tests := []struct {
+		name string
+		refs []*plumbing.Reference
+		want string
+	}{
+		{
+			name: "symbolic HEAD to non-main branch",
+			refs: []*plumbing.Reference{
+				plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("develop")),
+				branch("main", commit),
+				branch("develop", other),
+			},
+			want: "develop",
+		},
+		{
+			name: "detached HEAD matching one branch",
+			refs: []*plumbing.Reference{
+				plumbing.NewHashReference(plumbing.HEAD, other),
+				branch("main", commit),
+				branch("develop", other),
+			},
+			want: "develop",
+		},
+		{
+			name: "detached HEAD matching several branches prefers main",
+			refs: []*plumbing.Reference{
+				plumbing.NewHashReference(plumbing.HEAD, commit),
+				branch("develop", commit),
+				branch("main", commit),
+				branch("master", commit),
+			},
+			want: "main",
+		},
+		{
+			name: "no HEAD",
+			refs: []*plumbing.Reference{branch("main", commit)},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := headBranch(tt.refs); got != tt.want {
+				t.Errorf("headBranch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
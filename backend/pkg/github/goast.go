@@ -0,0 +1,990 @@
+package github
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"observability-copilot/pkg/generator"
+)
+
+// applyGoChange applies an append or modify change to a Go source file by
+// parsing it rather than splicing lines. Imports are merged into the existing
+// import declaration, declarations are appended once, statements are
+// inserted after the statement containing the anchor and call arguments are
+// added to the call containing it. The result is gofmt'd.
+func applyGoChange(filePath string, change generator.FileChange) error {
+	if change.Action == "modify" && change.LineAfter == "" {
+		filePath = goMainFile(filePath)
+	}
+	src, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) && change.Action == "append" {
+		src = []byte("package main\n")
+	} else if err != nil {
+		return err
+	}
+
+	switch change.Action {
+	case "append":
+		src, err = appendGoDecls(src, change.Content)
+	case "modify":
+		if strings.TrimSpace(change.Content) != "" {
+			src, err = insertGoStmts(src, change.LineAfter, change.Content)
+		}
+		if err == nil && len(change.CallArgs) > 0 {
+			src, err = appendGoCallArgs(src, change.LineAfter, change.CallArgs)
+		}
+		if err == nil && change.WrapHandler != "" {
+			src, err = wrapGoHandler(src, change.WrapHandler)
+		}
+	default:
+		return fmt.Errorf("unsupported action %q for Go file", change.Action)
+	}
+	if err != nil {
+		return err
+	}
+
+	if src, err = addGoImports(src, change.Imports); err != nil {
+		return err
+	}
+
+	formatted, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("generated code does not format: %w", err)
+	}
+	return os.WriteFile(filePath, formatted, 0644)
+}
+
+// goMainFile returns the file declaring func main in filePath's package:
+// filePath itself, or the sibling file that does. Code main runs, such as
+// the tracer startup and its deferred shutdown, goes there rather than in
+// the function building the router.
+func goMainFile(filePath string) string {
+	declaresMain := func(path string) bool {
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+		if err != nil || file.Name.Name != "main" {
+			return false
+		}
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+				return true
+			}
+		}
+		return false
+	}
+	if declaresMain(filePath) {
+		return filePath
+	}
+
+	siblings, _ := filepath.Glob(filepath.Join(filepath.Dir(filePath), "*.go"))
+	for _, sibling := range siblings {
+		if !strings.HasSuffix(sibling, "_test.go") && declaresMain(sibling) {
+			return sibling
+		}
+	}
+	return filePath
+}
+
+// appendGoDecls appends top-level declarations, skipping those whose names
+// the file already declares. An init function using a skipped name is
+// skipped too, so collectors already registered aren't registered twice.
+func appendGoDecls(src []byte, decls string) ([]byte, error) {
+	code := "package p\n" + decls
+	snippetSet := token.NewFileSet()
+	snippet, err := parser.ParseFile(snippetSet, "", code, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("invalid declarations: %w", err)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := declaredNames(file)
+	offset := func(p token.Pos) int { return snippetSet.Position(p).Offset }
+	type span struct{ start, end int }
+	skip := []span{}
+	skipped := map[string]bool{}
+	cut := func(doc *ast.CommentGroup, n ast.Node) {
+		start := n.Pos()
+		if doc != nil {
+			start = doc.Pos()
+		}
+		skip = append(skip, span{offset(start), offset(n.End())})
+	}
+
+	for _, decl := range snippet.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil && existing[d.Name.Name] {
+				skipped[d.Name.Name] = true
+				cut(d.Doc, d)
+			}
+		case *ast.GenDecl:
+			kept := 0
+			var specCuts []func()
+			for _, spec := range d.Specs {
+				var names []*ast.Ident
+				var doc *ast.CommentGroup
+				switch s := spec.(type) {
+				case *ast.ValueSpec:
+					names, doc = s.Names, s.Doc
+				case *ast.TypeSpec:
+					names, doc = []*ast.Ident{s.Name}, s.Doc
+				}
+				collides := false
+				for _, n := range names {
+					collides = collides || existing[n.Name]
+				}
+				if !collides {
+					kept++
+					continue
+				}
+				for _, n := range names {
+					skipped[n.Name] = true
+				}
+				spec, doc := spec, doc
+				specCuts = append(specCuts, func() { cut(doc, spec) })
+			}
+			if kept == 0 && len(specCuts) > 0 {
+				cut(d.Doc, d)
+			} else {
+				for _, c := range specCuts {
+					c()
+				}
+			}
+		}
+	}
+	for _, decl := range snippet.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Name.Name != "init" || fn.Body == nil {
+			continue
+		}
+		uses := false
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && skipped[id.Name] {
+				uses = true
+			}
+			return !uses
+		})
+		if uses {
+			cut(fn.Doc, fn)
+		}
+	}
+
+	if len(skip) > 0 {
+		sort.Slice(skip, func(i, j int) bool { return skip[i].start > skip[j].start })
+		for _, s := range skip {
+			code = code[:s.start] + code[s.end:]
+		}
+		decls = strings.TrimPrefix(code, "package p\n")
+		if rest, err := parser.ParseFile(token.NewFileSet(), "", code, 0); err != nil || len(rest.Decls) == 0 {
+			return src, nil
+		}
+	}
+
+	out := strings.TrimRight(string(src), "\n") + "\n\n" + strings.Trim(decls, "\n") + "\n"
+	return []byte(out), nil
+}
+
+// declaredNames lists the top-level identifiers a file declares, ignoring
+// init functions which may be repeated
+func declaredNames(file *ast.File) map[string]bool {
+	names := map[string]bool{}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil && d.Name.Name != "init" {
+				names[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						names[n.Name] = true
+					}
+				case *ast.TypeSpec:
+					names[s.Name.Name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// insertGoStmts inserts statements after the innermost statement whose
+// source contains anchor. An empty anchor inserts at the top of main().
+// Statements already present in the file are not inserted again.
+func insertGoStmts(src []byte, anchor, stmts string) ([]byte, error) {
+	if _, err := parser.ParseFile(token.NewFileSet(), "", "package p\nfunc _() {\n"+stmts+"\n}", 0); err != nil {
+		return nil, fmt.Errorf("invalid statements: %w", err)
+	}
+	if first := firstCodeLine(stmts); first != "" && strings.Contains(string(src), first) {
+		return src, nil
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := -1
+	if anchor == "" {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" && fn.Body != nil {
+				offset = fset.Position(fn.Body.Lbrace).Offset + 1
+			}
+		}
+		if offset < 0 {
+			return nil, fmt.Errorf("func main not found")
+		}
+	} else {
+		span := -1
+		ast.Inspect(file, func(n ast.Node) bool {
+			block, ok := n.(*ast.BlockStmt)
+			if !ok {
+				return true
+			}
+			for _, stmt := range block.List {
+				start := fset.Position(stmt.Pos()).Offset
+				end := fset.Position(stmt.End()).Offset
+				if strings.Contains(string(src[start:end]), anchor) && (span < 0 || end-start < span) {
+					offset, span = end, end-start
+				}
+			}
+			return true
+		})
+		if offset < 0 {
+			return nil, fmt.Errorf("anchor statement %q not found", anchor)
+		}
+	}
+
+	out := string(src[:offset]) + "\n" + strings.Trim(stmts, "\n") + "\n" + string(src[offset:])
+	return []byte(out), nil
+}
+
+// findGoCall returns the innermost call whose source contains anchor, or nil
+func findGoCall(fset *token.FileSet, file *ast.File, src []byte, anchor string) *ast.CallExpr {
+	var found *ast.CallExpr
+	span := -1
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		start := fset.Position(call.Pos()).Offset
+		end := fset.Position(call.End()).Offset
+		if strings.Contains(string(src[start:end]), anchor) && (span < 0 || end-start < span) {
+			found, span = call, end-start
+		}
+		return true
+	})
+	return found
+}
+
+// spreadAppend returns the append call a spread argument was built with,
+// as in f(append(opts, extra)...), or nil
+func spreadAppend(call *ast.CallExpr) *ast.CallExpr {
+	if !call.Ellipsis.IsValid() {
+		return nil
+	}
+	inner, ok := call.Args[len(call.Args)-1].(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	if fn, ok := inner.Fun.(*ast.Ident); !ok || fn.Name != "append" || len(inner.Args) == 0 {
+		return nil
+	}
+	return inner
+}
+
+// appendGoCallArgs appends args to the innermost call whose source contains
+// anchor, skipping those it already passes. A call spreading a slice,
+// f(opts...), gets them through append(opts, args...) instead.
+func appendGoCallArgs(src []byte, anchor string, args []string) ([]byte, error) {
+	for _, arg := range args {
+		if _, err := parser.ParseExpr(arg); err != nil {
+			return nil, fmt.Errorf("invalid call argument %q: %w", arg, err)
+		}
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	call := findGoCall(fset, file, src, anchor)
+	if call == nil {
+		return nil, fmt.Errorf("anchor call %q not found", anchor)
+	}
+	offset := func(p token.Pos) int { return fset.Position(p).Offset }
+
+	target := call
+	if inner := spreadAppend(call); inner != nil {
+		target = inner
+	}
+	passed := map[string]bool{}
+	for _, arg := range target.Args {
+		passed[squash(string(src[offset(arg.Pos()):offset(arg.End())]))] = true
+	}
+	missing := []string{}
+	for _, arg := range args {
+		if !passed[squash(arg)] {
+			missing = append(missing, arg)
+		}
+	}
+	if len(missing) == 0 {
+		return src, nil
+	}
+	insert := strings.Join(missing, ", ")
+
+	var out string
+	switch last := len(target.Args) - 1; {
+	case last < 0:
+		at := offset(target.Rparen)
+		out = string(src[:at]) + insert + string(src[at:])
+	case target == call && call.Ellipsis.IsValid():
+		start, end := offset(call.Args[last].Pos()), offset(call.Args[last].End())
+		out = string(src[:start]) + "append(" + string(src[start:end]) + ", " + insert + ")" + string(src[end:])
+	default:
+		// Calls split one argument per line keep that layout
+		at := offset(target.Args[last].End())
+		if strings.Contains(string(src[at:offset(target.Rparen)]), "\n") {
+			insert = strings.Join(missing, ",\n")
+			out = string(src[:at]) + ",\n" + insert + string(src[at:])
+		} else {
+			out = string(src[:at]) + ", " + insert + string(src[at:])
+		}
+	}
+	return []byte(out), nil
+}
+
+// removeGoCallArgs drops args from the innermost call whose source contains
+// anchor, unwrapping an append(opts, ...) spread that is left with nothing
+// to add. It reports whether anything was removed.
+func removeGoCallArgs(src []byte, anchor string, args []string) ([]byte, bool, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	call := findGoCall(fset, file, src, anchor)
+	if call == nil {
+		return src, false, nil
+	}
+	offset := func(p token.Pos) int { return fset.Position(p).Offset }
+
+	// The first argument of append is the slice, never a generated one
+	target, first := call, 0
+	if inner := spreadAppend(call); inner != nil {
+		target, first = inner, 1
+	}
+	generated := map[string]bool{}
+	for _, arg := range args {
+		generated[squash(arg)] = true
+	}
+	kept := []int{}
+	removed := false
+	for i, arg := range target.Args {
+		if i >= first && generated[squash(string(src[offset(arg.Pos()):offset(arg.End())]))] {
+			removed = true
+			continue
+		}
+		kept = append(kept, i)
+	}
+	if !removed {
+		return src, false, nil
+	}
+
+	// Cut each removed argument with the separator before it; leading ones
+	// go with the separator after them
+	type span struct{ start, end int }
+	var cuts []span
+	switch {
+	case len(kept) == 0:
+		cuts = append(cuts, span{offset(target.Lparen) + 1, offset(target.Rparen)})
+	case first == 1 && len(kept) == 1:
+		slice := target.Args[0]
+		cuts = append(cuts, span{offset(target.Pos()), offset(slice.Pos())}, span{offset(slice.End()), offset(target.End())})
+	default:
+		if kept[0] > 0 {
+			cuts = append(cuts, span{offset(target.Args[0].Pos()), offset(target.Args[kept[0]].Pos())})
+		}
+		next := 1
+		for i := kept[0] + 1; i < len(target.Args); i++ {
+			if next < len(kept) && kept[next] == i {
+				next++
+				continue
+			}
+			cuts = append(cuts, span{offset(target.Args[i-1].End()), offset(target.Args[i].End())})
+		}
+	}
+
+	out := string(src)
+	for i := len(cuts) - 1; i >= 0; i-- {
+		out = out[:cuts[i].start] + out[cuts[i].end:]
+	}
+	return []byte(out), true, nil
+}
+
+// servedGoHandler finds the handler a Go file serves: the handler argument
+// of http.ListenAndServe or ListenAndServeTLS, or the Handler field of an
+// http.Server literal. server is the literal when it has no Handler field,
+// i.e. serves http.DefaultServeMux.
+func servedGoHandler(file *ast.File) (handler ast.Expr, server *ast.CompositeLit) {
+	isHTTP := func(e ast.Expr, name string) bool {
+		sel, ok := e.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != name {
+			return false
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		return ok && pkg.Name == "http"
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if handler != nil || server != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if isHTTP(n.Fun, "ListenAndServe") && len(n.Args) == 2 {
+				handler = n.Args[1]
+			} else if isHTTP(n.Fun, "ListenAndServeTLS") && len(n.Args) == 4 {
+				handler = n.Args[3]
+			}
+		case *ast.CompositeLit:
+			if !isHTTP(n.Type, "Server") {
+				return true
+			}
+			for _, elt := range n.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok && key.Name == "Handler" {
+						handler = kv.Value
+						return false
+					}
+				}
+			}
+			server = n
+		}
+		return true
+	})
+	return handler, server
+}
+
+// goWrapperCall parses a WrapHandler call and returns a function reporting
+// whether a call in src is that wrapper, whatever handler it wraps
+func goWrapperCall(wrapper string) (func(fset *token.FileSet, src []byte, call *ast.CallExpr) bool, error) {
+	code := strings.Replace(wrapper, generator.HandlerPlaceholder, "handler", 1)
+	fset := token.NewFileSet()
+	expr, err := parser.ParseExprFrom(fset, "", code, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid handler wrapper %q: %w", wrapper, err)
+	}
+	want, ok := expr.(*ast.CallExpr)
+	if !ok || len(want.Args) == 0 || !strings.HasPrefix(strings.TrimSpace(strings.SplitN(wrapper, "(", 2)[1]), generator.HandlerPlaceholder) {
+		return nil, fmt.Errorf("handler wrapper %q must be a call taking the handler first", wrapper)
+	}
+	text := func(fset *token.FileSet, src []byte, n ast.Node) string {
+		return squash(string(src[fset.Position(n.Pos()).Offset:fset.Position(n.End()).Offset]))
+	}
+	fun := text(fset, []byte(code), want.Fun)
+	args := []string{}
+	for _, arg := range want.Args[1:] {
+		args = append(args, text(fset, []byte(code), arg))
+	}
+
+	return func(callSet *token.FileSet, src []byte, call *ast.CallExpr) bool {
+		if len(call.Args) != len(want.Args) || text(callSet, src, call.Fun) != fun {
+			return false
+		}
+		for i, arg := range call.Args[1:] {
+			if text(callSet, src, arg) != args[i] {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// wrapGoHandler wraps the handler the file serves in wrapper, unless it is
+// already wrapped in it
+func wrapGoHandler(src []byte, wrapper string) ([]byte, error) {
+	isWrapper, err := goWrapperCall(wrapper)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	handler, server := servedGoHandler(file)
+	offset := func(p token.Pos) int { return fset.Position(p).Offset }
+
+	if server != nil {
+		at := offset(server.Lbrace) + 1
+		field := "Handler: " + strings.Replace(wrapper, generator.HandlerPlaceholder, "http.DefaultServeMux", 1)
+		if fset.Position(server.Lbrace).Line != fset.Position(server.Rbrace).Line {
+			field = "\n" + field + ","
+		} else if len(server.Elts) > 0 {
+			field += ", "
+		}
+		return []byte(string(src[:at]) + field + string(src[at:])), nil
+	}
+	if handler == nil {
+		return nil, fmt.Errorf("no http.ListenAndServe call or http.Server found to serve the instrumented handler")
+	}
+
+	wrapped := false
+	ast.Inspect(handler, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && isWrapper(fset, src, call) {
+			wrapped = true
+		}
+		return !wrapped
+	})
+	if wrapped {
+		return src, nil
+	}
+
+	start, end := offset(handler.Pos()), offset(handler.End())
+	inner := string(src[start:end])
+	if id, ok := handler.(*ast.Ident); ok && id.Name == "nil" {
+		inner = "http.DefaultServeMux"
+	}
+	out := string(src[:start]) + strings.Replace(wrapper, generator.HandlerPlaceholder, inner, 1) + string(src[end:])
+	return []byte(out), nil
+}
+
+// unwrapGoHandler undoes wrapGoHandler, replacing the wrapper call around
+// the served handler with the handler it wraps. It reports whether the
+// wrapper was found.
+func unwrapGoHandler(src []byte, wrapper string) ([]byte, bool, error) {
+	isWrapper, err := goWrapperCall(wrapper)
+	if err != nil {
+		return nil, false, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	handler, _ := servedGoHandler(file)
+	if handler == nil {
+		return src, false, nil
+	}
+
+	var found *ast.CallExpr
+	ast.Inspect(handler, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && isWrapper(fset, src, call) {
+			found = call
+		}
+		return found == nil
+	})
+	if found == nil {
+		return src, false, nil
+	}
+	offset := func(p token.Pos) int { return fset.Position(p).Offset }
+	start, end := offset(found.Pos()), offset(found.End())
+	inner := string(src[offset(found.Args[0].Pos()):offset(found.Args[0].End())])
+
+	// http.DefaultServeMux stood in for a nil handler, or for the Handler
+	// field wrapGoHandler added to an http.Server
+	if ast.Node(found) == handler && squash(inner) == "http.DefaultServeMux" {
+		inner = "nil"
+		ast.Inspect(file, func(n ast.Node) bool {
+			kv, ok := n.(*ast.KeyValueExpr)
+			if !ok || kv.Value != handler {
+				return true
+			}
+			inner = ""
+			start, end = offset(kv.Pos()), offset(kv.End())
+			rest := strings.TrimLeft(string(src[end:]), " \t")
+			if strings.HasPrefix(rest, ",") {
+				end = len(src) - len(rest) + 1
+			}
+			for start > 0 && strings.ContainsRune(" \t", rune(src[start-1])) {
+				start--
+			}
+			if start > 0 && src[start-1] == '\n' && strings.HasPrefix(strings.TrimLeft(string(src[end:]), " \t"), "\n") {
+				start--
+			}
+			return false
+		})
+	}
+	out := string(src[:start]) + inner + string(src[end:])
+	return []byte(out), true, nil
+}
+
+// firstCodeLine returns the first non-blank, non-comment line of a snippet
+func firstCodeLine(code string) string {
+	for _, line := range strings.Split(code, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "//") {
+			return line
+		}
+	}
+	return ""
+}
+
+// addGoImports adds the given import specs (`"path"` or `name "path"`) that
+// the file doesn't already import. Specs are merged into the last import
+// declaration, which is grouped if needed, or a new one after the package
+// clause when the file has none.
+func addGoImports(src []byte, specs []string) ([]byte, error) {
+	if len(specs) == 0 {
+		return src, nil
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	imported := map[string]bool{}
+	for _, imp := range file.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err == nil {
+			imported[p] = true
+		}
+	}
+
+	missing := []string{}
+	for _, spec := range specs {
+		fields := strings.Fields(spec)
+		p, err := strconv.Unquote(fields[len(fields)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid import spec %q", spec)
+		}
+		if !imported[p] {
+			imported[p] = true
+			missing = append(missing, spec)
+		}
+	}
+	if len(missing) == 0 {
+		return src, nil
+	}
+	sort.Strings(missing)
+	lines := "\t" + strings.Join(missing, "\n\t") + "\n"
+
+	var last *ast.GenDecl
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			last = gen
+		}
+	}
+
+	var out string
+	switch {
+	case last == nil:
+		end := fset.Position(file.Name.End()).Offset
+		out = string(src[:end]) + "\n\nimport (\n" + lines + ")\n" + string(src[end:])
+	case last.Lparen.IsValid():
+		rparen := fset.Position(last.Rparen).Offset
+		out = strings.TrimRight(string(src[:rparen]), " \t\n") + "\n" + lines + string(src[rparen:])
+	default:
+		start := fset.Position(last.Pos()).Offset
+		end := fset.Position(last.End()).Offset
+		existing := strings.TrimSpace(strings.TrimPrefix(string(src[start:end]), "import"))
+		out = string(src[:start]) + "import (\n\t" + existing + "\n" + lines + ")" + string(src[end:])
+	}
+	return []byte(out), nil
+}
+
+// swapGoImports updates the imports of a Go file whose lines a "replace"
+// change swapped: the change's imports are added and the ones the replaced
+// lines used are dropped once nothing refers to them. The result is
+// gofmt'd.
+func swapGoImports(filePath string, change generator.FileChange) error {
+	src, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	if src, err = addGoImports(src, change.Imports); err != nil {
+		return err
+	}
+	if src, err = removeUnusedGoImports(src, change.ReplacedImports); err != nil {
+		return err
+	}
+	formatted, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("generated code does not format: %w", err)
+	}
+	return os.WriteFile(filePath, formatted, 0644)
+}
+
+// removeGoChange undoes an append or modify change to a Go source file:
+// declarations, statements and call arguments matching the generated ones,
+// ignoring whitespace, are cut along with the generated comments before
+// them, and the change's imports are dropped once nothing refers to them. Code that
+// differs from the generated code is left in place.
+func removeGoChange(filePath string, change generator.FileChange) error {
+	if change.LineAfter == "" && isGoStmts(change.Content) {
+		filePath = goMainFile(filePath)
+	}
+	src, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	argsRemoved := false
+	if len(change.CallArgs) > 0 {
+		if src, argsRemoved, err = removeGoCallArgs(src, change.LineAfter, change.CallArgs); err != nil {
+			return err
+		}
+	}
+
+	if change.WrapHandler != "" {
+		var unwrapped bool
+		if src, unwrapped, err = unwrapGoHandler(src, change.WrapHandler); err != nil {
+			return err
+		}
+		argsRemoved = argsRemoved || unwrapped
+	}
+
+	// A change that only adds imports is undone by pruning them
+	importsOnly := strings.TrimSpace(change.Content) == "" && len(change.CallArgs) == 0 && change.WrapHandler == ""
+
+	generated := map[string]bool{}
+	comments := map[string]bool{}
+	nodeText := func(fset *token.FileSet, code []byte, n ast.Node) string {
+		return squash(string(code[fset.Position(n.Pos()).Offset:fset.Position(n.End()).Offset]))
+	}
+	if strings.TrimSpace(change.Content) != "" {
+		var snippet string
+		if change.LineAfter == "" && !isGoStmts(change.Content) {
+			snippet = "package p\n" + change.Content
+		} else {
+			snippet = "package p\nfunc _() {\n" + change.Content + "\n}"
+		}
+		snippetSet := token.NewFileSet()
+		parsed, err := parser.ParseFile(snippetSet, "", snippet, parser.ParseComments)
+		if err != nil {
+			return fmt.Errorf("invalid generated code: %w", err)
+		}
+
+		for _, group := range parsed.Comments {
+			comments[squash(group.Text())] = true
+		}
+		if fn, ok := parsed.Decls[len(parsed.Decls)-1].(*ast.FuncDecl); ok && fn.Name.Name == "_" {
+			for _, stmt := range fn.Body.List {
+				generated[nodeText(snippetSet, []byte(snippet), stmt)] = true
+			}
+		} else {
+			for _, decl := range parsed.Decls {
+				generated[nodeText(snippetSet, []byte(snippet), decl)] = true
+			}
+		}
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	// Collect the byte ranges of generated nodes, each at most once
+	type span struct{ start, end int }
+	var cuts []span
+	cut := func(n ast.Node) {
+		text := nodeText(fset, src, n)
+		if !generated[text] {
+			return
+		}
+		delete(generated, text)
+		cuts = append(cuts, span{fset.Position(n.Pos()).Offset, fset.Position(n.End()).Offset})
+	}
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		cut(decl)
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if block, ok := n.(*ast.BlockStmt); ok {
+			for _, stmt := range block.List {
+				cut(stmt)
+			}
+		}
+		return true
+	})
+	if len(cuts) == 0 && !argsRemoved && !importsOnly {
+		return nil
+	}
+
+	// Generated comments directly above a cut node go with it
+	for _, group := range file.Comments {
+		if !comments[squash(group.Text())] {
+			continue
+		}
+		end := fset.Position(group.End()).Offset
+		for i, c := range cuts {
+			if end <= c.start && strings.TrimSpace(string(src[end:c.start])) == "" {
+				cuts[i].start = fset.Position(group.Pos()).Offset
+			}
+		}
+	}
+
+	// Cut whole lines, merging neighbouring cuts, plus the blank line the
+	// insertion left after them
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].start < cuts[j].start })
+	merged := []span{}
+	for _, c := range cuts {
+		for c.start > 0 && (src[c.start-1] == ' ' || src[c.start-1] == '\t') {
+			c.start--
+		}
+		if i := strings.IndexByte(string(src[c.end:]), '\n'); i >= 0 && strings.TrimSpace(string(src[c.end:c.end+i])) == "" {
+			c.end += i + 1
+		}
+		if n := len(merged); n > 0 && strings.TrimSpace(string(src[merged[n-1].end:c.start])) == "" {
+			merged[n-1].end = c.end
+			continue
+		}
+		merged = append(merged, c)
+	}
+	out := string(src)
+	for i := len(merged) - 1; i >= 0; i-- {
+		c := merged[i]
+		end := c.end
+		if rest := out[end:]; strings.HasPrefix(strings.TrimLeft(rest, " \t"), "\n") {
+			end += strings.IndexByte(rest, '\n') + 1
+		}
+		out = out[:c.start] + out[end:]
+	}
+
+	pruned, err := removeUnusedGoImports([]byte(out), change.Imports)
+	if err != nil {
+		return err
+	}
+	formatted, err := format.Source(pruned)
+	if err != nil {
+		return fmt.Errorf("code does not format after removal: %w", err)
+	}
+	return os.WriteFile(filePath, formatted, 0644)
+}
+
+// isGoStmts reports whether a snippet is statements rather than top-level
+// declarations
+func isGoStmts(code string) bool {
+	_, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+code, 0)
+	return err != nil
+}
+
+// squash drops all whitespace so code compares equal however it is
+// formatted
+func squash(code string) string {
+	return strings.Join(strings.Fields(code), "")
+}
+
+// removeUnusedGoImports drops the given import specs when the file no
+// longer refers to the imported package
+func removeUnusedGoImports(src []byte, specs []string) ([]byte, error) {
+	if len(specs) == 0 {
+		return src, nil
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+
+	candidates := map[string]bool{}
+	for _, spec := range specs {
+		fields := strings.Fields(spec)
+		if p, err := strconv.Unquote(fields[len(fields)-1]); err == nil {
+			candidates[p] = true
+		}
+	}
+
+	unused := func(imp *ast.ImportSpec) bool {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || !candidates[p] {
+			return false
+		}
+		name := importName(p)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		return name == "_" || name == "." || !used[name]
+	}
+
+	// Cut unused specs, or the whole declaration when none are left. A
+	// group left with one spec and no comments goes back to a single-line
+	// import, as addGoImports found it.
+	type span struct {
+		start, end int
+		text       string
+	}
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	var cuts []span
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		var specCuts []span
+		var kept []ast.Spec
+		for _, spec := range gen.Specs {
+			if imp := spec.(*ast.ImportSpec); unused(imp) {
+				specCuts = append(specCuts, span{start: offset(imp.Pos()), end: offset(imp.End())})
+			} else {
+				kept = append(kept, spec)
+			}
+		}
+		switch {
+		case len(kept) == 0:
+			cuts = append(cuts, span{start: offset(gen.Pos()), end: offset(gen.End())})
+		case len(specCuts) > 0 && len(kept) == 1 && gen.Lparen.IsValid() && !hasComments(file, gen):
+			spec := string(src[offset(kept[0].Pos()):offset(kept[0].End())])
+			cuts = append(cuts, span{start: offset(gen.Pos()), end: offset(gen.End()), text: "import " + spec})
+		default:
+			cuts = append(cuts, specCuts...)
+		}
+	}
+
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].start > cuts[j].start })
+	out := string(src)
+	for _, c := range cuts {
+		out = out[:c.start] + c.text + out[c.end:]
+	}
+	return []byte(out), nil
+}
+
+// hasComments reports whether any of file's comments are inside node
+func hasComments(file *ast.File, node ast.Node) bool {
+	for _, group := range file.Comments {
+		if group.Pos() >= node.Pos() && group.End() <= node.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// importName is the package name an import path is referred to by,
+// skipping a major version suffix: github.com/go-chi/chi/v5 is chi
+func importName(p string) string {
+	name := path.Base(p)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" && path.Dir(p) != "." {
+		name = path.Base(path.Dir(p))
+	}
+	return name
+}
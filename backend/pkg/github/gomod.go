@@ -0,0 +1,55 @@
+package github
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+
+	"observability-copilot/pkg/generator"
+)
+
+// applyGoModChange merges the require block of a go.mod change into the
+// existing go.mod. Modules it doesn't require yet are added; modules it
+// already requires are only raised to the generated version when that is
+// newer, so the repo's own pins are kept.
+func applyGoModChange(filePath string, change generator.FileChange) error {
+	if change.Action != "append" {
+		return fmt.Errorf("unsupported action %q for go.mod", change.Action)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	file, err := modfile.Parse(filePath, data, nil)
+	if err != nil {
+		return err
+	}
+	wanted, err := modfile.ParseLax("require.mod", []byte(change.Content), nil)
+	if err != nil {
+		return fmt.Errorf("invalid generated requirements: %w", err)
+	}
+
+	current := map[string]string{}
+	for _, r := range file.Require {
+		current[r.Mod.Path] = r.Mod.Version
+	}
+	for _, r := range wanted.Require {
+		have, ok := current[r.Mod.Path]
+		if ok && semver.Compare(have, r.Mod.Version) >= 0 {
+			continue
+		}
+		if err := file.AddRequire(r.Mod.Path, r.Mod.Version); err != nil {
+			return err
+		}
+		current[r.Mod.Path] = r.Mod.Version
+	}
+
+	file.Cleanup()
+	out, err := file.Format()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, out, 0644)
+}
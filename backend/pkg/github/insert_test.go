@@ -0,0 +1,141 @@
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInsertAfterLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		src      string
+		anchor   string
+		isRegexp bool
+		content  string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:    "top-level anchor",
+			file:    "app.rb",
+			src:     "require 'sinatra'\nget '/' do\n  'ok'\nend\n",
+			anchor:  "require 'sinatra'",
+			content: "require 'prometheus/middleware/exporter'\n",
+			want:    "require 'sinatra'\nrequire 'prometheus/middleware/exporter'\nget '/' do\n  'ok'\nend\n",
+		},
+		{
+			name:    "indented anchor",
+			file:    "config.rb",
+			src:     "class App\n  def setup\n    use Rack::Logger\n  end\nend\n",
+			anchor:  "use Rack::Logger",
+			content: "use Prometheus::Middleware::Collector\nuse Prometheus::Middleware::Exporter\n",
+			want:    "class App\n  def setup\n    use Rack::Logger\n    use Prometheus::Middleware::Collector\n    use Prometheus::Middleware::Exporter\n  end\nend\n",
+		},
+		{
+			name:    "anchor opening a block",
+			file:    "build.gradle",
+			src:     "plugins {\n    id 'java'\n}\n\ndependencies {\n    implementation 'org.springframework.boot:spring-boot-starter-web'\n}\n",
+			anchor:  "dependencies {",
+			content: "\n// OpenTelemetry dependencies\nimplementation 'io.opentelemetry:opentelemetry-api:1.32.0'\n",
+			want:    "plugins {\n    id 'java'\n}\n\ndependencies {\n    // OpenTelemetry dependencies\n    implementation 'io.opentelemetry:opentelemetry-api:1.32.0'\n    implementation 'org.springframework.boot:spring-boot-starter-web'\n}\n",
+		},
+		{
+			name:    "nested xml block with tabs",
+			file:    "pom.xml",
+			src:     "<project>\n\t<dependencies>\n\t\t<dependency>\n\t\t\t<artifactId>web</artifactId>\n\t\t</dependency>\n\t</dependencies>\n</project>\n",
+			anchor:  "<dependencies>",
+			content: "<dependency>\n    <artifactId>otel</artifactId>\n</dependency>",
+			want:    "<project>\n\t<dependencies>\n\t\t<dependency>\n\t\t    <artifactId>otel</artifactId>\n\t\t</dependency>\n\t\t<dependency>\n\t\t\t<artifactId>web</artifactId>\n\t\t</dependency>\n\t</dependencies>\n</project>\n",
+		},
+		{
+			name:    "content indentation is replaced",
+			file:    "settings.py",
+			src:     "class Config:\n    DEBUG = False\n",
+			anchor:  "DEBUG = False",
+			content: "        METRICS = True\n            # nested\n",
+			want:    "class Config:\n    DEBUG = False\n    METRICS = True\n        # nested\n",
+		},
+		{
+			name:    "python statement continued over lines",
+			file:    "main.py",
+			src:     "def create():\n    app = FastAPI(\n        title='api',\n    )\n    return app\n",
+			anchor:  "app = FastAPI(",
+			content: "FastAPIInstrumentor.instrument_app(app)",
+			want:    "def create():\n    app = FastAPI(\n        title='api',\n    )\n    FastAPIInstrumentor.instrument_app(app)\n    return app\n",
+		},
+		{
+			name:    "substring anchor takes first occurrence",
+			file:    "pom.xml",
+			src:     "<project>\n  <dependencyManagement>\n    <dependencies>\n    </dependencies>\n  </dependencyManagement>\n  <dependencies>\n    <dependency/>\n  </dependencies>\n</project>\n",
+			anchor:  "<dependencies>",
+			content: "<dependency>otel</dependency>",
+			want:    "<project>\n  <dependencyManagement>\n    <dependencies>\n    <dependency>otel</dependency>\n    </dependencies>\n  </dependencyManagement>\n  <dependencies>\n    <dependency/>\n  </dependencies>\n</project>\n",
+		},
+		{
+			name:     "regexp anchor picks the specific site",
+			file:     "pom.xml",
+			src:      "<project>\n  <dependencyManagement>\n    <dependencies>\n    </dependencies>\n  </dependencyManagement>\n  <dependencies>\n    <dependency/>\n  </dependencies>\n</project>\n",
+			anchor:   `^  <dependencies>\s*$`,
+			isRegexp: true,
+			content:  "<dependency>otel</dependency>",
+			want:     "<project>\n  <dependencyManagement>\n    <dependencies>\n    </dependencies>\n  </dependencyManagement>\n  <dependencies>\n    <dependency>otel</dependency>\n    <dependency/>\n  </dependencies>\n</project>\n",
+		},
+		{
+			name:     "regexp anchor on indented line",
+			file:     "app.rb",
+			src:      "configure do\n  set :port, 4567\n  set :bind, '0.0.0.0'\nend\n",
+			anchor:   `^\s+set :bind,`,
+			isRegexp: true,
+			content:  "use Prometheus::Middleware::Exporter",
+			want:     "configure do\n  set :port, 4567\n  set :bind, '0.0.0.0'\n  use Prometheus::Middleware::Exporter\nend\n",
+		},
+		{
+			name:    "missing anchor",
+			file:    "app.rb",
+			src:     "get '/' do\nend\n",
+			anchor:  "require 'sinatra'",
+			content: "require 'prometheus'",
+			want:    "get '/' do\nend\n",
+			wantErr: `anchor line "require 'sinatra'" not found`,
+		},
+		{
+			name:     "invalid regexp anchor",
+			file:     "app.rb",
+			src:      "get '/' do\nend\n",
+			anchor:   "get '/' (",
+			isRegexp: true,
+			content:  "require 'prometheus'",
+			want:     "get '/' do\nend\n",
+			wantErr:  "invalid line pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.src), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := insertAfterLine(path, tt.anchor, tt.content, tt.isRegexp)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("insertAfterLine() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("insertAfterLine() error = %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(data); got != tt.want {
+				t.Errorf("%s =\n%s\nwant\n%s", tt.file, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package github
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"observability-copilot/pkg/generator"
+)
+
+var (
+	// packageDependencies opens package.json's dependencies object
+	packageDependencies = regexp.MustCompile(`"dependencies"\s*:\s*\{`)
+
+	// packageEntryName captures the package name of a `"name": "version"`
+	// entry
+	packageEntryName = regexp.MustCompile(`"([^"]+)"\s*:`)
+
+	// packageTrailingComma is a comma left before an object's closing brace
+	packageTrailingComma = regexp.MustCompile(`,(\s*\n[ \t]*\})`)
+
+	// packageEmptyDependencies is a dependencies object emptied by removal
+	packageEmptyDependencies = regexp.MustCompile(`("dependencies"\s*:\s*)\{\s*\}`)
+)
+
+// packageEntries returns the `"name": "version"` entries of a package.json
+// change, without trailing commas
+func packageEntries(content string) []string {
+	entries := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		if entry := strings.TrimSuffix(strings.TrimSpace(line), ","); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// applyPackageJSONChange adds the dependencies of a package.json change to
+// the dependencies object, skipping packages it already lists. An empty
+// object, e.g. `"dependencies": {}`, is rewritten rather than left with a
+// trailing comma.
+func applyPackageJSONChange(filePath string, change generator.FileChange) error {
+	if change.Action != "modify" {
+		return fmt.Errorf("unsupported action %q for package.json", change.Action)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	content := string(data)
+
+	loc := packageDependencies.FindStringIndex(content)
+	if loc == nil {
+		return fmt.Errorf("no dependencies object in package.json")
+	}
+	open := loc[1]
+	end := closingBrace(content, open)
+	if end < 0 {
+		return fmt.Errorf("unterminated dependencies object in package.json")
+	}
+	body := content[open:end]
+
+	listed := map[string]bool{}
+	for _, m := range packageEntryName.FindAllStringSubmatch(body, -1) {
+		listed[m[1]] = true
+	}
+	missing := []string{}
+	for _, entry := range packageEntries(change.Content) {
+		if m := packageEntryName.FindStringSubmatch(entry); m != nil && !listed[m[1]] {
+			missing = append(missing, entry)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	keyStart := strings.LastIndex(content[:loc[0]], "\n") + 1
+	keyIndent := leadingSpace(content[keyStart:loc[0]])
+	indent := keyIndent + keyIndent
+	if keyIndent == "" {
+		indent = "  "
+	}
+
+	if strings.TrimSpace(body) == "" {
+		body = "\n" + indent + strings.Join(missing, ",\n"+indent) + "\n" + keyIndent
+		content = content[:open] + body + content[end:]
+	} else {
+		if lines := strings.SplitN(strings.TrimLeft(body, " \t"), "\n", 3); len(lines) > 1 {
+			indent = leadingSpace(lines[1])
+		}
+		inserted := ""
+		for _, entry := range missing {
+			inserted += "\n" + indent + entry + ","
+		}
+		content = content[:open] + inserted + content[open:]
+	}
+	return os.WriteFile(filePath, []byte(content), 0644)
+}
+
+// removePackageJSONChange takes the dependencies a package.json change added
+// back out, dropping the comma the last remaining entry is left with
+func removePackageJSONChange(filePath string, change generator.FileChange) error {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	generated := map[string]bool{}
+	for _, entry := range packageEntries(change.Content) {
+		generated[entry] = true
+	}
+	lines := strings.Split(string(data), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !generated[strings.TrimSuffix(strings.TrimSpace(line), ",")] {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) == len(lines) {
+		return nil
+	}
+
+	content := packageTrailingComma.ReplaceAllString(strings.Join(kept, "\n"), "$1")
+	content = packageEmptyDependencies.ReplaceAllString(content, "${1}{}")
+	return os.WriteFile(filePath, []byte(content), 0644)
+}
+
+// closingBrace returns the index of the brace closing the object whose
+// body starts at open, skipping braces inside strings, or -1
+func closingBrace(content string, open int) int {
+	depth, inString := 1, false
+	for i := open; i < len(content); i++ {
+		switch c := content[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,58 @@
+package github
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// insertPOMDependencies inserts content, <dependency> elements, at the top
+// of the project's own <dependencies> in a pom.xml. The ones nested in
+// <dependencyManagement>, plugins and profiles are passed over, however the
+// file is indented.
+func insertPOMDependencies(filePath, content string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	i, err := projectDependenciesLine(data)
+	if err != nil {
+		return err
+	}
+	return insertLines(filePath, strings.Split(string(data), "\n"), i, content)
+}
+
+// projectDependenciesLine returns the index of the line opening the
+// <dependencies> element directly under a pom.xml's <project>
+func projectDependenciesLine(data []byte) (int, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	open := []string{}
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return -1, fmt.Errorf("invalid pom.xml: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "dependencies" && len(open) == 1 && open[0] == "project" {
+				end := int(d.InputOffset())
+				if bytes.HasSuffix(data[:end], []byte("/>")) {
+					return -1, fmt.Errorf("pom.xml's <dependencies/> is empty")
+				}
+				return bytes.Count(data[:end], []byte("\n")), nil
+			}
+			open = append(open, t.Name.Local)
+		case xml.EndElement:
+			open = open[:len(open)-1]
+		}
+	}
+	return -1, fmt.Errorf("no <dependencies> in pom.xml's <project>")
+}
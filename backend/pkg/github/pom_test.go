@@ -0,0 +1,82 @@
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"observability-copilot/pkg/generator"
+)
+
+func TestInsertPOMDependencies(t *testing.T) {
+	const dependency = "<dependency>\n    <artifactId>otel</artifactId>\n</dependency>"
+
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "two-space indent after dependencyManagement",
+			src:  "<project>\n  <dependencyManagement>\n    <dependencies>\n      <dependency/>\n    </dependencies>\n  </dependencyManagement>\n  <dependencies>\n    <dependency/>\n  </dependencies>\n</project>\n",
+			want: "<project>\n  <dependencyManagement>\n    <dependencies>\n      <dependency/>\n    </dependencies>\n  </dependencyManagement>\n  <dependencies>\n    <dependency>\n        <artifactId>otel</artifactId>\n    </dependency>\n    <dependency/>\n  </dependencies>\n</project>\n",
+		},
+		{
+			name: "four-space indent",
+			src:  "<project>\n    <dependencies>\n        <dependency/>\n    </dependencies>\n</project>\n",
+			want: "<project>\n    <dependencies>\n        <dependency>\n            <artifactId>otel</artifactId>\n        </dependency>\n        <dependency/>\n    </dependencies>\n</project>\n",
+		},
+		{
+			name: "plugin dependencies first",
+			src:  "<project>\n\t<build>\n\t\t<plugins>\n\t\t\t<plugin>\n\t\t\t\t<dependencies>\n\t\t\t\t</dependencies>\n\t\t\t</plugin>\n\t\t</plugins>\n\t</build>\n\t<dependencies>\n\t\t<dependency/>\n\t</dependencies>\n</project>\n",
+			want: "<project>\n\t<build>\n\t\t<plugins>\n\t\t\t<plugin>\n\t\t\t\t<dependencies>\n\t\t\t\t</dependencies>\n\t\t\t</plugin>\n\t\t</plugins>\n\t</build>\n\t<dependencies>\n\t\t<dependency>\n\t\t    <artifactId>otel</artifactId>\n\t\t</dependency>\n\t\t<dependency/>\n\t</dependencies>\n</project>\n",
+		},
+		{
+			name:    "only managed dependencies",
+			src:     "<project>\n  <dependencyManagement>\n    <dependencies>\n    </dependencies>\n  </dependencyManagement>\n</project>\n",
+			wantErr: "no <dependencies> in pom.xml's <project>",
+		},
+		{
+			name:    "empty element",
+			src:     "<project>\n  <dependencies/>\n</project>\n",
+			wantErr: "<dependencies/> is empty",
+		},
+		{
+			name:    "malformed",
+			src:     "<project>\n  <build>\n</project>\n  <dependencies>\n  </dependencies>\n",
+			wantErr: "invalid pom.xml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "pom.xml")
+			if err := os.WriteFile(path, []byte(tt.src), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			change := generator.FileChange{Path: "pom.xml", Action: "modify", Content: dependency, LineAfter: "<dependencies>"}
+			err := applyPlan(dir, &generator.InstrumentationPlan{Changes: []generator.FileChange{change}})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("applyPlan() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyPlan() error = %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(data); got != tt.want {
+				t.Errorf("pom.xml =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,17 +1,18 @@
 package github
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"observability-copilot/pkg/generator"
+	"observability-copilot/pkg/logging"
+	"observability-copilot/pkg/scanner"
+	"observability-copilot/pkg/togglespec"
 )
 
 type PRRequest struct {
@@ -19,6 +20,7 @@ type PRRequest struct {
 	Body  string `json:"body"`
 	Head  string `json:"head"`
 	Base  string `json:"base"`
+	Draft bool   `json:"draft,omitempty"`
 }
 
 type PRResponse struct {
@@ -26,18 +28,66 @@ type PRResponse struct {
 	Number  int    `json:"number"`
 }
 
-// CreateInstrumentationPR creates a PR with only missing instrumentation
+// ErrPRExists is returned alongside the URL of an already open PR from the
+// instrumentation branch, so repeated requests don't open duplicates
+var ErrPRExists = errors.New("pull request already exists")
+
+// FollowUpError is returned alongside the URL of a PR that was opened but
+// couldn't be given all of its reviewers or labels. Failures names each
+// call that failed.
+type FollowUpError struct {
+	Failures []string
+}
+
+func (e *FollowUpError) Error() string {
+	return "pull request created, but " + strings.Join(e.Failures, "; ")
+}
+
+// PROptions are the optional settings of an instrumentation PR
+type PROptions struct {
+	// BaseBranch is the branch the PR targets, the repo's default branch
+	// when empty
+	BaseBranch string
+	// Force overwrites an existing instrumentation branch
+	Force bool
+	// Draft opens the PR as a draft
+	Draft bool
+	// Reviewers are requested on a new PR: user logins, or "org/team" for
+	// a GitHub team
+	Reviewers []string
+	// Labels are added to a new PR
+	Labels []string
+	// TitleTemplate and BodyTemplate are text/template sources for the PR
+	// title (also the commit message) and body, executed with
+	// PRTemplateData. Empty uses DefaultTitleTemplate and
+	// DefaultBodyTemplate.
+	TitleTemplate string
+	BodyTemplate  string
+	// AuthorName and AuthorEmail attribute the commit, overriding the
+	// configured identity
+	AuthorName  string
+	AuthorEmail string
+}
+
+// CreateInstrumentationPR creates a PR with only missing instrumentation.
+// If the instrumentation branch already exists on the remote, opts.Force
+// overwrites it; otherwise its open PR is returned with ErrPRExists.
+// Reviewers and labels that can't be added don't fail the PR; they are
+// reported with a FollowUpError. Progress is logged with ctx's request ID.
 func CreateInstrumentationPR(
+	ctx context.Context,
 	repoURL string,
 	plan *generator.InstrumentationPlan,
 	hasMetrics bool,
 	hasOtel bool,
+	opts PROptions,
 ) (string, error) {
 
-	// Get GitHub token first
-	token := os.Getenv("GITHUB_TOKEN")
-	if token == "" {
-		return "", fmt.Errorf("GITHUB_TOKEN not set")
+	// Get the provider's token first
+	repoURL = scanner.NormalizeRepoURL(repoURL)
+	provider := providerFor(repoURL)
+	if _, err := provider.Token(); err != nil {
+		return "", err
 	}
 
 	// Parse repo owner and name from URL
@@ -46,106 +96,609 @@ func CreateInstrumentationPR(
 		return "", fmt.Errorf("invalid repo URL: %s", repoURL)
 	}
 
-	// Clone repo
-	tmpDir := filepath.Join("/tmp", fmt.Sprintf("%s-%s", owner, repo))
-	os.RemoveAll(tmpDir)
+	if err := ValidateAuthor(opts.AuthorName, opts.AuthorEmail); err != nil {
+		return "", err
+	}
 
-	cmd := exec.Command("git", "clone", repoURL, tmpDir)
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("git clone failed: %w", err)
+	// Render the title and body first so a bad template fails fast
+	title, body, err := renderPRTitleBody(plan, hasMetrics, hasOtel, opts)
+	if err != nil {
+		return "", err
 	}
-	defer os.RemoveAll(tmpDir)
 
-	// Configure git user (REQUIRED to fix exit code 128)
-	cmd = exec.Command("git", "-C", tmpDir, "config", "user.name", "Observability Copilot Bot")
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("git config user.name failed: %w", err)
+	// Create branch name based on what we're adding
+	branchName := BranchName(PlanMode(plan), hasMetrics, hasOtel)
+	log := logging.FromContext(ctx).With("repo", repoURL, "branch", branchName)
+
+	// Check for a previous run before doing any work
+	auth := provider.Auth(repoURL)
+	branchExists, err := remoteBranchExists(auth, repoURL, branchName)
+	if err != nil {
+		return "", err
+	}
+	existingPR := ""
+	if branchExists {
+		existingPR, err = provider.FindOpenPR(ctx, owner, repo, branchName)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up existing PR: %w", err)
+		}
+		if !opts.Force {
+			if existingPR != "" {
+				return existingPR, ErrPRExists
+			}
+			return "", fmt.Errorf("instrumentation branch %q already exists on the remote; retry with force to update it", branchName)
+		}
 	}
 
-	cmd = exec.Command("git", "-C", tmpDir, "config", "user.email", "bot@observability-copilot.dev")
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("git config user.email failed: %w", err)
+	// Clone repo into a directory of its own so concurrent requests for
+	// the same repo don't share a worktree
+	tmpDir, err := scanner.MkdirTemp(fmt.Sprintf("%s-%s-", strings.ReplaceAll(owner, "/", "-"), repo))
+	if err != nil {
+		return "", fmt.Errorf("failed to create clone directory: %w", err)
+	}
+	defer scanner.RemoveTemp(tmpDir)
+
+	// Target the repo's own default branch, which isn't always main
+	baseBranch := opts.BaseBranch
+	if baseBranch == "" {
+		baseBranch, err = defaultBranch(auth, repoURL)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	// Create branch name based on what we're adding
-	branchName := getBranchName(plan.Mode, hasMetrics, hasOtel)
+	gitRepo, err := cloneRepo(provider, repoURL, tmpDir, baseBranch, false)
+	if err != nil {
+		if opts.BaseBranch == "" && errors.Is(err, scanner.ErrBranchNotFound) {
+			forgetDefaultBranch(repoURL)
+		}
+		return "", err
+	}
+	log.Debug("cloned repository", "base_branch", baseBranch)
 
 	// Create and checkout new branch
-	cmd = exec.Command("git", "-C", tmpDir, "checkout", "-b", branchName)
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("git checkout failed: %w", err)
+	if err := checkoutNewBranch(gitRepo, branchName); err != nil {
+		return "", err
 	}
 
 	// Apply changes from plan
+	if err := applyPlan(tmpDir, plan); err != nil {
+		return "", err
+	}
+
+	// Nothing to commit when every change was already present
+	changed, err := hasChanges(gitRepo)
+	if err != nil {
+		return "", err
+	}
+	if !changed {
+		if plan.Mode == "none" {
+			return "", fmt.Errorf("no generated instrumentation found to remove, nothing to change")
+		}
+		return "", fmt.Errorf("instrumentation is already present, nothing to change")
+	}
+
+	// Match the project's formatting, then validate with the toolchain for
+	// the plan's language
+	formatChanges(ctx, tmpDir, plan)
+	if err := validateChanges(tmpDir, plan); err != nil {
+		return "", fmt.Errorf("validation failed: %w", err)
+	}
+
+	// Commit as the requested author, or the bot
+	if _, err := commitAll(gitRepo, title, commitAuthor(opts.AuthorName, opts.AuthorEmail)); err != nil {
+		return "", err
+	}
+
+	// Push, replacing the previous run's branch when forced
+	if err := pushBranch(gitRepo, auth, branchName, branchExists); err != nil {
+		return "", err
+	}
+	log.Info("pushed instrumentation branch", "changes", len(plan.Changes), "force", branchExists)
+
+	// The open PR picks up the force-pushed branch
+	if existingPR != "" {
+		return existingPR, nil
+	}
+
+	// Create PR via the provider's API
+	pr, err := provider.CreatePR(ctx, owner, repo, PRRequest{
+		Title: title,
+		Body:  body,
+		Head:  branchName,
+		Base:  baseBranch,
+		Draft: opts.Draft,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create PR: %w", err)
+	}
+	log.Info("opened pull request", "pr_url", pr.HTMLURL, "draft", opts.Draft)
+
+	// The PR stands even if these fail
+	var failures []string
+	if len(opts.Reviewers) > 0 {
+		if err := provider.RequestReviewers(ctx, owner, repo, pr.Number, opts.Reviewers); err != nil {
+			failures = append(failures, fmt.Sprintf("requesting reviewers %s failed: %v", strings.Join(opts.Reviewers, ", "), err))
+		}
+	}
+	if len(opts.Labels) > 0 {
+		if err := provider.AddLabels(ctx, owner, repo, pr.Number, opts.Labels); err != nil {
+			failures = append(failures, fmt.Sprintf("adding labels %s failed: %v", strings.Join(opts.Labels, ", "), err))
+		}
+	}
+	if len(failures) > 0 {
+		log.Warn("pull request follow-ups failed", "pr_url", pr.HTMLURL, "failures", failures)
+		return pr.HTMLURL, &FollowUpError{Failures: failures}
+	}
+
+	return pr.HTMLURL, nil
+}
+
+// DryRunResult is the outcome of applying a plan without committing or pushing
+type DryRunResult struct {
+	Diff            string   `json:"diff"`
+	Files           []string `json:"files"`
+	ValidationError string   `json:"validation_error,omitempty"`
+}
+
+// DryRunInstrumentationPR clones the repo and applies the plan like
+// CreateInstrumentationPR, but returns the resulting diff instead of
+// committing, pushing, or opening a PR.
+func DryRunInstrumentationPR(ctx context.Context, repoURL string, plan *generator.InstrumentationPlan, baseBranch string) (*DryRunResult, error) {
+	repoURL = scanner.NormalizeRepoURL(repoURL)
+	owner, repo := parseRepoURL(repoURL)
+	if owner == "" || repo == "" {
+		return nil, fmt.Errorf("invalid repo URL: %s", repoURL)
+	}
+
+	tmpDir, err := scanner.MkdirTemp(fmt.Sprintf("%s-%s-dryrun-", strings.ReplaceAll(owner, "/", "-"), repo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clone directory: %w", err)
+	}
+	defer scanner.RemoveTemp(tmpDir)
+
+	gitRepo, err := cloneRepo(providerFor(repoURL), repoURL, tmpDir, baseBranch, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyPlan(tmpDir, plan); err != nil {
+		return nil, err
+	}
+
+	formatChanges(ctx, tmpDir, plan)
+	result := &DryRunResult{Files: []string{}}
+	if err := validateChanges(tmpDir, plan); err != nil {
+		result.ValidationError = err.Error()
+		logging.FromContext(ctx).Warn("dry run failed validation", "repo", repoURL, "error", err)
+	}
+
+	// Commit locally so newly created files show up in the diff
+	diff, files, err := worktreeDiff(gitRepo)
+	if err != nil {
+		return nil, err
+	}
+	result.Diff = diff
+	result.Files = append(result.Files, files...)
+
+	return result, nil
+}
+
+// applyPlan writes the plan's file changes into a checked-out repo
+func applyPlan(dir string, plan *generator.InstrumentationPlan) error {
 	for _, change := range plan.Changes {
-		filePath := filepath.Join(tmpDir, change.Path)
+		filePath := filepath.Join(dir, change.Path)
+
+		// Line edits delete or swap out the matched lines
+		if change.Action == "replace" || (change.Action == "delete" && change.MatchLine != "") {
+			if change.Action == "replace" && alreadyApplied(filePath, change.Content) {
+				continue
+			}
+			if err := replaceLines(filePath, change, change.Action == "delete"); err != nil {
+				return fmt.Errorf("failed to modify %s: %w", change.Path, err)
+			}
+			if change.Action == "replace" && strings.HasSuffix(change.Path, ".go") {
+				if err := swapGoImports(filePath, change); err != nil {
+					return fmt.Errorf("failed to modify %s: %w", change.Path, err)
+				}
+			}
+			continue
+		}
+
+		// Removal plans only take out code that matches what was generated
+		if change.Action == "delete" || change.Action == "remove" {
+			if err := removeGenerated(filePath, change); err != nil {
+				return fmt.Errorf("failed to remove instrumentation from %s: %w", change.Path, err)
+			}
+			continue
+		}
+
+		// Go sources are edited through the AST so imports stay valid
+		if strings.HasSuffix(change.Path, ".go") && (change.Action == "append" || change.Action == "modify") {
+			if err := applyGoChange(filePath, change); err != nil {
+				return fmt.Errorf("failed to modify %s: %w", change.Path, err)
+			}
+			continue
+		}
+
+		// go.mod requirements are merged rather than appended as a second
+		// require block
+		if filepath.Base(change.Path) == "go.mod" {
+			if err := applyGoModChange(filePath, change); err != nil {
+				return fmt.Errorf("failed to modify %s: %w", change.Path, err)
+			}
+			continue
+		}
+
+		// package.json dependencies are merged into the dependencies object,
+		// which may be empty
+		if filepath.Base(change.Path) == "package.json" && change.Action == "modify" {
+			if err := applyPackageJSONChange(filePath, change); err != nil {
+				return fmt.Errorf("failed to modify %s: %w", change.Path, err)
+			}
+			continue
+		}
+
+		if (change.Action == "append" || change.Action == "modify") && alreadyApplied(filePath, change.Content) {
+			continue
+		}
 
 		if change.Action == "append" {
-			// Append to existing file
-			f, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0644)
+			// Append to the file, creating config files such as an absent
+			// application.properties
+			f, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 			if err != nil {
-				return "", fmt.Errorf("failed to open %s: %w", change.Path, err)
+				return fmt.Errorf("failed to open %s: %w", change.Path, err)
 			}
 			_, err = f.WriteString(change.Content)
 			f.Close()
 			if err != nil {
-				return "", err
+				return err
 			}
 		} else if change.Action == "create" {
-			// Create new file
-			err := os.WriteFile(filePath, []byte(change.Content), 0644)
+			// Create new file, and any package directory it goes in. A file
+			// already at the path is never overwritten: it is either an
+			// earlier run's output or the repo's own code.
+			if existing, err := os.ReadFile(filePath); err == nil {
+				if string(existing) == change.Content {
+					continue
+				}
+				return fmt.Errorf("failed to create %s: file already exists", change.Path)
+			} else if !os.IsNotExist(err) {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(filePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
 			if err != nil {
-				return "", err
+				return fmt.Errorf("failed to create %s: %w", change.Path, err)
+			}
+			_, err = f.WriteString(change.Content)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		} else if change.Action == "modify" && change.Chain != "" {
+			// Splice the call into the builder chain
+			if err := spliceChain(filePath, change.LineAfter, change.Content, change.Chain); err != nil {
+				return fmt.Errorf("failed to modify %s: %w", change.Path, err)
+			}
+		} else if change.Action == "modify" && filepath.Base(change.Path) == "pom.xml" {
+			// Maven dependencies go in the project's own <dependencies>
+			if err := insertPOMDependencies(filePath, change.Content); err != nil {
+				return fmt.Errorf("failed to modify %s: %w", change.Path, err)
+			}
+		} else if change.Action == "modify" {
+			// Insert after the anchor line
+			if err := insertAfterLine(filePath, change.LineAfter, change.Content, change.Regexp); err != nil {
+				return fmt.Errorf("failed to modify %s: %w", change.Path, err)
 			}
 		}
 	}
+	return nil
+}
 
-	// Git add
-	cmd = exec.Command("git", "-C", tmpDir, "add", ".")
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("git add failed: %w", err)
+// removeGenerated undoes a generated change: a "delete" removes the file if
+// it still has the generated content, and a "remove" cuts the generated
+// snippet out of it. Files that no longer match are left alone.
+func removeGenerated(filePath string, change generator.FileChange) error {
+	if change.Action == "remove" && strings.HasSuffix(filePath, ".go") {
+		return removeGoChange(filePath, change)
+	}
+	if change.Action == "remove" && filepath.Base(filePath) == "package.json" {
+		return removePackageJSONChange(filePath, change)
+	}
+	if change.Action == "remove" && change.Chain != "" {
+		return removeChained(filePath, change)
 	}
 
-	// Git commit
-	commitMsg := getCommitMessage(plan.Mode, hasMetrics, hasOtel)
-	cmd = exec.Command("git", "-C", tmpDir, "commit", "-m", commitMsg)
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("git commit failed: %w", err)
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
 	}
 
-	// Update remote URL with token for authentication
-	authenticatedURL := fmt.Sprintf("https://x-access-token:%s@github.com/%s/%s.git", token, owner, repo)
-	cmd = exec.Command("git", "-C", tmpDir, "remote", "set-url", "origin", authenticatedURL)
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("failed to set remote URL: %w", err)
+	if change.Action == "delete" {
+		if strings.TrimSpace(string(data)) != strings.TrimSpace(change.Content) {
+			return nil
+		}
+		return os.Remove(filePath)
 	}
 
-	// Git push
-	cmd = exec.Command("git", "-C", tmpDir, "push", "-u", "origin", branchName)
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("git push failed: %w", err)
+	// Appended snippets end the file verbatim; inserted ones are whole
+	// lines, possibly re-indented
+	content := string(data)
+	if strings.TrimSpace(change.Content) == "" {
+		return nil
+	}
+	if strings.HasSuffix(content, change.Content) {
+		return os.WriteFile(filePath, []byte(strings.TrimSuffix(content, change.Content)), 0644)
+	}
+
+	lines := strings.Split(content, "\n")
+	snippet := strings.Split(strings.Trim(change.Content, "\n"), "\n")
+	for i := 0; i+len(snippet) <= len(lines); i++ {
+		found := true
+		for j, want := range snippet {
+			if strings.TrimSpace(lines[i+j]) != strings.TrimSpace(want) {
+				found = false
+				break
+			}
+		}
+		if found {
+			out := append(lines[:i:i], lines[i+len(snippet):]...)
+			return os.WriteFile(filePath, []byte(strings.Join(out, "\n")), 0644)
+		}
 	}
+	return nil
+}
 
-	// Create PR via GitHub API
-	prURL, err := createGitHubPR(owner, repo, branchName, commitMsg, plan, token)
+// alreadyApplied reports whether every code line of content is already in
+// the file, so re-running a plan doesn't duplicate earlier instrumentation
+func alreadyApplied(filePath, content string) bool {
+	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to create PR: %w", err)
+		return false
+	}
+
+	found := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "<!--") {
+			continue
+		}
+		if !strings.Contains(string(data), line) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// lineMatcher matches lines containing pattern, or matching it as a
+// regular expression when isRegexp is set
+func lineMatcher(pattern string, isRegexp bool) (func(string) bool, error) {
+	if !isRegexp {
+		return func(line string) bool { return strings.Contains(line, pattern) }, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid line pattern %q: %w", pattern, err)
+	}
+	return re.MatchString, nil
+}
+
+// insertAfterLine inserts content after the first line matching anchor,
+// re-indented to the anchor's block: one level deeper when the anchor
+// opens a block, e.g. `"dependencies": {` or `<dependencies>`, otherwise
+// level with the anchor. A Python or JavaScript anchor continued over
+// several lines is inserted after as a whole.
+func insertAfterLine(filePath, anchor, content string, isRegexp bool) error {
+	matches, err := lineMatcher(anchor, isRegexp)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if matches(line) {
+			return insertLines(filePath, lines, i, content)
+		}
+	}
+
+	return fmt.Errorf("anchor line %q not found", anchor)
+}
+
+// insertLines writes filePath's lines with content inserted after lines[i],
+// re-indented as insertAfterLine describes
+func insertLines(filePath string, lines []string, i int, content string) error {
+	line := lines[i]
+	indent := leadingSpace(line)
+	end := i
+	if continuedStatements[filepath.Ext(filePath)] {
+		// A statement continued over several lines, e.g. an app
+		// constructed with options, is inserted after whole
+		end = statementEnd(lines, i)
+	}
+	if end == i && opensBlock(line) {
+		for _, next := range lines[i+1:] {
+			if strings.TrimSpace(next) == "" {
+				continue
+			}
+			if ni := leadingSpace(next); len(ni) > len(indent) {
+				indent = ni
+			}
+			break
+		}
+	}
+	inserted := reindent(content, indent)
+	out := append([]string{}, lines[:end+1]...)
+	out = append(out, inserted...)
+	out = append(out, lines[end+1:]...)
+	return os.WriteFile(filePath, []byte(strings.Join(out, "\n")), 0644)
+}
+
+// continuedStatements are the extensions of sources whose anchor
+// statements may continue over several lines
+var continuedStatements = map[string]bool{
+	".py": true, ".js": true, ".ts": true, ".mjs": true, ".cjs": true, ".mts": true, ".cts": true,
+}
+
+// statementEnd returns the line the statement starting at lines[start]
+// ends on, following the brackets it leaves open
+func statementEnd(lines []string, start int) int {
+	depth := 0
+	for i := start; i < len(lines); i++ {
+		depth += strings.Count(lines[i], "(") + strings.Count(lines[i], "[") + strings.Count(lines[i], "{")
+		depth -= strings.Count(lines[i], ")") + strings.Count(lines[i], "]") + strings.Count(lines[i], "}")
+		if depth <= 0 {
+			return i
+		}
+	}
+	return start
+}
+
+// leadingSpace returns the indentation of line
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// opensBlock reports whether line opens a brace, bracket or Python block,
+// or is an XML start tag, so the lines after it are nested
+func opensBlock(line string) bool {
+	line = strings.TrimSpace(line)
+	if strings.HasSuffix(line, "{") || strings.HasSuffix(line, "[") || strings.HasSuffix(line, ":") {
+		return true
+	}
+	return strings.HasPrefix(line, "<") && !strings.HasPrefix(line, "</") && !strings.HasPrefix(line, "<!") &&
+		strings.HasSuffix(line, ">") && !strings.HasSuffix(line, "/>") && !strings.Contains(line, "</")
+}
+
+// reindent splits content into lines, trims surrounding blank lines, and
+// replaces the indentation its lines share with indent
+func reindent(content, indent string) []string {
+	lines := strings.Split(strings.Trim(content, "\n"), "\n")
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := len(leadingSpace(line)); common < 0 || n < common {
+			common = n
+		}
+	}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+			continue
+		}
+		lines[i] = indent + line[common:]
+	}
+	return lines
+}
+
+// replaceLines swaps the first line matching change.MatchLine, through the
+// next line matching change.EndLine when that is set, for change.Content.
+// With remove the lines are deleted instead, and finding no match is not an
+// error since they are already gone.
+func replaceLines(filePath string, change generator.FileChange, remove bool) error {
+	matches, err := lineMatcher(change.MatchLine, change.Regexp)
+	if err != nil {
+		return err
+	}
+	matchesEnd := func(string) bool { return false }
+	if change.EndLine != "" {
+		if matchesEnd, err = lineMatcher(change.EndLine, change.Regexp); err != nil {
+			return err
+		}
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if remove && os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if !matches(line) {
+			continue
+		}
+		last := i
+		if change.EndLine != "" {
+			last = -1
+			for j := i; j < len(lines); j++ {
+				if matchesEnd(lines[j]) {
+					last = j
+					break
+				}
+			}
+			if last < 0 {
+				return fmt.Errorf("end line %q not found after %q", change.EndLine, change.MatchLine)
+			}
+		}
+		out := append([]string{}, lines[:i]...)
+		if !remove {
+			out = append(out, reindent(change.Content, leadingSpace(line))...)
+		}
+		out = append(out, lines[last+1:]...)
+		return os.WriteFile(filePath, []byte(strings.Join(out, "\n")), 0644)
 	}
 
-	return prURL, nil
+	if remove {
+		return nil
+	}
+	return fmt.Errorf("line %q not found", change.MatchLine)
 }
 
 func parseRepoURL(url string) (owner, repo string) {
 	// https://github.com/owner/repo.git -> owner, repo
-	parts := strings.Split(strings.TrimSuffix(url, ".git"), "/")
-	if len(parts) >= 2 {
-		owner = parts[len(parts)-2]
+	// https://github.mycorp.com/owner/repo -> owner, repo
+	// https://gitlab.com/group/subgroup/repo -> group/subgroup, repo
+	// git@github.com:owner/repo.git -> owner, repo
+	url = scanner.NormalizeRepoURL(url)
+	url = strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	parts := strings.Split(strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git"), "/")
+	if len(parts) >= 3 {
+		owner = strings.Join(parts[1:len(parts)-1], "/")
 		repo = parts[len(parts)-1]
 	}
 	return
 }
 
-func getBranchName(mode string, hasMetrics, hasOtel bool) string {
+// PlanMode is the telemetry mode plan adds, counting its log correlation,
+// e.g. "metrics+logs" for a metrics plan that also correlates logs
+func PlanMode(plan *generator.InstrumentationPlan) string {
+	metrics, traces, logs, ok := togglespec.ModeSignals(plan.Mode)
+	if !ok {
+		return plan.Mode
+	}
+	return togglespec.ModeFor(metrics, traces, logs || plan.Logs)
+}
+
+// BranchName is the branch CreateInstrumentationPR pushes when adding mode
+// to a service with the given existing instrumentation. Modes that also
+// correlate logs get their own branch, so they don't reuse the branch of
+// an earlier PR adding only metrics or traces.
+func BranchName(mode string, hasMetrics, hasOtel bool) string {
+	if mode == "none" {
+		return "chore/remove-observability"
+	}
+	metrics, traces, logs, _ := togglespec.ModeSignals(mode)
+	if logs {
+		if (!metrics || hasMetrics) && (!traces || hasOtel) {
+			return "feat/correlate-logs-with-traces"
+		}
+		return BranchName(togglespec.ModeFor(metrics, traces, false), hasMetrics, hasOtel) + "-and-log-correlation"
+	}
+
 	if mode == "both" {
 		if hasMetrics && !hasOtel {
 			return "feat/add-opentelemetry-traces"
@@ -168,6 +721,17 @@ func getBranchName(mode string, hasMetrics, hasOtel bool) string {
 }
 
 func getCommitMessage(mode string, hasMetrics, hasOtel bool) string {
+	if mode == "none" {
+		return "chore: Remove observability instrumentation"
+	}
+	metrics, traces, logs, _ := togglespec.ModeSignals(mode)
+	if logs {
+		if (!metrics || hasMetrics) && (!traces || hasOtel) {
+			return "feat: Correlate logs with OpenTelemetry traces"
+		}
+		return getCommitMessage(togglespec.ModeFor(metrics, traces, false), hasMetrics, hasOtel) + " and log correlation"
+	}
+
 	if mode == "both" {
 		if hasMetrics && !hasOtel {
 			return "feat: Add OpenTelemetry distributed tracing"
@@ -187,73 +751,3 @@ func getCommitMessage(mode string, hasMetrics, hasOtel bool) string {
 
 	return "feat: Add observability instrumentation"
 }
-
-func createGitHubPR(owner, repo, branch, title string, plan *generator.InstrumentationPlan, token string) (string, error) {
-	prReq := PRRequest{
-		Title: title,
-		Body:  generatePRBody(plan),
-		Head:  branch,
-		Base:  "main", // or "master" - you could make this configurable
-	}
-
-	body, _ := json.Marshal(prReq)
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls", owner, repo)
-
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(body))
-	req.Header.Set("Authorization", "token "+token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 201 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("GitHub API error: %s", string(bodyBytes))
-	}
-
-	var prResp PRResponse
-	json.NewDecoder(resp.Body).Decode(&prResp)
-
-	return prResp.HTMLURL, nil
-}
-
-func generatePRBody(plan *generator.InstrumentationPlan) string {
-	body := fmt.Sprintf(`## 🔭 Observability Instrumentation
-
-This PR adds **%s** instrumentation to your service.
-
-### Changes Made:
-`, plan.Mode)
-
-	for _, change := range plan.Changes {
-		body += fmt.Sprintf("- Modified `%s` to add %s\n", change.Path, change.Action)
-	}
-
-	body += `
-### What's Included:
-`
-	if strings.Contains(plan.Mode, "metrics") || plan.Mode == "both" {
-		body += "- ✅ Prometheus metrics endpoint (`/metrics`)\n"
-		body += "- ✅ HTTP request counters and histograms\n"
-	}
-
-	if strings.Contains(plan.Mode, "traces") || plan.Mode == "both" {
-		body += "- ✅ OpenTelemetry distributed tracing\n"
-		body += "- ✅ Automatic span creation for HTTP requests\n"
-		body += "- ✅ Integration with OTel Collector\n"
-	}
-
-	body += `
-### Next Steps:
-1. Review the changes
-2. Test locally
-3. Merge when ready
-
-**Generated by Observability Copilot** 🚀
-`
-	return body
-}
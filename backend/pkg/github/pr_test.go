@@ -0,0 +1,141 @@
+package github
+
+import (
+	"testing"
+
+	"observability-copilot/pkg/generator"
+)
+
+func TestParseRepoURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		wantOwner string
+		wantRepo  string
+	}{
+		{name: "https", url: "https://github.com/owner/repo", wantOwner: "owner", wantRepo: "repo"},
+		{name: "https with git suffix", url: "https://github.com/owner/repo.git", wantOwner: "owner", wantRepo: "repo"},
+		{name: "https with trailing slash", url: "https://github.com/owner/repo/", wantOwner: "owner", wantRepo: "repo"},
+		{name: "enterprise host", url: "https://github.mycorp.com/owner/repo", wantOwner: "owner", wantRepo: "repo"},
+		{name: "gitlab subgroup", url: "https://gitlab.com/group/subgroup/repo", wantOwner: "group/subgroup", wantRepo: "repo"},
+		{name: "scp-like ssh", url: "git@github.com:owner/repo.git", wantOwner: "owner", wantRepo: "repo"},
+		{name: "scp-like ssh without suffix", url: "git@github.com:owner/repo", wantOwner: "owner", wantRepo: "repo"},
+		{name: "ssh scheme", url: "ssh://git@github.com/owner/repo.git", wantOwner: "owner", wantRepo: "repo"},
+		{name: "ssh scheme with port", url: "ssh://git@gitlab.com:2222/group/repo.git", wantOwner: "group", wantRepo: "repo"},
+		{name: "missing repo", url: "https://github.com/owner", wantOwner: "", wantRepo: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, repo := parseRepoURL(tt.url)
+			if owner != tt.wantOwner || repo != tt.wantRepo {
+				t.Errorf("parseRepoURL(%q) = %q, %q, want %q, %q", tt.url, owner, repo, tt.wantOwner, tt.wantRepo)
+			}
+		})
+	}
+}
+
+func TestBranchNameAndCommitMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		hasMetrics  bool
+		hasOtel     bool
+		wantBranch  string
+		wantMessage string
+	}{
+		{name: "both", mode: "both", wantBranch: "feat/add-observability", wantMessage: "feat: Add observability with Prometheus and OpenTelemetry"},
+		{name: "both with metrics", mode: "both", hasMetrics: true, wantBranch: "feat/add-opentelemetry-traces", wantMessage: "feat: Add OpenTelemetry distributed tracing"},
+		{name: "metrics", mode: "metrics", wantBranch: "feat/add-prometheus-metrics", wantMessage: "feat: Add Prometheus metrics instrumentation"},
+		{name: "traces", mode: "traces", wantBranch: "feat/add-opentelemetry-traces", wantMessage: "feat: Add OpenTelemetry distributed tracing"},
+		{name: "logs", mode: "logs", hasOtel: true, wantBranch: "feat/correlate-logs-with-traces", wantMessage: "feat: Correlate logs with OpenTelemetry traces"},
+		{name: "none", mode: "none", hasMetrics: true, wantBranch: "chore/remove-observability", wantMessage: "chore: Remove observability instrumentation"},
+		{
+			name:        "all",
+			mode:        "all",
+			wantBranch:  "feat/add-observability-and-log-correlation",
+			wantMessage: "feat: Add observability with Prometheus and OpenTelemetry and log correlation",
+		},
+		{
+			name:        "all with metrics",
+			mode:        "all",
+			hasMetrics:  true,
+			wantBranch:  "feat/add-opentelemetry-traces-and-log-correlation",
+			wantMessage: "feat: Add OpenTelemetry distributed tracing and log correlation",
+		},
+		{
+			name:        "metrics+logs",
+			mode:        "metrics+logs",
+			hasOtel:     true,
+			wantBranch:  "feat/add-prometheus-metrics-and-log-correlation",
+			wantMessage: "feat: Add Prometheus metrics instrumentation and log correlation",
+		},
+		{
+			name:        "traces+logs",
+			mode:        "traces+logs",
+			wantBranch:  "feat/add-opentelemetry-traces-and-log-correlation",
+			wantMessage: "feat: Add OpenTelemetry distributed tracing and log correlation",
+		},
+		{
+			name:        "metrics+logs with metrics",
+			mode:        "metrics+logs",
+			hasMetrics:  true,
+			wantBranch:  "feat/correlate-logs-with-traces",
+			wantMessage: "feat: Correlate logs with OpenTelemetry traces",
+		},
+		{
+			name:        "all with metrics and traces",
+			mode:        "all",
+			hasMetrics:  true,
+			hasOtel:     true,
+			wantBranch:  "feat/correlate-logs-with-traces",
+			wantMessage: "feat: Correlate logs with OpenTelemetry traces",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BranchName(tt.mode, tt.hasMetrics, tt.hasOtel); got != tt.wantBranch {
+				t.Errorf("BranchName(%q, %t, %t) = %q, want %q", tt.mode, tt.hasMetrics, tt.hasOtel, got, tt.wantBranch)
+			}
+			if got := getCommitMessage(tt.mode, tt.hasMetrics, tt.hasOtel); got != tt.wantMessage {
+				t.Errorf("getCommitMessage(%q, %t, %t) = %q, want %q", tt.mode, tt.hasMetrics, tt.hasOtel, got, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestBranchNameCombinedModesDiffer(t *testing.T) {
+	// A PR adding metrics and traces, then one adding them with logs, must
+	// not push to the same branch
+	both := BranchName("both", false, false)
+	for _, mode := range []string{"metrics+logs", "traces+logs", "all"} {
+		if got := BranchName(mode, false, false); got == both {
+			t.Errorf("BranchName(%q) = %q, the same branch as both", mode, got)
+		}
+	}
+}
+
+func TestPlanMode(t *testing.T) {
+	tests := []struct {
+		name string
+		plan generator.InstrumentationPlan
+		want string
+	}{
+		{name: "metrics", plan: generator.InstrumentationPlan{Mode: "metrics"}, want: "metrics"},
+		{name: "metrics with logs", plan: generator.InstrumentationPlan{Mode: "metrics", Logs: true}, want: "metrics+logs"},
+		{name: "traces with logs", plan: generator.InstrumentationPlan{Mode: "traces", Logs: true}, want: "traces+logs"},
+		{name: "both with logs", plan: generator.InstrumentationPlan{Mode: "both", Logs: true}, want: "all"},
+		{name: "logs", plan: generator.InstrumentationPlan{Mode: "logs", Logs: true}, want: "logs"},
+		{name: "already instrumented", plan: generator.InstrumentationPlan{Mode: "all"}, want: "all"},
+		{name: "none", plan: generator.InstrumentationPlan{Mode: "none"}, want: "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlanMode(&tt.plan); got != tt.want {
+				t.Errorf("PlanMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"observability-copilot/pkg/generator"
+)
+
+// previewContext is how many unchanged lines surround a change's preview
+const previewContext = 3
+
+// ChangePreview is a plan change with the file as it was before and after
+// applying it
+type ChangePreview struct {
+	generator.FileChange
+
+	Before string `json:"before"`
+	After  string `json:"after"`
+
+	// InsertLine is the 1-based line of the result where the change starts,
+	// or 0 when applying it leaves the file unchanged
+	InsertLine int `json:"insert_line"`
+
+	// Preview is the changed region of the result with a few lines of
+	// context, starting at line PreviewStart
+	Preview      string `json:"preview"`
+	PreviewStart int    `json:"preview_start"`
+}
+
+// PlanPreview is an instrumentation plan with each change rendered
+type PlanPreview struct {
+	*generator.InstrumentationPlan
+	Changes []ChangePreview `json:"changes"`
+}
+
+// PreviewPlan applies plan to the working copy in dir one change at a time,
+// recording each file before and after the change. The files in dir are
+// modified, so dir should be a throwaway checkout.
+func PreviewPlan(dir string, plan *generator.InstrumentationPlan) (*PlanPreview, error) {
+	preview := &PlanPreview{InstrumentationPlan: plan, Changes: []ChangePreview{}}
+	for _, change := range plan.Changes {
+		filePath := filepath.Join(dir, change.Path)
+		before, _ := os.ReadFile(filePath)
+
+		single := *plan
+		single.Changes = []generator.FileChange{change}
+		if err := applyPlan(dir, &single); err != nil {
+			return nil, err
+		}
+		after, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		cp := ChangePreview{FileChange: change, Before: string(before), After: string(after)}
+		cp.InsertLine, cp.PreviewStart, cp.Preview = changedRegion(cp.Before, cp.After)
+		preview.Changes = append(preview.Changes, cp)
+	}
+	return preview, nil
+}
+
+// changedRegion finds the lines of after that differ from before by
+// trimming their common prefix and suffix, and renders that region with
+// previewContext lines around it
+func changedRegion(before, after string) (insertLine, start int, region string) {
+	if before == after {
+		return 0, 0, ""
+	}
+	old := strings.Split(before, "\n")
+	lines := strings.Split(after, "\n")
+
+	prefix := 0
+	for prefix < len(old) && prefix < len(lines) && old[prefix] == lines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(lines)-prefix &&
+		old[len(old)-1-suffix] == lines[len(lines)-1-suffix] {
+		suffix++
+	}
+
+	from := prefix - previewContext
+	if from < 0 {
+		from = 0
+	}
+	to := len(lines) - suffix + previewContext
+	if to > len(lines) {
+		to = len(lines)
+	}
+	return prefix + 1, from + 1, strings.Join(lines[from:to], "\n")
+}
@@ -0,0 +1,472 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"observability-copilot/pkg/logging"
+	"observability-copilot/pkg/scanner"
+)
+
+// Provider is the code host a repo lives on. Cloning and applying changes
+// are shared; only remote auth and the host's API calls differ.
+type Provider interface {
+	// Name identifies the provider in errors, e.g. "GitHub"
+	Name() string
+
+	// Token returns the API token, or an error naming the unset variable
+	Token() (string, error)
+
+	// Auth returns git transport credentials for repoURL, or nil when no
+	// token is set or the URL isn't on this host
+	Auth(repoURL string) transport.AuthMethod
+
+	// CreatePR opens a pull (or merge) request and returns its web URL
+	// and number
+	CreatePR(ctx context.Context, owner, repo string, pr PRRequest) (*PRResponse, error)
+
+	// RequestReviewers asks reviewers to review PR number
+	RequestReviewers(ctx context.Context, owner, repo string, number int, reviewers []string) error
+
+	// AddLabels adds labels to PR number
+	AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error
+
+	// ListBranches returns the names of the repo's branches
+	ListBranches(ctx context.Context, owner, repo string) ([]string, error)
+
+	// FindOpenPR returns the web URL of an open PR from head, or ""
+	FindOpenPR(ctx context.Context, owner, repo, head string) (string, error)
+}
+
+// providerFor picks the provider from the repo URL's host. Hosts named
+// gitlab.* or matching the GitLab host use GitLab; everything else is GitHub.
+func providerFor(repoURL string) Provider {
+	host := repoHost(repoURL)
+	if host == gitlabHost() || strings.HasPrefix(host, "gitlab.") {
+		return gitlabProvider{host: host}
+	}
+	return githubProvider{}
+}
+
+// ListBranches lists the branches of repoURL on its provider, giving up
+// after the clone timeout. Missing, private and unreachable repos fail with
+// a scanner.CloneError, as their clones would.
+func ListBranches(repoURL string) ([]string, error) {
+	repoURL = scanner.NormalizeRepoURL(repoURL)
+	owner, repo := parseRepoURL(repoURL)
+	if owner == "" || repo == "" {
+		return nil, fmt.Errorf("invalid repo URL: %s", repoURL)
+	}
+
+	timeout := scanner.CloneTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	branches, err := providerFor(repoURL).ListBranches(ctx, owner, repo)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("listing branches timed out after %s", timeout)
+	}
+	return branches, classifyAPIError(err, repoURL)
+}
+
+// classifyAPIError turns a provider API call about repoURL that failed
+// like a clone would, because the repo is missing, private or out of
+// reach, into the scanner's CloneError
+func classifyAPIError(err error, repoURL string) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case 404:
+			return &scanner.CloneError{Kind: scanner.ErrRepoNotFound, URL: repoURL, Err: err}
+		case 401, 403:
+			return &scanner.CloneError{Kind: scanner.ErrAuthRequired, URL: repoURL, Err: err}
+		}
+		return err
+	}
+	return scanner.ClassifyGitError(err, repoURL, "")
+}
+
+// repoHost returns the host of an https or SSH repo URL
+func repoHost(repoURL string) string {
+	repoURL = scanner.NormalizeRepoURL(repoURL)
+	rest := strings.TrimPrefix(strings.TrimPrefix(repoURL, "https://"), "http://")
+	return strings.SplitN(rest, "/", 2)[0]
+}
+
+// APIError is a non-success response from a provider's API
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error (%d): %s", e.StatusCode, e.Body)
+}
+
+// RateLimitError is returned when the API still rate limits the token
+// after every retry
+type RateLimitError struct {
+	APIError
+	RetryAfter time.Duration
+	Attempts   int
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("API rate limit exceeded after %d attempts, retry after %s: %s", e.Attempts, e.RetryAfter, e.Body)
+}
+
+// maxAttempts is how many times a rate-limited API call is tried, the
+// configured MaxAttempts or 4
+func maxAttempts() int {
+	if settings.MaxAttempts > 0 {
+		return settings.MaxAttempts
+	}
+	return 4
+}
+
+// maxBackoff caps a single wait between rate-limited attempts
+const maxBackoff = time.Minute
+
+// doJSON sends an authenticated API request and decodes a JSON response.
+// Rate-limited responses are retried with exponential backoff, honouring
+// Retry-After and rate limit reset headers. The request and any waits are
+// abandoned when ctx is done. A nil out discards the response body.
+func doJSON(ctx context.Context, method, url string, body interface{}, headers map[string]string, wantStatus int, out interface{}) error {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+
+	attempts := maxAttempts()
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader
+		if data != nil {
+			reader = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reader)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == wantStatus {
+			defer resp.Body.Close()
+			if out == nil {
+				return nil
+			}
+			return json.NewDecoder(resp.Body).Decode(out)
+		}
+
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		apiErr := APIError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+
+		wait, limited := rateLimitWait(resp, backoff)
+		if !limited {
+			return &apiErr
+		}
+		if attempt >= attempts {
+			return &RateLimitError{APIError: apiErr, RetryAfter: wait, Attempts: attempt}
+		}
+
+		logging.FromContext(ctx).Warn("rate limited, retrying", "status", resp.StatusCode, "wait", wait.String(), "attempt", attempt, "max_attempts", attempts)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// rateLimitWait reports whether resp is a rate limit response and how long
+// to wait before retrying, falling back to backoff when no header says
+func rateLimitWait(resp *http.Response, backoff time.Duration) (time.Duration, bool) {
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	if remaining == "" {
+		remaining = resp.Header.Get("RateLimit-Remaining")
+	}
+	retryAfter := resp.Header.Get("Retry-After")
+
+	limited := resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && (retryAfter != "" || remaining == "0"))
+	if !limited {
+		return 0, false
+	}
+
+	wait := backoff
+	if secs, err := strconv.Atoi(retryAfter); err == nil {
+		wait = time.Duration(secs) * time.Second
+	} else if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil && remaining == "0" {
+		wait = time.Until(time.Unix(reset, 0))
+	}
+	if wait < backoff {
+		wait = backoff
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait, true
+}
+
+// githubProvider talks to github.com or a GitHub Enterprise Server
+type githubProvider struct{}
+
+func (githubProvider) Name() string { return "GitHub" }
+
+func (githubProvider) Token() (string, error) {
+	token := settings.GitHubToken
+	if token == "" {
+		return "", fmt.Errorf("GITHUB_TOKEN not set")
+	}
+	return token, nil
+}
+
+func (p githubProvider) Auth(repoURL string) transport.AuthMethod {
+	token, _ := p.Token()
+	return scanner.GitHubAuth(repoURL, token)
+}
+
+func (p githubProvider) headers() map[string]string {
+	token, _ := p.Token()
+	return map[string]string{
+		"Authorization": "token " + token,
+		"Accept":        "application/vnd.github.v3+json",
+	}
+}
+
+func (p githubProvider) CreatePR(ctx context.Context, owner, repo string, pr PRRequest) (*PRResponse, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls", apiBaseURL(), owner, repo)
+
+	var prResp PRResponse
+	if err := doJSON(ctx, "POST", url, pr, p.headers(), 201, &prResp); err != nil {
+		return nil, fmt.Errorf("GitHub %w", err)
+	}
+	return &prResp, nil
+}
+
+// RequestReviewers requests users by login and teams written "org/team"
+func (p githubProvider) RequestReviewers(ctx context.Context, owner, repo string, number int, reviewers []string) error {
+	body := struct {
+		Reviewers     []string `json:"reviewers,omitempty"`
+		TeamReviewers []string `json:"team_reviewers,omitempty"`
+	}{}
+	for _, r := range reviewers {
+		if _, team, ok := strings.Cut(r, "/"); ok {
+			body.TeamReviewers = append(body.TeamReviewers, team)
+		} else {
+			body.Reviewers = append(body.Reviewers, r)
+		}
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/requested_reviewers", apiBaseURL(), owner, repo, number)
+	if err := doJSON(ctx, "POST", url, body, p.headers(), 201, nil); err != nil {
+		return fmt.Errorf("GitHub %w", err)
+	}
+	return nil
+}
+
+// AddLabels adds labels through the issues API, creating missing ones
+func (p githubProvider) AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/labels", apiBaseURL(), owner, repo, number)
+	body := map[string][]string{"labels": labels}
+	if err := doJSON(ctx, "POST", url, body, p.headers(), 200, nil); err != nil {
+		return fmt.Errorf("GitHub %w", err)
+	}
+	return nil
+}
+
+func (p githubProvider) ListBranches(ctx context.Context, owner, repo string) ([]string, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/branches?per_page=100", apiBaseURL(), owner, repo)
+
+	var branches []struct {
+		Name string `json:"name"`
+	}
+	if err := doJSON(ctx, "GET", url, nil, p.headers(), 200, &branches); err != nil {
+		return nil, fmt.Errorf("GitHub %w", err)
+	}
+
+	names := []string{}
+	for _, b := range branches {
+		names = append(names, b.Name)
+	}
+	return names, nil
+}
+
+func (p githubProvider) FindOpenPR(ctx context.Context, owner, repo, head string) (string, error) {
+	// head must be qualified with the owner of the branch's repo
+	ownerLogin := owner
+	if i := strings.LastIndex(owner, "/"); i >= 0 {
+		ownerLogin = owner[i+1:]
+	}
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/pulls?state=open&head=%s", apiBaseURL(), owner, repo, url.QueryEscape(ownerLogin+":"+head))
+
+	var prs []PRResponse
+	if err := doJSON(ctx, "GET", endpoint, nil, p.headers(), 200, &prs); err != nil {
+		return "", fmt.Errorf("GitHub %w", err)
+	}
+	if len(prs) == 0 {
+		return "", nil
+	}
+	return prs[0].HTMLURL, nil
+}
+
+// apiBaseURL returns the REST API root: the configured GitHubAPIURL, the
+// Enterprise Server API under the GitHub host, or the public GitHub API
+func apiBaseURL() string {
+	if settings.GitHubAPIURL != "" {
+		return strings.TrimSuffix(settings.GitHubAPIURL, "/")
+	}
+	if host := scanner.GitHubHost(); host != "github.com" {
+		return "https://" + host + "/api/v3"
+	}
+	return "https://api.github.com"
+}
+
+// gitlabProvider talks to gitlab.com or a self-managed GitLab
+type gitlabProvider struct {
+	host string
+}
+
+// gitlabHost is the configured self-managed GitLab host, or "gitlab.com"
+func gitlabHost() string {
+	if settings.GitLabHost != "" {
+		return settings.GitLabHost
+	}
+	return "gitlab.com"
+}
+
+func (gitlabProvider) Name() string { return "GitLab" }
+
+func (gitlabProvider) Token() (string, error) {
+	token := settings.GitLabToken
+	if token == "" {
+		return "", fmt.Errorf("GITLAB_TOKEN not set")
+	}
+	return token, nil
+}
+
+func (p gitlabProvider) Auth(repoURL string) transport.AuthMethod {
+	token, _ := p.Token()
+	if token == "" || !strings.HasPrefix(repoURL, "https://"+p.host+"/") {
+		return nil
+	}
+	return &githttp.BasicAuth{Username: "oauth2", Password: token}
+}
+
+// projectURL is the API URL of a project, addressed by its escaped path
+func (p gitlabProvider) projectURL(owner, repo string) string {
+	return fmt.Sprintf("https://%s/api/v4/projects/%s", p.host, url.PathEscape(owner+"/"+repo))
+}
+
+func (p gitlabProvider) headers() map[string]string {
+	token, _ := p.Token()
+	return map[string]string{"PRIVATE-TOKEN": token}
+}
+
+func (p gitlabProvider) CreatePR(ctx context.Context, owner, repo string, pr PRRequest) (*PRResponse, error) {
+	// GitLab marks merge requests as drafts by their title
+	title := pr.Title
+	if pr.Draft {
+		title = "Draft: " + title
+	}
+	mr := map[string]string{
+		"title":         title,
+		"description":   pr.Body,
+		"source_branch": pr.Head,
+		"target_branch": pr.Base,
+	}
+
+	var mrResp struct {
+		WebURL string `json:"web_url"`
+		IID    int    `json:"iid"`
+	}
+	if err := doJSON(ctx, "POST", p.projectURL(owner, repo)+"/merge_requests", mr, p.headers(), 201, &mrResp); err != nil {
+		return nil, fmt.Errorf("GitLab %w", err)
+	}
+	return &PRResponse{HTMLURL: mrResp.WebURL, Number: mrResp.IID}, nil
+}
+
+// RequestReviewers looks up each username's ID, as merge requests take
+// reviewers by ID
+func (p gitlabProvider) RequestReviewers(ctx context.Context, owner, repo string, number int, reviewers []string) error {
+	ids := []int{}
+	for _, username := range reviewers {
+		var users []struct {
+			ID int `json:"id"`
+		}
+		endpoint := fmt.Sprintf("https://%s/api/v4/users?username=%s", p.host, url.QueryEscape(username))
+		if err := doJSON(ctx, "GET", endpoint, nil, p.headers(), 200, &users); err != nil {
+			return fmt.Errorf("GitLab %w", err)
+		}
+		if len(users) == 0 {
+			return fmt.Errorf("GitLab user %q not found", username)
+		}
+		ids = append(ids, users[0].ID)
+	}
+
+	endpoint := fmt.Sprintf("%s/merge_requests/%d", p.projectURL(owner, repo), number)
+	if err := doJSON(ctx, "PUT", endpoint, map[string][]int{"reviewer_ids": ids}, p.headers(), 200, nil); err != nil {
+		return fmt.Errorf("GitLab %w", err)
+	}
+	return nil
+}
+
+func (p gitlabProvider) AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error {
+	endpoint := fmt.Sprintf("%s/merge_requests/%d", p.projectURL(owner, repo), number)
+	body := map[string]string{"add_labels": strings.Join(labels, ",")}
+	if err := doJSON(ctx, "PUT", endpoint, body, p.headers(), 200, nil); err != nil {
+		return fmt.Errorf("GitLab %w", err)
+	}
+	return nil
+}
+
+func (p gitlabProvider) FindOpenPR(ctx context.Context, owner, repo, head string) (string, error) {
+	var mrs []struct {
+		WebURL string `json:"web_url"`
+	}
+	if err := doJSON(ctx, "GET", p.projectURL(owner, repo)+"/merge_requests?state=opened&source_branch="+url.QueryEscape(head), nil, p.headers(), 200, &mrs); err != nil {
+		return "", fmt.Errorf("GitLab %w", err)
+	}
+	if len(mrs) == 0 {
+		return "", nil
+	}
+	return mrs[0].WebURL, nil
+}
+
+func (p gitlabProvider) ListBranches(ctx context.Context, owner, repo string) ([]string, error) {
+	var branches []struct {
+		Name string `json:"name"`
+	}
+	if err := doJSON(ctx, "GET", p.projectURL(owner, repo)+"/repository/branches?per_page=100", nil, p.headers(), 200, &branches); err != nil {
+		return nil, fmt.Errorf("GitLab %w", err)
+	}
+
+	names := []string{}
+	for _, b := range branches {
+		names = append(names, b.Name)
+	}
+	return names, nil
+}
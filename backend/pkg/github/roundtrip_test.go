@@ -0,0 +1,298 @@
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"observability-copilot/pkg/generator"
+	"observability-copilot/pkg/scanner"
+)
+
+// readTree returns the contents of every file under dir by relative path
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// writeTree writes files, by relative path, under dir
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// instrument scans dir, generates mode's plan for its only service, as the
+// PR flow does, and applies it
+func instrument(t *testing.T, dir, mode string) *generator.InstrumentationPlan {
+	t.Helper()
+	result, err := scanner.ScanLocalPath(dir, "")
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(result.Detections) != 1 {
+		t.Fatalf("scan found %d services, want 1", len(result.Detections))
+	}
+	d := result.Detections[0]
+	plan, err := generator.Generate(d.Language, d.ServiceName, mode, d.Candidates, generator.Options{
+		Dir:          d.Path,
+		WebFramework: d.Framework,
+		ModulePath:   d.ModulePath,
+	})
+	if err != nil {
+		t.Fatalf("generate %s: %v", mode, err)
+	}
+	if err := applyPlan(dir, plan); err != nil {
+		t.Fatalf("apply %s: %v", mode, err)
+	}
+	return plan
+}
+
+// diffTrees reports the files that differ between got and want
+func diffTrees(t *testing.T, got, want map[string]string) {
+	t.Helper()
+	names := map[string]bool{}
+	for name := range got {
+		names[name] = true
+	}
+	for name := range want {
+		names[name] = true
+	}
+	sorted := []string{}
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		g, inGot := got[name]
+		w, inWant := want[name]
+		switch {
+		case !inWant:
+			t.Errorf("%s left behind:\n%s", name, g)
+		case !inGot:
+			t.Errorf("%s missing, want:\n%s", name, w)
+		case g != w:
+			t.Errorf("%s =\n%s\nwant\n%s", name, g, w)
+		}
+	}
+}
+
+func TestPythonReinstrument(t *testing.T) {
+	apps := []struct {
+		name  string
+		files map[string]string
+	}{
+		{
+			name: "flask",
+			files: map[string]string{
+				"requirements.txt": "flask==3.0.0\n",
+				"app.py":           "from flask import Flask\n\napp = Flask(__name__)\n\n@app.route('/')\ndef index():\n    return 'ok'\n\nif __name__ == '__main__':\n    app.run()\n",
+			},
+		},
+		{
+			name: "fastapi",
+			files: map[string]string{
+				"requirements.txt": "fastapi==0.110.0\nuvicorn==0.29.0\n",
+				"main.py":          "from fastapi import FastAPI\n\napp = FastAPI()\n\n@app.get('/')\ndef index():\n    return {'ok': True}\n",
+			},
+		},
+	}
+	sequences := []struct {
+		name  string
+		modes []string
+	}{
+		{name: "instrument twice", modes: []string{"both", "both"}},
+		{name: "instrument then remove", modes: []string{"both", "none"}},
+	}
+
+	for _, app := range apps {
+		for _, seq := range sequences {
+			t.Run(app.name+"/"+seq.name, func(t *testing.T) {
+				dir := t.TempDir()
+				writeTree(t, dir, app.files)
+
+				instrument(t, dir, seq.modes[0])
+				instrumented := readTree(t, dir)
+				if _, ok := instrumented["metrics_config.py"]; !ok {
+					t.Fatal("first run generated no metrics_config.py")
+				}
+
+				instrument(t, dir, seq.modes[1])
+				want := instrumented
+				if seq.modes[1] == "none" {
+					want = app.files
+				}
+				diffTrees(t, readTree(t, dir), want)
+			})
+		}
+	}
+}
+
+const (
+	netHTTPDefaultMux = "package main\n\nimport \"net/http\"\n\nfunc main() {\n\thttp.HandleFunc(\"/\", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(\"ok\")) })\n\thttp.ListenAndServe(\":8080\", nil)\n}\n"
+
+	netHTTPOwnMux = "package main\n\nimport \"net/http\"\n\nfunc main() {\n\tmux := http.NewServeMux()\n\tmux.HandleFunc(\"/\", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(\"ok\")) })\n\thttp.ListenAndServe(\":8080\", mux)\n}\n"
+
+	netHTTPServer = "package main\n\nimport \"net/http\"\n\nfunc main() {\n\tmux := http.NewServeMux()\n\tmux.HandleFunc(\"/\", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(\"ok\")) })\n\tsrv := &http.Server{Addr: \":8080\", Handler: mux}\n\tsrv.ListenAndServe()\n}\n"
+
+	netHTTPUnservedMux = "package main\n\nimport \"net/http\"\n\nfunc main() {\n\tapi := http.NewServeMux()\n\tapi.HandleFunc(\"/\", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(\"ok\")) })\n\thttp.Handle(\"/api/\", http.StripPrefix(\"/api\", api))\n\thttp.ListenAndServe(\":8080\", nil)\n}\n"
+)
+
+// TestGoNetHTTPMetricsRoute checks the /metrics endpoint is registered on
+// the mux the server serves, which the metrics and tracing wrappers wrap
+func TestGoNetHTTPMetricsRoute(t *testing.T) {
+	tests := []struct {
+		name      string
+		main      string
+		want      string
+		wantAfter string // the statement the route follows
+	}{
+		{
+			name:      "default mux",
+			main:      netHTTPDefaultMux,
+			want:      `http.Handle("/metrics", promhttp.Handler())`,
+			wantAfter: "func main() {",
+		},
+		{
+			name:      "own mux",
+			main:      netHTTPOwnMux,
+			want:      `mux.Handle("/metrics", promhttp.Handler())`,
+			wantAfter: "mux := http.NewServeMux()",
+		},
+		{
+			name:      "own mux in an http.Server",
+			main:      netHTTPServer,
+			want:      `mux.Handle("/metrics", promhttp.Handler())`,
+			wantAfter: "mux := http.NewServeMux()",
+		},
+		{
+			name:      "mux mounted on the default mux",
+			main:      netHTTPUnservedMux,
+			want:      `http.Handle("/metrics", promhttp.Handler())`,
+			wantAfter: "func main() {",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, map[string]string{
+				"go.mod":  "module example.com/api\n\ngo 1.21\n",
+				"main.go": tt.main,
+			})
+			instrument(t, dir, "metrics")
+
+			main := readTree(t, dir)["main.go"]
+			if strings.Count(main, `"/metrics"`) != 1 || !strings.Contains(main, tt.want) {
+				t.Fatalf("main.go registers /metrics other than with %s:\n%s", tt.want, main)
+			}
+			route := strings.Index(main, tt.want)
+			if after := strings.Index(main, tt.wantAfter); after < 0 || after > route {
+				t.Errorf("route not registered after %q:\n%s", tt.wantAfter, main)
+			}
+			if !strings.Contains(main, "httpmetrics.Middleware(") {
+				t.Errorf("served handler not wrapped in the metrics middleware:\n%s", main)
+			}
+		})
+	}
+}
+
+func TestGoInstrumentThenRemove(t *testing.T) {
+	tests := []struct {
+		name    string
+		require string
+		main    string
+	}{
+		{
+			name:    "gin",
+			require: "github.com/gin-gonic/gin v1.9.1",
+			main:    "package main\n\nimport \"github.com/gin-gonic/gin\"\n\nfunc main() {\n\tr := gin.Default()\n\tr.GET(\"/\", func(c *gin.Context) { c.String(200, \"ok\") })\n\tr.Run(\":8080\")\n}\n",
+		},
+		{
+			name:    "echo",
+			require: "github.com/labstack/echo/v4 v4.11.3",
+			main:    "package main\n\nimport (\n\t\"net/http\"\n\n\t\"github.com/labstack/echo/v4\"\n)\n\nfunc main() {\n\te := echo.New()\n\te.GET(\"/\", func(c echo.Context) error { return c.String(http.StatusOK, \"ok\") })\n\te.Logger.Fatal(e.Start(\":8080\"))\n}\n",
+		},
+		{
+			name:    "chi",
+			require: "github.com/go-chi/chi/v5 v5.0.10",
+			main:    "package main\n\nimport (\n\t\"net/http\"\n\n\t\"github.com/go-chi/chi/v5\"\n)\n\nfunc main() {\n\tr := chi.NewRouter()\n\tr.Get(\"/\", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(\"ok\")) })\n\thttp.ListenAndServe(\":8080\", r)\n}\n",
+		},
+		{
+			name: "net/http default mux",
+			main: netHTTPDefaultMux,
+		},
+		{
+			name: "net/http own mux",
+			main: netHTTPOwnMux,
+		},
+		{
+			name: "net/http server",
+			main: netHTTPServer,
+		},
+		{
+			name: "net/http server on default mux",
+			main: "package main\n\nimport \"net/http\"\n\nfunc main() {\n\thttp.HandleFunc(\"/\", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(\"ok\")) })\n\tsrv := &http.Server{Addr: \":8080\"}\n\tsrv.ListenAndServe()\n}\n",
+		},
+		{
+			name: "net/http multi-line server on default mux",
+			main: "package main\n\nimport (\n\t\"net/http\"\n\t\"time\"\n)\n\nfunc main() {\n\thttp.HandleFunc(\"/\", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(\"ok\")) })\n\tsrv := &http.Server{\n\t\tAddr:        \":8080\",\n\t\tReadTimeout: 5 * time.Second,\n\t}\n\tsrv.ListenAndServe()\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			gomod := "module example.com/api\n\ngo 1.21\n"
+			if tt.require != "" {
+				gomod += "\nrequire " + tt.require + "\n"
+			}
+			original := map[string]string{
+				"go.mod":  gomod,
+				"main.go": tt.main,
+			}
+			writeTree(t, dir, original)
+
+			instrument(t, dir, "both")
+			instrumented := readTree(t, dir)
+			if !strings.Contains(instrumented["main.go"], `"/metrics"`) {
+				t.Fatalf("instrumented main.go serves no /metrics route:\n%s", instrumented["main.go"])
+			}
+
+			instrument(t, dir, "none")
+			// go.mod keeps the requirements until go mod tidy drops them
+			got := readTree(t, dir)
+			delete(got, "go.mod")
+			delete(original, "go.mod")
+			diffTrees(t, got, original)
+		})
+	}
+}
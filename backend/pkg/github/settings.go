@@ -0,0 +1,52 @@
+package github
+
+import "time"
+
+// Settings hold the code host credentials and API options. The GitHub host
+// itself is shared with the scanner, see scanner.Settings.
+type Settings struct {
+	GitHubToken string
+	// GitHubAPIURL overrides the REST API root, e.g. for a proxy
+	GitHubAPIURL string
+
+	GitLabToken string
+	// GitLabHost is a self-managed GitLab host, or "" for gitlab.com
+	GitLabHost string
+
+	// MaxAttempts is how many times a rate-limited API call is tried,
+	// 0 for the default
+	MaxAttempts int
+
+	// SignCommits signs instrumentation commits with the GPG private key
+	// exported to SigningKeyFile, selected by SigningKeyID when the file
+	// holds several and unlocked with SigningPassphrase
+	SignCommits       bool
+	SigningKeyFile    string
+	SigningKeyID      string
+	SigningPassphrase string
+
+	// AuthorName and AuthorEmail replace the bot identity instrumentation
+	// commits are attributed to
+	AuthorName  string
+	AuthorEmail string
+
+	// FormatCode runs the language's formatters, such as black or
+	// prettier, over the files a plan creates before they are committed
+	FormatCode bool
+
+	// CompileJava compiles changed Maven projects, which runs their build
+	// plugins, as validation
+	CompileJava bool
+
+	// DefaultBranchTTL is how long a repo's default branch is remembered,
+	// 0 for an hour
+	DefaultBranchTTL time.Duration
+}
+
+var settings Settings
+
+// Configure sets the package's settings. It is meant to be called once at
+// startup, before any PR is created.
+func Configure(s Settings) {
+	settings = s
+}
@@ -0,0 +1,92 @@
+package github
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// signingKey loads the configured GPG key for signing commits, decrypted
+// with the passphrase. It returns nil when signing is off, and an error
+// naming the problem when signing is on but the key can't be used.
+func signingKey() (*openpgp.Entity, error) {
+	if !settings.SignCommits {
+		return nil, nil
+	}
+	if settings.SigningKeyFile == "" {
+		return nil, fmt.Errorf("commit signing is enabled but GIT_SIGNING_KEY_FILE is not set")
+	}
+	data, err := os.ReadFile(settings.SigningKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("commit signing key unavailable: %w", err)
+	}
+
+	// Accept an ASCII-armored export, falling back to a binary one
+	keys, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil {
+		if keys, err = openpgp.ReadKeyRing(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("failed to read commit signing key %s: %w", settings.SigningKeyFile, err)
+		}
+	}
+
+	key := findSigningKey(keys, settings.SigningKeyID)
+	if key == nil {
+		if settings.SigningKeyID != "" {
+			return nil, fmt.Errorf("no private key with ID %s in %s", settings.SigningKeyID, settings.SigningKeyFile)
+		}
+		return nil, fmt.Errorf("no private key in %s", settings.SigningKeyFile)
+	}
+	if err := decryptKey(key, settings.SigningPassphrase); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// findSigningKey returns the entity with a private key whose primary key
+// or a subkey matches id, a key ID or fingerprint in hex. An empty id picks
+// the first entity with a private key.
+func findSigningKey(keys openpgp.EntityList, id string) *openpgp.Entity {
+	id = strings.ToUpper(strings.TrimPrefix(strings.ReplaceAll(id, " ", ""), "0x"))
+	matches := func(keyID uint64, fingerprint []byte) bool {
+		return strings.HasSuffix(fmt.Sprintf("%X", fingerprint), id) ||
+			fmt.Sprintf("%016X", keyID) == id
+	}
+
+	for _, key := range keys {
+		if key.PrivateKey == nil {
+			continue
+		}
+		if id == "" || matches(key.PrimaryKey.KeyId, key.PrimaryKey.Fingerprint) {
+			return key
+		}
+		for _, sub := range key.Subkeys {
+			if matches(sub.PublicKey.KeyId, sub.PublicKey.Fingerprint) {
+				return key
+			}
+		}
+	}
+	return nil
+}
+
+// decryptKey unlocks the key's private key and subkeys with passphrase
+func decryptKey(key *openpgp.Entity, passphrase string) error {
+	if key.PrivateKey.Encrypted {
+		if passphrase == "" {
+			return fmt.Errorf("commit signing key is passphrase-protected but GIT_SIGNING_PASSPHRASE is not set")
+		}
+		if err := key.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+			return fmt.Errorf("failed to unlock commit signing key: %w", err)
+		}
+	}
+	for _, sub := range key.Subkeys {
+		if sub.PrivateKey != nil && sub.PrivateKey.Encrypted {
+			if err := sub.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				return fmt.Errorf("failed to unlock commit signing subkey: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"observability-copilot/pkg/generator"
+)
+
+// ErrInvalidTemplate is returned when a PR title or body template doesn't
+// parse or execute
+var ErrInvalidTemplate = errors.New("invalid PR template")
+
+// DefaultTitleTemplate is the PR title and commit message used when none
+// is given
+const DefaultTitleTemplate = "{{.DefaultTitle}}"
+
+// DefaultBodyTemplate is the PR body used when none is given
+const DefaultBodyTemplate = `## 🔭 Observability Instrumentation
+
+{{if .Removal}}This PR removes the instrumentation Observability Copilot generated for your service.
+{{else}}This PR adds **{{.Mode}}** instrumentation to your service.
+{{end}}
+### Changes Made:
+{{range .Changes}}{{if $.Removal}}- Removed generated code from ` + "`{{.Path}}`" + `{{else}}- Modified ` + "`{{.Path}}`" + ` to add {{.Action}}{{end}}
+{{end}}{{if or .Metrics .Traces .Logs}}
+### What's Included:
+{{end}}{{if .Metrics}}- ✅ Prometheus metrics endpoint (` + "`/metrics`" + `)
+- ✅ HTTP request counters and histograms
+{{end}}{{if .Traces}}- ✅ OpenTelemetry distributed tracing
+- ✅ Automatic span creation for HTTP requests
+- ✅ Integration with OTel Collector
+{{end}}{{if .Logs}}- ✅ trace_id and span_id on log records, to join logs with traces
+{{end}}
+### Next Steps:
+1. Review the changes
+2. Test locally
+3. Merge when ready
+
+**Generated by Observability Copilot** 🚀
+`
+
+// PRTemplateData is what PR title and body templates are executed with,
+// e.g. "{{.Service}}: add {{.Mode}} instrumentation (OBS-123)"
+type PRTemplateData struct {
+	// Plan is the full instrumentation plan
+	Plan *generator.InstrumentationPlan
+	// Service, Framework and Mode are the plan's
+	Service   string
+	Framework string
+	Mode      string
+	// Changes are the plan's file changes, each with Path and Action
+	Changes []generator.FileChange
+	// Metrics and Traces report which signals the PR adds, and Logs
+	// whether it correlates logs with traces
+	Metrics bool
+	Traces  bool
+	Logs    bool
+	// Removal is set when the PR removes generated instrumentation
+	// (mode "none")
+	Removal bool
+	// DefaultTitle is the conventional commit title the PR would get
+	// without a template, e.g. "feat: Add OpenTelemetry distributed tracing"
+	DefaultTitle string
+}
+
+// newPRTemplateData describes plan for the templates
+func newPRTemplateData(plan *generator.InstrumentationPlan, hasMetrics, hasOtel bool) PRTemplateData {
+	return PRTemplateData{
+		Plan:         plan,
+		Service:      plan.Service,
+		Framework:    plan.Framework,
+		Mode:         plan.Mode,
+		Changes:      plan.Changes,
+		Metrics:      plan.Mode == "metrics" || plan.Mode == "both",
+		Traces:       plan.Mode == "traces" || plan.Mode == "both",
+		Logs:         plan.Logs,
+		Removal:      plan.Mode == "none",
+		DefaultTitle: getCommitMessage(PlanMode(plan), hasMetrics, hasOtel),
+	}
+}
+
+// renderPRText executes tmpl, or def when tmpl is empty, with data.
+// Errors wrap ErrInvalidTemplate.
+func renderPRText(name, tmpl, def string, data PRTemplateData) (string, error) {
+	if tmpl == "" {
+		tmpl = def
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	var out strings.Builder
+	if err := t.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return out.String(), nil
+}
+
+// renderPRTitleBody renders the PR title and body from opts' templates. A
+// title is a single line, so it is trimmed and must not be empty.
+func renderPRTitleBody(plan *generator.InstrumentationPlan, hasMetrics, hasOtel bool, opts PROptions) (title, body string, err error) {
+	data := newPRTemplateData(plan, hasMetrics, hasOtel)
+	if title, err = renderPRText("title", opts.TitleTemplate, DefaultTitleTemplate, data); err != nil {
+		return "", "", err
+	}
+	title = strings.TrimSpace(title)
+	if title == "" || strings.Contains(title, "\n") {
+		return "", "", fmt.Errorf("%w: title must render to a single non-empty line", ErrInvalidTemplate)
+	}
+	if body, err = renderPRText("body", opts.BodyTemplate, DefaultBodyTemplate, data); err != nil {
+		return "", "", err
+	}
+	return title, body, nil
+}
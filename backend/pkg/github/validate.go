@@ -0,0 +1,202 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"observability-copilot/pkg/generator"
+)
+
+// validator checks an applied plan in the checkout at dir, returning the
+// tool's output in the error when the generated code is broken
+type validator func(dir string, plan *generator.InstrumentationPlan) error
+
+// validators are keyed by plan.Framework. Each skips validation when its
+// toolchain isn't installed.
+var validators = map[string]validator{
+	"Go": func(dir string, plan *generator.InstrumentationPlan) error {
+		return validateGo(dir, changedFiles(plan, ".go"), changedFiles(plan, "go.mod"))
+	},
+	"Python": func(dir string, plan *generator.InstrumentationPlan) error {
+		return validatePython(dir, changedFiles(plan, ".py"))
+	},
+	"Node.js": func(dir string, plan *generator.InstrumentationPlan) error {
+		files := changedFiles(plan, ".js")
+		files = append(files, changedFiles(plan, ".cjs")...)
+		files = append(files, changedFiles(plan, ".mjs")...)
+		return validateNode(dir, files)
+	},
+	"Java": func(dir string, plan *generator.InstrumentationPlan) error {
+		return validateMaven(dir, changedFiles(plan, "pom.xml"))
+	},
+}
+
+// validateChanges sanity-checks the applied plan with the toolchain for its
+// language. Languages without a validator aren't checked.
+func validateChanges(dir string, plan *generator.InstrumentationPlan) error {
+	validate, ok := validators[plan.Framework]
+	if !ok {
+		return nil
+	}
+	return validate(dir, plan)
+}
+
+// ErrDependenciesUnavailable is returned when a Go plan's new requirements
+// can't be downloaded, typically because the module proxy is unreachable
+var ErrDependenciesUnavailable = errors.New("go dependencies could not be downloaded")
+
+// Output of go commands that failed to reach the module proxy or VCS hosts
+var offlineGoErrors = []string{
+	"dial tcp",
+	"no such host",
+	"i/o timeout",
+	"connection refused",
+	"network is unreachable",
+	"GOPROXY=off",
+	"disabled by GOFLAGS=-mod=vendor",
+}
+
+// validateGo gofmt-checks the changed files, then resolves each module's
+// dependencies with go mod tidy, so the generated imports are in go.mod and
+// go.sum when they are committed, and builds it
+func validateGo(dir string, files, modules []string) error {
+	if _, err := exec.LookPath("gofmt"); err == nil && len(files) > 0 {
+		args := append([]string{"-l", "-e"}, files...)
+		cmd := exec.Command("gofmt", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("gofmt failed: %w\n%s", err, out)
+		}
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		return nil
+	}
+	moduleDirs := []string{"."}
+	if len(modules) > 0 {
+		moduleDirs = moduleDirs[:0]
+		for _, gomod := range modules {
+			moduleDirs = append(moduleDirs, filepath.Dir(gomod))
+		}
+	}
+	for _, moduleDir := range moduleDirs {
+		if err := tidyGoModule(filepath.Join(dir, moduleDir), moduleDir); err != nil {
+			return err
+		}
+		cmd := exec.Command("go", "build", "./...")
+		cmd.Dir = filepath.Join(dir, moduleDir)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("go build failed in %s: %w\n%s", moduleDir, err, out)
+		}
+	}
+	return nil
+}
+
+// tidyGoModule runs go mod tidy in the module at path, named by rel in
+// errors. Modules without a go.mod are left to go build to report.
+func tidyGoModule(path, rel string) error {
+	if _, err := os.Stat(filepath.Join(path, "go.mod")); err != nil {
+		return nil
+	}
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = path
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	for _, marker := range offlineGoErrors {
+		if strings.Contains(string(out), marker) {
+			return fmt.Errorf("%w in %s: check that the module proxy (GOPROXY) is reachable from the server\n%s", ErrDependenciesUnavailable, rel, out)
+		}
+	}
+	return fmt.Errorf("go mod tidy failed in %s: %w\n%s", rel, err, out)
+}
+
+func validatePython(dir string, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		if python, err = exec.LookPath("python"); err != nil {
+			return nil
+		}
+	}
+
+	args := append([]string{"-m", "py_compile"}, files...)
+	cmd := exec.Command(python, args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("py_compile failed: %w\n%s", err, out)
+	}
+	return nil
+}
+
+// validateNode syntax-checks each generated JavaScript file
+func validateNode(dir string, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	node, err := exec.LookPath("node")
+	if err != nil {
+		return nil
+	}
+
+	for _, file := range files {
+		cmd := exec.Command(node, "--check", file)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("node --check %s failed: %w\n%s", file, err, out)
+		}
+	}
+	return nil
+}
+
+// mavenEnv lists the environment variables mvn is run with. Everything else,
+// notably the code host tokens, is kept from the project's build plugins.
+var mavenEnv = []string{"PATH", "HOME", "JAVA_HOME", "MAVEN_HOME", "M2_HOME", "MAVEN_OPTS", "LANG", "TMPDIR"}
+
+// validateMaven compiles each Maven project whose pom.xml the plan changed,
+// when Settings.CompileJava allows running the project's build. Gradle
+// builds aren't compiled.
+func validateMaven(dir string, poms []string) error {
+	if len(poms) == 0 || !settings.CompileJava {
+		return nil
+	}
+	mvn, err := exec.LookPath("mvn")
+	if err != nil {
+		return nil
+	}
+
+	for _, pom := range poms {
+		cmd := exec.Command(mvn, "-q", "-B", "compile")
+		cmd.Dir = filepath.Join(dir, filepath.Dir(pom))
+		cmd.Env = []string{}
+		for _, name := range mavenEnv {
+			if value, ok := os.LookupEnv(name); ok {
+				cmd.Env = append(cmd.Env, name+"="+value)
+			}
+		}
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("mvn compile failed in %s: %w\n%s", filepath.Dir(pom), err, out)
+		}
+	}
+	return nil
+}
+
+// changedFiles lists the distinct plan paths with the given extension
+func changedFiles(plan *generator.InstrumentationPlan, ext string) []string {
+	seen := map[string]bool{}
+	files := []string{}
+	for _, change := range plan.Changes {
+		if strings.HasSuffix(change.Path, ext) && !seen[change.Path] {
+			seen[change.Path] = true
+			files = append(files, change.Path)
+		}
+	}
+	return files
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Emoji keeps the emoji on Summary lines, for humans reading the console
+var Emoji bool
+
+// Setup makes a logger writing to w the slog default. format is "json" or
+// "text"; level is "debug", "info", "warn" or "error".
+func Setup(w io.Writer, format, level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q, want json or text", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// Summary is a milestone message, prefixed with icon when Emoji is set
+func Summary(icon, msg string) string {
+	if Emoji {
+		return icon + " " + msg
+	}
+	return msg
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the request ID that FromContext
+// attaches to log records
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext returns the default logger, with a request_id attribute
+// when ctx carries one
+func FromContext(ctx context.Context) *slog.Logger {
+	if id := RequestID(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
@@ -0,0 +1,209 @@
+package scanner
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "compress/gzip"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+var (
+    // ErrUnsupportedArchive is returned for archives other than .tar.gz,
+    // .tgz and .zip
+    ErrUnsupportedArchive = errors.New("unsupported archive, expected .tar.gz, .tgz or .zip")
+
+    // ErrUnsafeArchivePath is returned for entries that would be extracted
+    // outside the target directory, e.g. "../../etc/passwd"
+    ErrUnsafeArchivePath = errors.New("archive entry escapes the extraction directory")
+)
+
+// ArchiveFormat returns "tar.gz" or "zip" for an archive's file name, or
+// ErrUnsupportedArchive
+func ArchiveFormat(name string) (string, error) {
+    name = strings.ToLower(name)
+    switch {
+    case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+        return "tar.gz", nil
+    case strings.HasSuffix(name, ".zip"):
+        return "zip", nil
+    }
+    return "", ErrUnsupportedArchive
+}
+
+// ScanArchive detects the services of a repo uploaded as an archive, for
+// code the server can't clone. The archive at archivePath is extracted to
+// a temporary directory, named by name, that is removed once scanned. An
+// archive whose files all sit under one top-level directory, like GitHub's
+// "repo-main/", is scanned from that directory. Extraction stops with
+// ErrCloneTooLarge once the files exceed MaxCloneBytes.
+func ScanArchive(ctx context.Context, archivePath, name, subpath string) (*ScanResult, error) {
+    format, err := ArchiveFormat(name)
+    if err != nil {
+        return nil, err
+    }
+
+    dir, err := MkdirTemp("upload-")
+    if err != nil {
+        return nil, fmt.Errorf("failed to create extraction directory: %w", err)
+    }
+    defer RemoveTemp(dir)
+
+    if format == "zip" {
+        err = extractZip(archivePath, dir)
+    } else {
+        err = extractTarGz(archivePath, dir)
+    }
+    if err != nil {
+        return nil, err
+    }
+    return scanDir(ctx, singleTopLevelDir(dir), subpath, nil)
+}
+
+// extractTarGz extracts a gzipped tarball into dir
+func extractTarGz(archivePath, dir string) error {
+    f, err := os.Open(archivePath)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    gz, err := gzip.NewReader(f)
+    if err != nil {
+        return fmt.Errorf("invalid tar.gz archive: %w", err)
+    }
+    defer gz.Close()
+
+    budget := newExtractBudget()
+    tr := tar.NewReader(gz)
+    for {
+        header, err := tr.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("invalid tar.gz archive: %w", err)
+        }
+        target, err := extractPath(dir, header.Name)
+        if err != nil {
+            return err
+        }
+        // Symlinks and devices are skipped: only plain files are scanned
+        switch header.Typeflag {
+        case tar.TypeDir:
+            if err := os.MkdirAll(target, 0o755); err != nil {
+                return err
+            }
+        case tar.TypeReg:
+            if err := writeExtracted(target, tr, budget); err != nil {
+                return err
+            }
+        }
+    }
+}
+
+// extractZip extracts a zip archive into dir
+func extractZip(archivePath, dir string) error {
+    zr, err := zip.OpenReader(archivePath)
+    if err != nil {
+        return fmt.Errorf("invalid zip archive: %w", err)
+    }
+    defer zr.Close()
+
+    budget := newExtractBudget()
+    for _, file := range zr.File {
+        target, err := extractPath(dir, file.Name)
+        if err != nil {
+            return err
+        }
+        mode := file.Mode()
+        switch {
+        case mode.IsDir():
+            if err := os.MkdirAll(target, 0o755); err != nil {
+                return err
+            }
+        case mode.IsRegular():
+            r, err := file.Open()
+            if err != nil {
+                return fmt.Errorf("invalid zip archive: %w", err)
+            }
+            err = writeExtracted(target, r, budget)
+            r.Close()
+            if err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// extractPath resolves an archive entry's name under dir, rejecting
+// absolute names and any that climb out of it (zip-slip)
+func extractPath(dir, name string) (string, error) {
+    name = filepath.FromSlash(strings.ReplaceAll(name, `\`, "/"))
+    if filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+        return "", fmt.Errorf("%w: %s", ErrUnsafeArchivePath, name)
+    }
+    target := filepath.Join(dir, name)
+    if rel, err := filepath.Rel(dir, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return "", fmt.Errorf("%w: %s", ErrUnsafeArchivePath, name)
+    }
+    return target, nil
+}
+
+// extractBudget is how many more bytes may be extracted, or -1 for no
+// limit
+type extractBudget struct {
+    limit     int64
+    remaining int64
+}
+
+func newExtractBudget() *extractBudget {
+    limit := MaxCloneBytes()
+    if limit <= 0 {
+        return &extractBudget{limit: 0, remaining: -1}
+    }
+    return &extractBudget{limit: limit, remaining: limit}
+}
+
+// writeExtracted writes r to target, charging its size to budget. The
+// entry's declared size isn't trusted: copying stops one byte past the
+// budget.
+func writeExtracted(target string, r io.Reader, budget *extractBudget) error {
+    if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+        return err
+    }
+    out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+    if err != nil {
+        return err
+    }
+    defer out.Close()
+
+    if budget.remaining < 0 {
+        _, err = io.Copy(out, r)
+        return err
+    }
+    n, err := io.Copy(out, io.LimitReader(r, budget.remaining+1))
+    if err != nil {
+        return err
+    }
+    budget.remaining -= n
+    if budget.remaining < 0 {
+        return fmt.Errorf("%w of %d bytes", ErrCloneTooLarge, budget.limit)
+    }
+    return nil
+}
+
+// singleTopLevelDir returns the only entry of dir when it is a directory,
+// otherwise dir
+func singleTopLevelDir(dir string) string {
+    entries, err := os.ReadDir(dir)
+    if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+        return dir
+    }
+    return filepath.Join(dir, entries[0].Name())
+}
@@ -0,0 +1,294 @@
+package scanner
+
+import (
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// Problem codes reported by AuditDir
+const (
+    // Metrics are registered but nothing serves them for scraping
+    ProblemMetricsNotExposed = "metrics_not_exposed"
+    // A tracer provider is created but never installed as the global one,
+    // so instrumentation libraries keep using the no-op provider
+    ProblemTracerNotGlobal = "tracer_not_global"
+    // A tracer provider is created without a span processor or exporter,
+    // so spans go nowhere
+    ProblemTracerNoExporter = "tracer_without_exporter"
+    // A tracer provider is never shut down, so buffered spans are lost on
+    // exit
+    ProblemTracerNotShutDown = "tracer_not_shut_down"
+    // Spans are created but no tracer provider is set up in code
+    ProblemSpansWithoutTracer = "spans_without_tracer"
+)
+
+// Problem severities
+const (
+    SeverityError   = "error"
+    SeverityWarning = "warning"
+)
+
+// AuditReport describes the observability a repo already has and what is
+// wrong with it
+type AuditReport struct {
+    Services []ServiceAudit `json:"services"`
+    Problems []Problem      `json:"problems"`
+}
+
+// ServiceAudit is the existing instrumentation of one detected service.
+// Files are repo-relative.
+type ServiceAudit struct {
+    Service      string   `json:"service"`
+    Path         string   `json:"path"`
+    Language     string   `json:"language"`
+    Framework    string   `json:"framework"`
+    HasMetrics   bool     `json:"has_metrics"`
+    HasTraces    bool     `json:"has_traces"`
+    MetricsFiles []string `json:"metrics_files"`
+    TraceFiles   []string `json:"trace_files"`
+
+    // MetricsExposed reports whether anything serves the metrics, and
+    // MetricsPath the route when it is known
+    MetricsExposed bool   `json:"metrics_exposed"`
+    MetricsPath    string `json:"metrics_path,omitempty"`
+}
+
+// Problem is a misconfiguration found in a service's instrumentation. File
+// and Line point at the code it was found on, when there is one.
+type Problem struct {
+    Service  string `json:"service"`
+    Code     string `json:"code"`
+    Severity string `json:"severity"`
+    Message  string `json:"message"`
+    File     string `json:"file,omitempty"`
+    Line     int    `json:"line,omitempty"`
+}
+
+// auditExtensions are the files searched for each language's
+// instrumentation. Java also searches its config, where Spring Boot
+// exposes the Prometheus actuator endpoint.
+var auditExtensions = map[string][]string{
+    "Go":      goExtensions,
+    "Python":  pythonExtensions,
+    "Java":    {"java", "kt", "properties", "yml", "yaml"},
+    "Node.js": nodeExtensions,
+    ".NET":    dotnetExtensions,
+    "Rust":    rustExtensions,
+    "Ruby":    rubyExtensions,
+}
+
+// metricsExposurePatterns mark code serving metrics for scraping
+var metricsExposurePatterns = map[string][]string{
+    "Go":      {"promhttp.Handler"},
+    "Python":  {"start_http_server(", "make_asgi_app(", "make_wsgi_app(", "generate_latest(", "PrometheusMetrics(", ".expose("},
+    "Java":    {".scrape(", "exposure.include.*prometheus", "include:.*prometheus"},
+    "Node.js": {".metrics()", "PrometheusModule.register"},
+    ".NET":    {"UseMetricServer(", "MapMetrics(", "KestrelMetricServer(", "PrometheusScrapingEndpoint("},
+    "Rust":    {"TextEncoder"},
+    "Ruby":    {"Prometheus::Middleware::Exporter"},
+}
+
+// tracerChecks are the patterns a tracer provider's setup is checked
+// against. An empty list skips that check.
+type tracerChecks struct {
+    Provider []string // creates a tracer provider
+    Global   []string // installs it as the global provider
+    Exporter []string // attaches a span processor or exporter
+    Shutdown []string // flushes it on exit
+}
+
+var tracerSetup = map[string]tracerChecks{
+    "Go": {
+        Provider: []string{"sdktrace.NewTracerProvider("},
+        Global:   []string{"otel.SetTracerProvider("},
+        Exporter: []string{"WithBatcher(", "WithSyncer(", "WithSpanProcessor("},
+        Shutdown: []string{".Shutdown("},
+    },
+    "Python": {
+        Provider: []string{"TracerProvider("},
+        Global:   []string{"set_tracer_provider("},
+        Exporter: []string{"add_span_processor("},
+    },
+    "Java": {
+        Provider: []string{"SdkTracerProvider.builder("},
+        Global:   []string{"buildAndRegisterGlobal(", "GlobalOpenTelemetry.set("},
+        Exporter: []string{"addSpanProcessor("},
+    },
+    "Node.js": {
+        Provider: []string{"new NodeTracerProvider(", "new BasicTracerProvider(", "new NodeSDK("},
+        Global:   []string{".register(", ".start()"},
+        Exporter: []string{"addSpanProcessor(", "spanProcessor", "traceExporter"},
+        Shutdown: []string{".shutdown("},
+    },
+    ".NET": {
+        Provider: []string{"AddOpenTelemetry(", "CreateTracerProviderBuilder("},
+        Exporter: []string{"AddOtlpExporter(", "AddConsoleExporter(", "AddJaegerExporter(", "AddZipkinExporter("},
+    },
+    "Rust": {
+        Provider: []string{"TracerProvider::builder("},
+        Global:   []string{"set_tracer_provider("},
+        Exporter: []string{"with_batch_exporter(", "with_simple_exporter(", "install_batch(", "install_simple("},
+        Shutdown: []string{"shutdown_tracer_provider("},
+    },
+}
+
+// quotedMetricsRoute matches a route literal serving metrics, e.g.
+// "/metrics" or '/internal/metrics'
+var quotedMetricsRoute = regexp.MustCompile(`["'](/[\w/.-]*metrics)["']`)
+
+// AuditDir reports the existing instrumentation of the services result
+// found in the checkout at root, and the problems with it
+func AuditDir(root string, result *ScanResult) *AuditReport {
+    report := &AuditReport{Services: []ServiceAudit{}, Problems: []Problem{}}
+    modules := findModules(root)
+    for _, d := range result.Detections {
+        audit, problems := auditService(root, d, modules)
+        report.Services = append(report.Services, audit)
+        report.Problems = append(report.Problems, problems...)
+    }
+    return report
+}
+
+// auditService audits one detected service's module
+func auditService(root string, d FrameworkDetection, modules []string) (ServiceAudit, []Problem) {
+    audit := ServiceAudit{
+        Service:      d.ServiceName,
+        Path:         d.Path,
+        Language:     d.Language,
+        Framework:    d.Framework,
+        HasMetrics:   d.HasMetrics,
+        HasTraces:    d.HasOTel,
+        MetricsFiles: []string{},
+        TraceFiles:   []string{},
+    }
+    problems := []Problem{}
+    problem := func(code, severity, message string, at []Match) {
+        p := Problem{Service: d.ServiceName, Code: code, Severity: severity, Message: message}
+        if len(at) > 0 {
+            p.File, p.Line = at[0].File, at[0].Line
+        }
+        problems = append(problems, p)
+    }
+
+    // Only this module's own files, not those of nested modules
+    path := filepath.Join(root, d.Path)
+    extensions := auditExtensions[d.Language]
+    grep := func(patterns []string) []Match {
+        found := []Match{}
+        for _, m := range grepMatches(path, patterns, extensions) {
+            m.File = filepath.ToSlash(filepath.Join(d.Path, m.File))
+            if owningModule(m.File, modules) == d.Path {
+                found = append(found, m)
+            }
+        }
+        return found
+    }
+
+    registered := grep(metricsRegistrationPatterns[d.Language])
+    providers := grep(tracerSetup[d.Language].Provider)
+    audit.MetricsFiles = matchedFiles(registered)
+    audit.TraceFiles = matchedFiles(providers)
+
+    // The Node.js analyzer also knows NestJS modules and ES imports
+    for _, c := range d.Candidates {
+        switch c.Kind {
+        case "metrics":
+            audit.MetricsFiles = appendMissing(audit.MetricsFiles, c.Files...)
+        case "traces":
+            audit.TraceFiles = appendMissing(audit.TraceFiles, c.Files...)
+        }
+    }
+
+    // Metrics must be served somewhere to be scraped
+    exposed := grep(metricsExposurePatterns[d.Language])
+    audit.MetricsExposed = len(exposed) > 0
+    if d.Language == "Go" {
+        audit.MetricsPath = findGoMetricsPath(path)
+    }
+    for _, m := range exposed {
+        if audit.MetricsPath != "" {
+            break
+        }
+        if route := quotedMetricsRoute.FindStringSubmatch(m.Text); route != nil {
+            audit.MetricsPath = route[1]
+        } else if strings.Contains(m.Text, "prometheus") && d.Language == "Java" {
+            audit.MetricsPath = "/actuator/prometheus"
+        }
+    }
+    if len(audit.MetricsFiles) > 0 && !audit.MetricsExposed {
+        problem(ProblemMetricsNotExposed, SeverityError,
+            "Metrics are registered but no endpoint serves them, so Prometheus can't scrape them", registered)
+    }
+
+    // A tracer provider only records spans once it is global, exporting
+    // and flushed on exit
+    checks := tracerSetup[d.Language]
+    if len(providers) > 0 {
+        if len(checks.Global) > 0 && len(grep(checks.Global)) == 0 {
+            problem(ProblemTracerNotGlobal, SeverityError,
+                "A tracer provider is created but never set as the global provider, so instrumentation libraries don't use it", providers)
+        }
+        if len(checks.Exporter) > 0 && len(grep(checks.Exporter)) == 0 {
+            problem(ProblemTracerNoExporter, SeverityError,
+                "A tracer provider is created without a span processor or exporter, so spans are never exported", providers)
+        }
+        if len(checks.Shutdown) > 0 && len(grep(checks.Shutdown)) == 0 {
+            problem(ProblemTracerNotShutDown, SeverityWarning,
+                "The tracer provider is never shut down, so spans still buffered on exit are lost", providers)
+        }
+    } else if len(audit.TraceFiles) == 0 {
+        if spans := grep(otelUsagePatterns[d.Language]); len(spans) > 0 {
+            problem(ProblemSpansWithoutTracer, SeverityWarning,
+                "Spans are created but no tracer provider is set up in code; unless an auto-instrumentation agent runs the service, they are dropped", spans)
+        }
+    }
+
+    return audit, problems
+}
+
+// grepMatches lists the lines matching any of the grep-style patterns,
+// ignoring case, in files with the given extensions, skipping test and
+// generated files. Files are relative to repoPath.
+func grepMatches(repoPath string, patterns []string, extensions []string) []Match {
+    matches := []Match{}
+    if len(patterns) == 0 {
+        return matches
+    }
+    res := []*regexp.Regexp{}
+    for _, p := range patterns {
+        if re, err := regexp.Compile("(?i)" + grepPattern(p)); err == nil {
+            res = append(res, re)
+        }
+    }
+    include := func(name string) bool {
+        return hasExtension(name, extensions) && !excludedFile(name)
+    }
+
+    walkRepoFiles(repoPath, include, func(path, code string) bool {
+        rel, err := filepath.Rel(repoPath, path)
+        if err != nil {
+            return false
+        }
+        for i, line := range strings.Split(code, "\n") {
+            for _, re := range res {
+                if re.MatchString(line) {
+                    matches = append(matches, Match{File: filepath.ToSlash(rel), Line: i + 1, Text: strings.TrimSpace(line)})
+                    break
+                }
+            }
+        }
+        return false
+    })
+    return matches
+}
+
+// appendMissing appends the items not already in list
+func appendMissing(list []string, items ...string) []string {
+    for _, item := range items {
+        if !containsString(list, item) {
+            list = append(list, item)
+        }
+    }
+    return list
+}
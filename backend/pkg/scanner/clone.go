@@ -0,0 +1,113 @@
+package scanner
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sync/atomic"
+    "time"
+
+    "github.com/go-git/go-git/v5"
+)
+
+// DefaultCloneTimeout bounds clones and remote calls when no timeout is
+// configured
+const DefaultCloneTimeout = 2 * time.Minute
+
+// CloneTimeout is the configured clone timeout, or DefaultCloneTimeout
+func CloneTimeout() time.Duration {
+    if settings.CloneTimeout > 0 {
+        return settings.CloneTimeout
+    }
+    return DefaultCloneTimeout
+}
+
+// MaxCloneBytes is the largest checkout allowed on disk. Zero means no
+// limit.
+func MaxCloneBytes() int64 {
+    return settings.MaxCloneBytes
+}
+
+var (
+    // ErrCloneTimeout is returned when a clone takes longer than CloneTimeout
+    ErrCloneTimeout = errors.New("clone timed out")
+
+    // ErrCloneTooLarge is returned when a checkout grows past MaxCloneBytes
+    ErrCloneTooLarge = errors.New("repository exceeds the clone size limit")
+)
+
+// Clone clones into dir, aborting after CloneTimeout or once the checkout
+// exceeds MaxCloneBytes. A failed clone leaves nothing behind in dir, and
+// its error is a CloneError when the reason is known.
+func Clone(dir string, opts *git.CloneOptions) (*git.Repository, error) {
+    return CloneContext(context.Background(), dir, opts)
+}
+
+// CloneContext is Clone, also aborting when parent is done
+func CloneContext(parent context.Context, dir string, opts *git.CloneOptions) (*git.Repository, error) {
+    timeout := CloneTimeout()
+    ctx, cancel := context.WithTimeout(parent, timeout)
+    defer cancel()
+
+    // Poll the checkout size while cloning and cancel once it's too big
+    limit := MaxCloneBytes()
+    var tooLarge atomic.Bool
+    if limit > 0 {
+        done := make(chan struct{})
+        defer close(done)
+        go func() {
+            ticker := time.NewTicker(500 * time.Millisecond)
+            defer ticker.Stop()
+            for {
+                select {
+                case <-done:
+                    return
+                case <-ticker.C:
+                    if dirSize(dir) > limit {
+                        tooLarge.Store(true)
+                        cancel()
+                        return
+                    }
+                }
+            }
+        }()
+    }
+
+    repo, err := git.PlainCloneContext(ctx, dir, false, opts)
+    if err == nil && limit > 0 && dirSize(dir) > limit {
+        tooLarge.Store(true)
+    }
+    switch {
+    case tooLarge.Load():
+        err = fmt.Errorf("%w of %d bytes", ErrCloneTooLarge, limit)
+    case err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded):
+        err = fmt.Errorf("%w after %s", ErrCloneTimeout, timeout)
+    default:
+        err = ClassifyGitError(err, opts.URL, opts.ReferenceName.Short())
+    }
+    if err != nil {
+        os.RemoveAll(dir)
+        return nil, err
+    }
+    return repo, nil
+}
+
+// dirSize totals the size of regular files under dir
+func dirSize(dir string) int64 {
+    var size int64
+    filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return nil
+        }
+        if d.Type().IsRegular() {
+            if info, err := d.Info(); err == nil {
+                size += info.Size()
+            }
+        }
+        return nil
+    })
+    return size
+}
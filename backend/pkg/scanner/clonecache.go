@@ -0,0 +1,221 @@
+package scanner
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "os"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/config"
+    "github.com/go-git/go-git/v5/plumbing"
+    "github.com/go-git/go-git/v5/storage/memory"
+)
+
+// DefaultCloneCacheTTL is how long an unused cached checkout is kept when
+// no TTL is configured
+const DefaultCloneCacheTTL = time.Hour
+
+// cachedClone is a shallow checkout of one repo and branch kept between
+// scans. mu is held while a scan uses the checkout; lastUsed is guarded by
+// cloneCache's lock, which eviction sorts by.
+type cachedClone struct {
+    mu       sync.Mutex
+    dir      string
+    sha      string
+    lastUsed time.Time
+}
+
+// cloneCache holds the cached checkouts by cacheKey
+var cloneCache = struct {
+    sync.Mutex
+    entries map[string]*cachedClone
+}{entries: map[string]*cachedClone{}}
+
+// cacheKey identifies a repo URL and branch
+func cacheKey(repoURL, branch string) string {
+    sum := sha256.Sum256([]byte(repoURL + "\x00" + branch))
+    return hex.EncodeToString(sum[:8])
+}
+
+// checkout returns a checkout of the repo and branch opts describe, and the
+// function that releases it once the scan is done with it. With
+// CloneCacheSize set, a cached checkout is reused while the remote branch
+// still points at the commit it was cloned at, and cloned again otherwise.
+// Changes made to a cached checkout are discarded on release.
+func checkout(ctx context.Context, repoID string, opts *git.CloneOptions) (dir string, cached bool, release func(), err error) {
+    if settings.CloneCacheSize <= 0 {
+        dir, err := MkdirTemp(repoID + "-")
+        if err != nil {
+            return "", false, nil, fmt.Errorf("failed to create clone directory: %w", err)
+        }
+        if _, err := CloneContext(ctx, dir, opts); err != nil {
+            RemoveTemp(dir)
+            return "", false, nil, fmt.Errorf("failed to clone: %w", err)
+        }
+        return dir, false, func() { RemoveTemp(dir) }, nil
+    }
+
+    sha, err := remoteHead(ctx, opts)
+    if err != nil {
+        return "", false, nil, fmt.Errorf("failed to clone: %w", err)
+    }
+
+    key := cacheKey(opts.URL, opts.ReferenceName.String())
+    var entry *cachedClone
+    for entry == nil {
+        cloneCache.Lock()
+        candidate, ok := cloneCache.entries[key]
+        if !ok {
+            candidate = &cachedClone{lastUsed: time.Now()}
+            cloneCache.entries[key] = candidate
+        }
+        cloneCache.Unlock()
+
+        // Eviction may drop the entry while its lock is waited for, so it
+        // is only used if it is still the cached one once locked
+        candidate.mu.Lock()
+        cloneCache.Lock()
+        if cloneCache.entries[key] == candidate {
+            entry = candidate
+        }
+        cloneCache.Unlock()
+        if entry == nil {
+            candidate.mu.Unlock()
+        }
+    }
+    release = func() {
+        if err := resetCheckout(entry.dir); err != nil {
+            RemoveTemp(entry.dir)
+            entry.dir, entry.sha = "", ""
+        }
+        cloneCache.Lock()
+        entry.lastUsed = time.Now()
+        cloneCache.Unlock()
+        entry.mu.Unlock()
+        evictClones()
+    }
+
+    if entry.dir != "" && entry.sha == sha {
+        if _, err := os.Stat(entry.dir); err == nil {
+            return entry.dir, true, release, nil
+        }
+    }
+
+    // The branch moved on, or this is the first scan: clone it afresh
+    if entry.dir != "" {
+        RemoveTemp(entry.dir)
+    }
+    entry.dir, entry.sha = "", ""
+    dir, err = MkdirTemp(repoID + "-cached-")
+    if err != nil {
+        entry.mu.Unlock()
+        return "", false, nil, fmt.Errorf("failed to create clone directory: %w", err)
+    }
+    if _, err := CloneContext(ctx, dir, opts); err != nil {
+        RemoveTemp(dir)
+        entry.mu.Unlock()
+        return "", false, nil, fmt.Errorf("failed to clone: %w", err)
+    }
+    entry.dir, entry.sha = dir, sha
+    return dir, false, release, nil
+}
+
+// remoteHead returns the commit the remote's branch, or its default branch
+// when opts names none, points at
+func remoteHead(ctx context.Context, opts *git.CloneOptions) (string, error) {
+    ctx, cancel := context.WithTimeout(ctx, CloneTimeout())
+    defer cancel()
+
+    remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+        Name: "origin",
+        URLs: []string{opts.URL},
+    })
+    refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: opts.Auth})
+    if err != nil {
+        return "", ClassifyGitError(err, opts.URL, opts.ReferenceName.Short())
+    }
+
+    target := opts.ReferenceName
+    if target == "" {
+        target = plumbing.HEAD
+    }
+    byName := map[plumbing.ReferenceName]*plumbing.Reference{}
+    for _, ref := range refs {
+        byName[ref.Name()] = ref
+    }
+    // HEAD is listed as a symbolic reference to the default branch
+    for i := 0; i < 2; i++ {
+        ref, ok := byName[target]
+        if !ok {
+            break
+        }
+        if ref.Type() == plumbing.HashReference {
+            return ref.Hash().String(), nil
+        }
+        target = ref.Target()
+    }
+    return "", ClassifyGitError(fmt.Errorf("%w on the remote: %s", plumbing.ErrReferenceNotFound, target), opts.URL, opts.ReferenceName.Short())
+}
+
+// resetCheckout discards changes made to a cached checkout
+func resetCheckout(dir string) error {
+    if dir == "" {
+        return fmt.Errorf("no checkout")
+    }
+    repo, err := git.PlainOpen(dir)
+    if err != nil {
+        return err
+    }
+    worktree, err := repo.Worktree()
+    if err != nil {
+        return err
+    }
+    if err := worktree.Reset(&git.ResetOptions{Mode: git.HardReset}); err != nil {
+        return err
+    }
+    return worktree.Clean(&git.CleanOptions{Dir: true})
+}
+
+// evictClones removes cached checkouts unused for longer than
+// CloneCacheTTL, then the least recently used ones until at most
+// CloneCacheSize remain. Checkouts in use are left alone.
+func evictClones() {
+    ttl := settings.CloneCacheTTL
+    if ttl <= 0 {
+        ttl = DefaultCloneCacheTTL
+    }
+
+    cloneCache.Lock()
+    defer cloneCache.Unlock()
+
+    keys := make([]string, 0, len(cloneCache.entries))
+    for key := range cloneCache.entries {
+        keys = append(keys, key)
+    }
+    sort.Slice(keys, func(i, j int) bool {
+        return cloneCache.entries[keys[i]].lastUsed.Before(cloneCache.entries[keys[j]].lastUsed)
+    })
+
+    remaining := len(keys)
+    for _, key := range keys {
+        entry := cloneCache.entries[key]
+        if remaining <= settings.CloneCacheSize && time.Since(entry.lastUsed) <= ttl {
+            continue
+        }
+        if !entry.mu.TryLock() {
+            continue
+        }
+        if entry.dir != "" {
+            RemoveTemp(entry.dir)
+        }
+        entry.dir, entry.sha = "", ""
+        delete(cloneCache.entries, key)
+        entry.mu.Unlock()
+        remaining--
+    }
+}
@@ -0,0 +1,125 @@
+package scanner
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "strings"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
+    "github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+var (
+    // ErrRepoNotFound is returned when the git host has no repository at
+    // the URL, which private repos also look like to tokens without access
+    ErrRepoNotFound = errors.New("repository not found")
+
+    // ErrAuthRequired is returned when the git host wants credentials, or
+    // rejects the configured ones
+    ErrAuthRequired = errors.New("repository requires authentication")
+
+    // ErrBranchNotFound is returned when the branch asked for isn't on the
+    // remote
+    ErrBranchNotFound = errors.New("branch not found")
+
+    // ErrHostUnreachable is returned when the git host can't be reached
+    ErrHostUnreachable = errors.New("git host unreachable")
+)
+
+// CloneError is a clone or remote call that failed for a known reason. It
+// matches Kind, one of ErrRepoNotFound, ErrAuthRequired, ErrBranchNotFound
+// and ErrHostUnreachable, as well as the underlying error.
+type CloneError struct {
+    Kind   error
+    URL    string
+    Branch string
+    Err    error
+}
+
+func (e *CloneError) Error() string {
+    switch e.Kind {
+    case ErrRepoNotFound:
+        return fmt.Sprintf("repository %s was not found: check the URL, and that the configured token can access it", e.URL)
+    case ErrAuthRequired:
+        return fmt.Sprintf("repository %s requires authentication: configure a token with read access to it (%v)", e.URL, e.Err)
+    case ErrBranchNotFound:
+        return fmt.Sprintf("branch %q does not exist on %s", e.Branch, e.URL)
+    case ErrHostUnreachable:
+        return fmt.Sprintf("could not reach the git host of %s: %v", e.URL, e.Err)
+    }
+    return e.Err.Error()
+}
+
+func (e *CloneError) Unwrap() []error {
+    return []error{e.Kind, e.Err}
+}
+
+// gitFailures are what git hosts and git itself print for the failures
+// go-git doesn't turn into errors of its own, e.g. over SSH
+var gitFailures = []struct {
+    Text string
+    Kind error
+}{
+    {"repository not found", ErrRepoNotFound},
+    {"does not appear to be a git repository", ErrRepoNotFound},
+    {"could not read username", ErrAuthRequired},
+    {"permission denied", ErrAuthRequired},
+    {"invalid username or password", ErrAuthRequired},
+    {"couldn't find remote ref", ErrBranchNotFound},
+    {"remote branch", ErrBranchNotFound},
+    {"could not resolve host", ErrHostUnreachable},
+    {"no such host", ErrHostUnreachable},
+    {"connection refused", ErrHostUnreachable},
+    {"connection reset", ErrHostUnreachable},
+    {"network is unreachable", ErrHostUnreachable},
+}
+
+// ClassifyGitError turns a failed clone or remote call against url into a
+// CloneError when its reason is known. branch is the branch asked for, if
+// any. Other errors, including ErrCloneTimeout and ErrCloneTooLarge, are
+// returned as they are, as are cancelled calls.
+func ClassifyGitError(err error, url, branch string) error {
+    var known *CloneError
+    if err == nil || errors.As(err, &known) || errors.Is(err, context.Canceled) ||
+        errors.Is(err, ErrCloneTimeout) || errors.Is(err, ErrCloneTooLarge) {
+        return err
+    }
+
+    kind := gitErrorKind(err)
+    if kind == ErrBranchNotFound && branch == "" {
+        kind = nil
+    }
+    if kind == nil {
+        return err
+    }
+    return &CloneError{Kind: kind, URL: url, Branch: branch, Err: err}
+}
+
+// gitErrorKind returns the reason err failed, or nil if it isn't known
+func gitErrorKind(err error) error {
+    var refSpec git.NoMatchingRefSpecError
+    var netErr net.Error
+    switch {
+    case errors.Is(err, transport.ErrRepositoryNotFound):
+        return ErrRepoNotFound
+    case errors.Is(err, transport.ErrAuthenticationRequired),
+        errors.Is(err, transport.ErrAuthorizationFailed),
+        errors.Is(err, transport.ErrInvalidAuthMethod):
+        return ErrAuthRequired
+    case errors.Is(err, plumbing.ErrReferenceNotFound), errors.As(err, &refSpec):
+        return ErrBranchNotFound
+    case errors.As(err, &netErr):
+        return ErrHostUnreachable
+    }
+
+    message := strings.ToLower(err.Error())
+    for _, failure := range gitFailures {
+        if strings.Contains(message, failure.Text) {
+            return failure.Kind
+        }
+    }
+    return nil
+}
@@ -0,0 +1,189 @@
+package scanner
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
+    "github.com/go-git/go-git/v5/plumbing/object"
+    "github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+func TestClassifyGitError(t *testing.T) {
+    const url = "https://github.com/acme/private"
+    timeout := fmt.Errorf("%w after 5m0s", ErrCloneTimeout)
+
+    tests := []struct {
+        name        string
+        err         error
+        branch      string
+        want        error  // the kind, or the error itself when unknown
+        wantMessage string // part of the message
+    }{
+        {
+            name:        "authentication required",
+            err:         transport.ErrAuthenticationRequired,
+            want:        ErrAuthRequired,
+            wantMessage: "requires authentication: configure a token",
+        },
+        {
+            name:        "authorization failed",
+            err:         fmt.Errorf("git clone failed: %w", transport.ErrAuthorizationFailed),
+            want:        ErrAuthRequired,
+            wantMessage: "requires authentication",
+        },
+        {
+            name:        "git asking for a username",
+            err:         errors.New("fatal: could not read Username for 'https://github.com': terminal prompts disabled"),
+            want:        ErrAuthRequired,
+            wantMessage: "requires authentication",
+        },
+        {
+            name:        "ssh key rejected",
+            err:         errors.New("git@github.com: Permission denied (publickey)."),
+            want:        ErrAuthRequired,
+            wantMessage: "requires authentication",
+        },
+        {
+            name:        "missing branch reference",
+            err:         plumbing.ErrReferenceNotFound,
+            branch:      "release",
+            want:        ErrBranchNotFound,
+            wantMessage: `branch "release" does not exist on ` + url,
+        },
+        {
+            name:        "missing branch refspec",
+            err:         git.NoMatchingRefSpecError{},
+            branch:      "release",
+            want:        ErrBranchNotFound,
+            wantMessage: `branch "release" does not exist`,
+        },
+        {
+            name:        "git missing remote ref",
+            err:         errors.New("fatal: couldn't find remote ref release"),
+            branch:      "release",
+            want:        ErrBranchNotFound,
+            wantMessage: `branch "release" does not exist`,
+        },
+        {
+            name: "missing reference without a branch asked for",
+            err:  plumbing.ErrReferenceNotFound,
+            want: plumbing.ErrReferenceNotFound,
+        },
+        {
+            name:        "repository not found",
+            err:         transport.ErrRepositoryNotFound,
+            want:        ErrRepoNotFound,
+            wantMessage: "was not found",
+        },
+        {
+            name:        "unreachable host",
+            err:         &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+            want:        ErrHostUnreachable,
+            wantMessage: "could not reach the git host",
+        },
+        {
+            name: "timeout left as is",
+            err:  timeout,
+            want: ErrCloneTimeout,
+        },
+        {
+            name: "cancelled left as is",
+            err:  context.Canceled,
+            want: context.Canceled,
+        },
+        {
+            name: "unknown left as is",
+            err:  errors.New("object not found"),
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := ClassifyGitError(tt.err, url, tt.branch)
+            if !errors.Is(got, tt.err) {
+                t.Errorf("ClassifyGitError() = %v, lost the underlying %v", got, tt.err)
+            }
+            if tt.want != nil && !errors.Is(got, tt.want) {
+                t.Errorf("ClassifyGitError() = %v, want %v", got, tt.want)
+            }
+
+            var cloneErr *CloneError
+            isCloneErr := errors.As(got, &cloneErr)
+            if wantCloneErr := tt.wantMessage != ""; isCloneErr != wantCloneErr {
+                t.Errorf("ClassifyGitError() = %#v, want CloneError %v", got, wantCloneErr)
+            }
+            if !strings.Contains(got.Error(), tt.wantMessage) {
+                t.Errorf("message %q doesn't contain %q", got.Error(), tt.wantMessage)
+            }
+        })
+    }
+
+    if err := ClassifyGitError(nil, url, ""); err != nil {
+        t.Errorf("ClassifyGitError(nil) = %v, want nil", err)
+    }
+}
+
+func TestCloneMissingBranch(t *testing.T) {
+    remote := t.TempDir()
+    repo, err := git.PlainInit(remote, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    wt, err := repo.Worktree()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(remote, "README.md"), []byte("api\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := wt.Add("README.md"); err != nil {
+        t.Fatal(err)
+    }
+    _, err = wt.Commit("init", &git.CommitOptions{
+        Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name   string
+        branch string
+        want   error
+    }{
+        {name: "existing branch", branch: "master"},
+        {name: "missing branch", branch: "release", want: ErrBranchNotFound},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := CloneContext(context.Background(), t.TempDir(), &git.CloneOptions{
+                URL:           remote,
+                ReferenceName: plumbing.NewBranchReferenceName(tt.branch),
+                SingleBranch:  true,
+            })
+            if tt.want == nil {
+                if err != nil {
+                    t.Fatalf("CloneContext() error = %v", err)
+                }
+                return
+            }
+            if !errors.Is(err, tt.want) {
+                t.Fatalf("CloneContext() error = %v, want %v", err, tt.want)
+            }
+            var cloneErr *CloneError
+            if !errors.As(err, &cloneErr) || cloneErr.Branch != tt.branch || cloneErr.URL != remote {
+                t.Errorf("CloneContext() error = %#v, want a CloneError for branch %q of %s", err, tt.branch, remote)
+            }
+        })
+    }
+}
@@ -0,0 +1,120 @@
+package scanner
+
+import (
+    "path/filepath"
+    "strings"
+)
+
+// stripComments removes comments from source in the language given by the
+// file's extension, so patterns in commented-out code or docstrings don't
+// count as matches. Line breaks are kept so line-based matching still works.
+// Unknown extensions are returned unchanged.
+func stripComments(src, filename string) string {
+    switch strings.TrimPrefix(filepath.Ext(filename), ".") {
+    case "js", "ts", "mjs", "cjs", "mts", "cts", "jsx", "tsx", "go":
+        return stripCComments(src, "'\"`")
+    case "java", "cs", "kt", "scala":
+        return stripCComments(src, "'\"")
+    case "rs":
+        // ' also starts lifetimes, so only " delimits strings
+        return stripCComments(src, "\"")
+    case "py":
+        return stripPythonComments(src)
+    case "rb", "ru":
+        // Ruby's # comments and quoting match Python's
+        return stripPythonComments(src)
+    }
+    return src
+}
+
+// stripCComments removes // and /* */ comments from C-family source,
+// leaving literals delimited by any of quotes intact
+func stripCComments(src, quotes string) string {
+    var out strings.Builder
+    out.Grow(len(src))
+
+    for i := 0; i < len(src); i++ {
+        ch := src[i]
+        switch {
+        case ch == '/' && i+1 < len(src) && src[i+1] == '/':
+            for i < len(src) && src[i] != '\n' {
+                i++
+            }
+            if i < len(src) {
+                out.WriteByte('\n')
+            }
+        case ch == '/' && i+1 < len(src) && src[i+1] == '*':
+            i += 2
+            for i+1 < len(src) && !(src[i] == '*' && src[i+1] == '/') {
+                if src[i] == '\n' {
+                    out.WriteByte('\n')
+                }
+                i++
+            }
+            i++
+        case strings.IndexByte(quotes, ch) >= 0:
+            out.WriteByte(ch)
+            for i++; i < len(src) && src[i] != ch; i++ {
+                if src[i] == '\\' && i+1 < len(src) {
+                    out.WriteByte(src[i])
+                    i++
+                }
+                out.WriteByte(src[i])
+            }
+            if i < len(src) {
+                out.WriteByte(ch)
+            }
+        default:
+            out.WriteByte(ch)
+        }
+    }
+    return out.String()
+}
+
+// stripPythonComments removes # comments and triple-quoted strings, which
+// are almost always docstrings, leaving ordinary string literals intact
+func stripPythonComments(src string) string {
+    var out strings.Builder
+    out.Grow(len(src))
+
+    for i := 0; i < len(src); i++ {
+        ch := src[i]
+        switch {
+        case ch == '#':
+            for i < len(src) && src[i] != '\n' {
+                i++
+            }
+            if i < len(src) {
+                out.WriteByte('\n')
+            }
+        case (ch == '"' || ch == '\'') && strings.HasPrefix(src[i:], strings.Repeat(string(ch), 3)):
+            delim := src[i : i+3]
+            i += 3
+            for i < len(src) && !strings.HasPrefix(src[i:], delim) {
+                if src[i] == '\\' && i+1 < len(src) {
+                    i++
+                }
+                if src[i] == '\n' {
+                    out.WriteByte('\n')
+                }
+                i++
+            }
+            i += 2
+        case ch == '"' || ch == '\'':
+            out.WriteByte(ch)
+            for i++; i < len(src) && src[i] != ch && src[i] != '\n'; i++ {
+                if src[i] == '\\' && i+1 < len(src) {
+                    out.WriteByte(src[i])
+                    i++
+                }
+                out.WriteByte(src[i])
+            }
+            if i < len(src) {
+                out.WriteByte(src[i])
+            }
+        default:
+            out.WriteByte(ch)
+        }
+    }
+    return out.String()
+}
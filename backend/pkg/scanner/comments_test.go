@@ -0,0 +1,139 @@
+package scanner
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestFindFilesInRepoSkipsComments(t *testing.T) {
+    tests := []struct {
+        name    string
+        file    string
+        src     string
+        pattern string
+        want    bool
+    }{
+        {
+            name:    "go call",
+            file:    "main.go",
+            src:     "package main\n\nfunc main() {\n    http.Handle(\"/metrics\", promhttp.Handler())\n}\n",
+            pattern: "promhttp.Handler()",
+            want:    true,
+        },
+        {
+            name:    "go line comment",
+            file:    "main.go",
+            src:     "package main\n\nfunc main() {\n    // http.Handle(\"/metrics\", promhttp.Handler())\n}\n",
+            pattern: "promhttp.Handler()",
+        },
+        {
+            name:    "go block comment",
+            file:    "main.go",
+            src:     "package main\n\n/*\nfunc metrics() {\n    http.Handle(\"/metrics\", promhttp.Handler())\n}\n*/\n\nfunc main() {}\n",
+            pattern: "promhttp.Handler()",
+        },
+        {
+            name:    "go string holding a comment opener",
+            file:    "main.go",
+            src:     "package main\n\nvar glob = \"/*\"\n\nfunc main() {\n    http.Handle(\"/metrics\", promhttp.Handler())\n}\n",
+            pattern: "promhttp.Handler()",
+            want:    true,
+        },
+        {
+            name:    "java block comment",
+            file:    "App.java",
+            src:     "class App {\n    /*\n     * MeterRegistry registry = new PrometheusMeterRegistry();\n     */\n}\n",
+            pattern: "PrometheusMeterRegistry",
+        },
+        {
+            name:    "javascript block comment",
+            file:    "server.js",
+            src:     "const express = require('express')\n/* const client = require('prom-client')\n   client.collectDefaultMetrics() */\n",
+            pattern: "prom-client",
+        },
+        {
+            name:    "rust block comment",
+            file:    "main.rs",
+            src:     "fn main() {\n    /* let exporter = opentelemetry_otlp::new_exporter();\n    */\n}\n",
+            pattern: "opentelemetry_otlp",
+        },
+        {
+            name:    "python call",
+            file:    "app.py",
+            src:     "from prometheus_client import start_http_server\n\nstart_http_server(8000)\n",
+            pattern: "start_http_server",
+            want:    true,
+        },
+        {
+            name:    "python double-quoted docstring",
+            file:    "app.py",
+            src:     "def main():\n    \"\"\"Serve metrics.\n\n    start_http_server(8000)\n    \"\"\"\n    pass\n",
+            pattern: "start_http_server",
+        },
+        {
+            name:    "python single-quoted docstring",
+            file:    "app.py",
+            src:     "def main():\n    '''\n    start_http_server(8000)\n    '''\n    pass\n",
+            pattern: "start_http_server",
+        },
+        {
+            name:    "python hash comment",
+            file:    "app.py",
+            src:     "# start_http_server(8000)\nprint('hi')\n",
+            pattern: "start_http_server",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            dir := t.TempDir()
+            if err := os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.src), 0644); err != nil {
+                t.Fatal(err)
+            }
+            ext := strings.TrimPrefix(filepath.Ext(tt.file), ".")
+
+            files := findFilesInRepo(dir, tt.pattern, []string{ext})
+            if got := len(files) > 0; got != tt.want {
+                t.Errorf("findFilesInRepo(%q) = %v, want found %v", tt.pattern, files, tt.want)
+            }
+        })
+    }
+}
+
+func TestStripCommentsKeepsLines(t *testing.T) {
+    tests := []struct {
+        name string
+        file string
+        src  string
+        want string
+    }{
+        {
+            name: "c block comment",
+            file: "main.go",
+            src:  "a\n/* b\nc */\nd\n",
+            want: "a\n\n\nd\n",
+        },
+        {
+            name: "python docstring",
+            file: "app.py",
+            src:  "a\n\"\"\"b\nc\"\"\"\nd\n",
+            want: "a\n\n\nd\n",
+        },
+        {
+            name: "unknown extension",
+            file: "notes.txt",
+            src:  "/* a */\n",
+            want: "/* a */\n",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := stripComments(tt.src, tt.file); got != tt.want {
+                t.Errorf("stripComments() = %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,213 @@
+package scanner
+
+import (
+    "math"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// Evidence is something a module's language or framework was detected
+// from. Kind is "manifest" for the file marking the language, "dependency"
+// for a framework's line in it, or "source" for code using the framework.
+// File is repo-relative and Line 1-based.
+type Evidence struct {
+    Kind      string `json:"kind"`
+    Framework string `json:"framework,omitempty"`
+    File      string `json:"file"`
+    Line      int    `json:"line,omitempty"`
+    Text      string `json:"text,omitempty"`
+}
+
+// frameworkMarker is how a framework shows in a module: the dependency its
+// manifest declares and the calls that construct its app. Frameworks taken
+// by default, like net/http, have no dependency.
+type frameworkMarker struct {
+    Framework  string
+    Dependency string
+    Patterns   []string
+    Extensions []string
+}
+
+// frameworkMarkers are the frameworks of each language, matching what
+// detectModule checks
+var frameworkMarkers = map[string][]frameworkMarker{
+    "Python": {
+        {Framework: "Django", Dependency: "django", Patterns: []string{pythonAppPatterns["Django"]}, Extensions: pythonExtensions},
+        {Framework: "FastAPI", Dependency: "fastapi", Patterns: []string{pythonAppPatterns["FastAPI"]}, Extensions: pythonExtensions},
+        {Framework: "Flask", Dependency: "flask", Patterns: []string{pythonAppPatterns["Flask"]}, Extensions: pythonExtensions},
+    },
+    "Go": {
+        {Framework: "Gin", Dependency: "github.com/gin-gonic/gin", Patterns: []string{goRouterPatterns["Gin"]}, Extensions: goExtensions},
+        {Framework: "Echo", Dependency: "github.com/labstack/echo", Patterns: []string{goRouterPatterns["Echo"]}, Extensions: goExtensions},
+        {Framework: "Chi", Dependency: "github.com/go-chi/chi", Patterns: []string{goRouterPatterns["Chi"]}, Extensions: goExtensions},
+        {Framework: "Gorilla Mux", Dependency: "github.com/gorilla/mux", Patterns: []string{goRouterPatterns["Gorilla Mux"]}, Extensions: goExtensions},
+        {Framework: "net/http", Patterns: []string{"ListenAndServe"}, Extensions: goExtensions},
+    },
+    "Java": {
+        {Framework: "Quarkus", Dependency: "io.quarkus", Patterns: []string{javaAppPatterns["Quarkus"]}, Extensions: javaExtensions},
+        {Framework: "Micronaut", Dependency: "io.micronaut", Patterns: []string{javaAppPatterns["Micronaut"]}, Extensions: javaExtensions},
+        {Framework: "Spring Boot", Dependency: "org.springframework.boot", Patterns: []string{javaAppPatterns["Spring Boot"]}, Extensions: javaExtensions},
+    },
+    ".NET": {
+        {Framework: "ASP.NET Core", Dependency: "Microsoft.NET.Sdk.Web", Patterns: []string{"WebApplication.CreateBuilder("}, Extensions: dotnetExtensions},
+    },
+    "Ruby": {
+        {Framework: "Rails", Dependency: "rails", Patterns: rubyAppPatterns["Rails"], Extensions: rubyExtensions},
+        {Framework: "Sinatra", Dependency: "sinatra", Patterns: rubyAppPatterns["Sinatra"], Extensions: rubyExtensions},
+    },
+    "Node.js": {
+        {Framework: "Fastify", Dependency: `"fastify"`, Patterns: []string{"fastify("}, Extensions: nodeExtensions},
+        {Framework: "Koa", Dependency: `"koa"`, Patterns: []string{"new Koa("}, Extensions: nodeExtensions},
+        {Framework: "Hapi", Dependency: `"@hapi/hapi"`, Patterns: []string{"@hapi/hapi"}, Extensions: nodeExtensions},
+        {Framework: "Express", Dependency: `"express"`, Patterns: []string{"express()"}, Extensions: nodeExtensions},
+    },
+    "Rust": {
+        {Framework: "Actix", Dependency: "actix-web", Patterns: []string{"App::new()"}, Extensions: rustExtensions},
+        {Framework: "Axum", Dependency: "axum", Patterns: []string{"Router::new()"}, Extensions: rustExtensions},
+    },
+}
+
+// detectEvidence records why detection's language and framework were
+// chosen, and how confident that choice is. Each framework scores a point
+// for its dependency and one for code using it; Confidence is the chosen
+// framework's share of all points, so a repo depending on both Flask and
+// FastAPI but only constructing a FastAPI app scores FastAPI 0.67. A
+// framework taken by default without evidence scores 0.5, and a module
+// with no framework 0. The other frameworks scoring points are listed in
+// Alternatives.
+func detectEvidence(path, dir string, modules []string, detection *FrameworkDetection) {
+    repoPath := func(file string) string {
+        return filepath.ToSlash(filepath.Join(dir, file))
+    }
+    detection.Evidence = []Evidence{}
+    detection.Alternatives = nil
+
+    manifest := languageManifest(path, detection.Language)
+    if manifest != "" {
+        detection.Evidence = append(detection.Evidence, Evidence{Kind: "manifest", File: repoPath(manifest)})
+    }
+
+    markers := frameworkMarkers[detection.Language]
+    if server, ok := grpcServers[detection.Language]; ok && detection.Framework == "gRPC" {
+        markers = []frameworkMarker{{Framework: "gRPC", Dependency: server.Dependency, Patterns: []string{server.Pattern}, Extensions: server.Extensions}}
+    }
+
+    var chosen, total int
+    for _, marker := range markers {
+        var evidence []Evidence
+        if manifest != "" && marker.Dependency != "" {
+            if line, text := manifestLine(filepath.Join(path, manifest), marker.Dependency); line > 0 {
+                evidence = append(evidence, Evidence{Kind: "dependency", Framework: marker.Framework, File: repoPath(manifest), Line: line, Text: text})
+            }
+        }
+        for _, pattern := range marker.Patterns {
+            matches := scopeMatches(findMatchesInRepo(path, pattern, marker.Extensions), dir, modules)
+            if len(matches) > 0 {
+                m := matches[0]
+                evidence = append(evidence, Evidence{Kind: "source", Framework: marker.Framework, File: m.File, Line: m.Line, Text: m.Text})
+                break
+            }
+        }
+        if len(evidence) == 0 {
+            continue
+        }
+
+        total += len(evidence)
+        if marker.Framework == detection.Framework {
+            chosen = len(evidence)
+            detection.Evidence = append(detection.Evidence, evidence...)
+        } else {
+            detection.Alternatives = append(detection.Alternatives, marker.Framework)
+        }
+    }
+
+    switch {
+    case detection.Framework == "":
+        detection.Confidence = 0
+    case chosen == 0:
+        detection.Confidence = 0.5
+    default:
+        detection.Confidence = math.Round(float64(chosen)/float64(total)*100) / 100
+    }
+}
+
+// languageManifest returns the module file its language was detected from,
+// or ""
+func languageManifest(path, language string) string {
+    var names []string
+    switch language {
+    case "Python":
+        names = []string{"requirements.txt", "setup.py", "pyproject.toml", "Pipfile"}
+    case "Go":
+        names = []string{"go.mod"}
+    case "Java":
+        names = javaBuildFiles
+    case ".NET":
+        if projects, _ := filepath.Glob(filepath.Join(path, "*.csproj")); len(projects) > 0 {
+            return filepath.Base(projects[0])
+        }
+    case "Ruby":
+        names = []string{"Gemfile"}
+    case "Node.js":
+        names = []string{"package.json"}
+    case "Rust":
+        names = []string{"Cargo.toml"}
+    }
+    for _, name := range names {
+        if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+            return name
+        }
+    }
+    return ""
+}
+
+// manifestLine returns the first line of file containing dependency,
+// ignoring case, and its 1-based number, or 0
+func manifestLine(file, dependency string) (int, string) {
+    content, err := os.ReadFile(file)
+    if err != nil {
+        return 0, ""
+    }
+    needle := strings.ToLower(dependency)
+    for i, line := range strings.Split(string(content), "\n") {
+        if strings.Contains(strings.ToLower(line), needle) {
+            return i + 1, strings.TrimSpace(line)
+        }
+    }
+    return 0, ""
+}
+
+// FrameworkLanguage returns the language of a web framework the scanner
+// detects, matched ignoring case, along with the framework's name as
+// detected, e.g. "Python" and "FastAPI" for "fastapi"
+func FrameworkLanguage(name string) (language, framework string, ok bool) {
+    for _, language := range SupportedLanguages {
+        for _, marker := range frameworkMarkers[language] {
+            if strings.EqualFold(marker.Framework, strings.TrimSpace(name)) {
+                return language, marker.Framework, true
+            }
+        }
+    }
+    return "", "", false
+}
+
+// SupportedFrameworks lists the web frameworks the scanner detects, by
+// language in SupportedLanguages order
+func SupportedFrameworks() []string {
+    frameworks := []string{}
+    for _, language := range SupportedLanguages {
+        frameworks = append(frameworks, LanguageFrameworks(language)...)
+    }
+    return frameworks
+}
+
+// LanguageFrameworks lists the web frameworks the scanner detects for a
+// language
+func LanguageFrameworks(language string) []string {
+    frameworks := []string{}
+    for _, marker := range frameworkMarkers[language] {
+        frameworks = append(frameworks, marker.Framework)
+    }
+    return frameworks
+}
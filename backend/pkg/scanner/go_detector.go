@@ -0,0 +1,200 @@
+package scanner
+
+import (
+    "go/ast"
+    "go/parser"
+    "go/token"
+    "go/types"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// findGoMetricsPath parses the module's Go files for a route serving
+// promhttp.Handler() (or HandlerFor), e.g.
+//
+//	router.GET("/internal/metrics", gin.WrapH(promhttp.Handler()))
+//
+// and returns its path, or "" when the handler isn't routed anywhere.
+func findGoMetricsPath(path string) string {
+    metricsPath := ""
+    isSource := func(name string) bool {
+        return strings.HasSuffix(name, ".go") && !excludedFile(name)
+    }
+    walkRepoFiles(path, isSource, func(file, code string) bool {
+        if !strings.Contains(code, "promhttp.Handler") {
+            return false
+        }
+        parsed, err := parser.ParseFile(token.NewFileSet(), file, code, 0)
+        if err != nil {
+            return false
+        }
+
+        ast.Inspect(parsed, func(n ast.Node) bool {
+            call, ok := n.(*ast.CallExpr)
+            if !ok || metricsPath != "" || len(call.Args) < 2 {
+                return metricsPath == ""
+            }
+            route, ok := call.Args[0].(*ast.BasicLit)
+            if !ok || route.Kind != token.STRING {
+                return true
+            }
+            p, err := strconv.Unquote(route.Value)
+            if err != nil || !strings.HasPrefix(p, "/") {
+                return true
+            }
+            for _, arg := range call.Args[1:] {
+                if callsPromHandler(arg) {
+                    metricsPath = p
+                    return false
+                }
+            }
+            return true
+        })
+        return metricsPath != ""
+    })
+    return metricsPath
+}
+
+// callsPromHandler reports whether expr calls promhttp.Handler or
+// promhttp.HandlerFor, directly or wrapped (e.g. gin.WrapH)
+func callsPromHandler(expr ast.Expr) bool {
+    found := false
+    ast.Inspect(expr, func(n ast.Node) bool {
+        call, ok := n.(*ast.CallExpr)
+        if !ok {
+            return !found
+        }
+        if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+            if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "promhttp" &&
+                (sel.Sel.Name == "Handler" || sel.Sel.Name == "HandlerFor") {
+                found = true
+            }
+        }
+        return !found
+    })
+    return found
+}
+
+// goRouterVar returns the variable the framework's router is assigned to.
+// For net/http that is a mux the server serves, e.g. "mux" for
+// http.ListenAndServe(":8080", mux), and "" when it serves
+// http.DefaultServeMux.
+func goRouterVar(path string, files []string, framework string) string {
+    if framework != "net/http" {
+        return findGoRouterVar(path, files, goRouterPatterns[framework])
+    }
+    mux := findGoRouterVar(path, files, "http.NewServeMux()")
+    if mux == "" {
+        return ""
+    }
+    for _, file := range files {
+        src, err := os.ReadFile(filepath.Join(path, file))
+        if err != nil {
+            continue
+        }
+        parsed, err := parser.ParseFile(token.NewFileSet(), file, src, 0)
+        if err != nil {
+            continue
+        }
+        for _, handler := range goServedHandlers(parsed) {
+            if refersTo(handler, mux) {
+                return mux
+            }
+        }
+    }
+    return ""
+}
+
+// goServedHandlers returns the handlers passed to http.ListenAndServe and
+// http.ListenAndServeTLS, and set as an http.Server's Handler
+func goServedHandlers(file *ast.File) []ast.Expr {
+    handlers := []ast.Expr{}
+    ast.Inspect(file, func(n ast.Node) bool {
+        switch n := n.(type) {
+        case *ast.CallExpr:
+            switch types.ExprString(n.Fun) {
+            case "http.ListenAndServe":
+                if len(n.Args) == 2 {
+                    handlers = append(handlers, n.Args[1])
+                }
+            case "http.ListenAndServeTLS":
+                if len(n.Args) == 4 {
+                    handlers = append(handlers, n.Args[3])
+                }
+            }
+        case *ast.CompositeLit:
+            if types.ExprString(n.Type) != "http.Server" {
+                return true
+            }
+            for _, elt := range n.Elts {
+                if kv, ok := elt.(*ast.KeyValueExpr); ok && types.ExprString(kv.Key) == "Handler" {
+                    handlers = append(handlers, kv.Value)
+                }
+            }
+        }
+        return true
+    })
+    return handlers
+}
+
+// refersTo reports whether expr mentions the variable name, e.g. a mux
+// wrapped in middleware
+func refersTo(expr ast.Expr, name string) bool {
+    found := false
+    ast.Inspect(expr, func(n ast.Node) bool {
+        if id, ok := n.(*ast.Ident); ok && id.Name == name {
+            found = true
+        }
+        return !found
+    })
+    return found
+}
+
+// findGoRouterVar returns the variable the first of files assigns the
+// router constructor call to, e.g. "e" for `e := echo.New()`, or ""
+func findGoRouterVar(path string, files []string, constructor string) string {
+    for _, file := range files {
+        src, err := os.ReadFile(filepath.Join(path, file))
+        if err != nil {
+            continue
+        }
+        parsed, err := parser.ParseFile(token.NewFileSet(), file, src, 0)
+        if err != nil {
+            continue
+        }
+
+        name := ""
+        ast.Inspect(parsed, func(n ast.Node) bool {
+            if name != "" {
+                return false
+            }
+            var lhs []ast.Expr
+            var rhs []ast.Expr
+            switch stmt := n.(type) {
+            case *ast.AssignStmt:
+                lhs, rhs = stmt.Lhs, stmt.Rhs
+            case *ast.ValueSpec:
+                for _, id := range stmt.Names {
+                    lhs = append(lhs, id)
+                }
+                rhs = stmt.Values
+            default:
+                return true
+            }
+            for i, value := range rhs {
+                if i < len(lhs) && types.ExprString(value) == constructor {
+                    if id, ok := lhs[i].(*ast.Ident); ok && id.Name != "_" {
+                        name = id.Name
+                    }
+                }
+            }
+            return name == ""
+        })
+        if name != "" {
+            return name
+        }
+    }
+    return ""
+}
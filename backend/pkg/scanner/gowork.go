@@ -0,0 +1,89 @@
+package scanner
+
+import (
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "golang.org/x/mod/modfile"
+)
+
+// goWorkspace returns the repo-relative module directories a go.work at
+// the repo root uses, and whether there is one. Directories outside the
+// repo are left out.
+func goWorkspace(root string) (map[string]bool, bool) {
+    content, err := os.ReadFile(filepath.Join(root, "go.work"))
+    if err != nil {
+        return nil, false
+    }
+    work, err := modfile.ParseWork("go.work", content, nil)
+    if err != nil {
+        return nil, false
+    }
+
+    dirs := map[string]bool{}
+    for _, use := range work.Use {
+        dir := path.Clean(filepath.ToSlash(use.Path))
+        if path.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+            continue
+        }
+        dirs[dir] = true
+    }
+    return dirs, true
+}
+
+// applyGoWorkspace restricts the Go modules among modules to those the
+// repo's go.work uses, adding any its walk skipped, e.g. under build/.
+// Without a go.work every go.mod is a module of its own.
+func applyGoWorkspace(root string, modules []string) []string {
+    used, ok := goWorkspace(root)
+    if !ok {
+        return modules
+    }
+
+    kept := []string{}
+    for _, dir := range modules {
+        if used[dir] || !onlyGoModule(filepath.Join(root, dir)) {
+            kept = append(kept, dir)
+        }
+    }
+    missing := []string{}
+    for dir := range used {
+        if containsString(kept, dir) {
+            continue
+        }
+        if _, err := os.Stat(filepath.Join(root, dir, "go.mod")); err == nil {
+            missing = append(missing, dir)
+        }
+    }
+    sort.Strings(missing)
+    return append(kept, missing...)
+}
+
+// onlyGoModule reports whether go.mod is the directory's only build
+// manifest
+func onlyGoModule(dir string) bool {
+    if _, err := os.Stat(filepath.Join(dir, "go.mod")); err != nil {
+        return false
+    }
+    for _, manifest := range moduleManifests {
+        if manifest == "go.mod" {
+            continue
+        }
+        if _, err := os.Stat(filepath.Join(dir, manifest)); err == nil {
+            return false
+        }
+    }
+    projects, _ := filepath.Glob(filepath.Join(dir, "*.csproj"))
+    return len(projects) == 0
+}
+
+// nestedGoModule reports whether dir, below the Go module being searched,
+// is a module of its own. Like the go tool, searches of a module leave
+// nested modules to their own service.
+func nestedGoModule(dir string) bool {
+    _, err := os.Stat(filepath.Join(dir, "go.mod"))
+    return err == nil
+}
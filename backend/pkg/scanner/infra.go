@@ -0,0 +1,77 @@
+package scanner
+
+import (
+    "path/filepath"
+    "regexp"
+)
+
+// Kinds of observability infrastructure a repo may already ship
+const (
+    InfraOTelCollector = "otel-collector"
+    InfraPrometheus    = "prometheus"
+)
+
+// InfraFile is an observability config file found in the repo
+type InfraFile struct {
+    // Kind is InfraOTelCollector or InfraPrometheus
+    Kind string `json:"kind"`
+    // Path is repo-relative
+    Path string `json:"path"`
+}
+
+var (
+    // A collector config has receivers, exporters and pipelines, possibly
+    // indented inside a ConfigMap
+    collectorReceivers = regexp.MustCompile(`(?m)^\s*receivers:\s*$`)
+    collectorExporters = regexp.MustCompile(`(?m)^\s*exporters:\s*$`)
+    collectorPipelines = regexp.MustCompile(`(?m)^\s*pipelines:\s*$`)
+
+    // A Prometheus config scrapes targets; the operator uses ServiceMonitor
+    // and PodMonitor resources instead
+    prometheusScrape  = regexp.MustCompile(`(?m)^\s*scrape_configs:`)
+    prometheusMonitor = regexp.MustCompile(`(?m)^kind:\s*(?:ServiceMonitor|PodMonitor)\s*$`)
+
+    // Compose services or Kubernetes containers running the collector or
+    // Prometheus images
+    composeCollector  = regexp.MustCompile(`(?m)^\s*image:\s*["']?[\w./-]*(?:opentelemetry-collector|otelcol)[\w.-]*(?::\S+)?["']?\s*$`)
+    composePrometheus = regexp.MustCompile(`(?m)^\s*image:\s*["']?[\w./-]*prom/prometheus(?::\S+)?["']?\s*$`)
+)
+
+// detectInfra finds OpenTelemetry Collector and Prometheus configs in the
+// checkout at root, including docker-compose files and manifests running
+// either, so the generator needn't add them again
+func detectInfra(root string) []InfraFile {
+    isYAML := func(name string) bool {
+        ext := filepath.Ext(name)
+        return ext == ".yaml" || ext == ".yml"
+    }
+
+    found := []InfraFile{}
+    walkFiles(root, false, isYAML, func(path, code string) bool {
+        rel, err := filepath.Rel(root, path)
+        if err != nil {
+            return false
+        }
+        rel = filepath.ToSlash(rel)
+
+        isCollector := collectorReceivers.MatchString(code) && collectorExporters.MatchString(code) && collectorPipelines.MatchString(code)
+        if isCollector || composeCollector.MatchString(code) {
+            found = append(found, InfraFile{Kind: InfraOTelCollector, Path: rel})
+        }
+        if prometheusScrape.MatchString(code) || prometheusMonitor.MatchString(code) || composePrometheus.MatchString(code) {
+            found = append(found, InfraFile{Kind: InfraPrometheus, Path: rel})
+        }
+        return false
+    })
+    return found
+}
+
+// HasInfra reports whether the scan found an existing config of kind
+func (r *ScanResult) HasInfra(kind string) bool {
+    for _, f := range r.ExistingObservability {
+        if f.Kind == kind {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,67 @@
+package scanner
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+func TestJavaCandidate(t *testing.T) {
+    app := "package com.example;\n\n@SpringBootApplication\npublic class Application {}\n"
+    tests := []struct {
+        name  string
+        files map[string]string
+        want  Candidate
+    }{
+        {
+            name: "maven spring boot",
+            files: map[string]string{
+                "pom.xml": "<project><dependencies></dependencies></project>\n",
+                "src/main/java/com/example/Application.java": app,
+            },
+            want: Candidate{Kind: "http", Framework: "Spring Boot", Manifest: "pom.xml", Files: []string{"src/main/java/com/example/Application.java"}},
+        },
+        {
+            name: "gradle spring boot",
+            files: map[string]string{
+                "build.gradle": "plugins {\n    id 'org.springframework.boot' version '3.2.0'\n}\n\ndependencies {\n    implementation 'org.springframework.boot:spring-boot-starter-web'\n}\n",
+                "src/main/java/com/example/Application.java": app,
+            },
+            want: Candidate{Kind: "http", Framework: "Spring Boot", Manifest: "build.gradle", Files: []string{"src/main/java/com/example/Application.java"}},
+        },
+        {
+            name: "gradle kotlin dsl without application class",
+            files: map[string]string{
+                "build.gradle.kts": "dependencies {\n    implementation(\"org.springframework.boot:spring-boot-starter-web\")\n}\n",
+            },
+            want: Candidate{Kind: "http", Framework: "Spring Boot", Manifest: "build.gradle.kts", Files: []string{"build.gradle.kts"}},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            dir := t.TempDir()
+            for name, content := range tt.files {
+                path := filepath.Join(dir, name)
+                if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+                    t.Fatal(err)
+                }
+                if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+                    t.Fatal(err)
+                }
+            }
+
+            if framework := detectJavaFramework(dir); framework != tt.want.Framework {
+                t.Errorf("detectJavaFramework() = %q, want %q", framework, tt.want.Framework)
+            }
+            got, ok := javaCandidate(dir, tt.want.Framework)
+            if !ok {
+                t.Fatal("javaCandidate() found no candidate")
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("javaCandidate() = %+v, want %+v", got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,115 @@
+package scanner
+
+import (
+    "io/fs"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+var (
+    // Imports match CommonJS require, ES/TypeScript import ... from, bare
+    // imports and dynamic import(), including tsc's transpiled output
+    promClientImport = regexp.MustCompile(`(?:require|import)\(\s*['"]prom-client['"]\s*\)|(?:from|import)\s+['"]prom-client['"]`)
+    promRegistration = regexp.MustCompile(`collectDefaultMetrics\)?\(|registerMetric\(|new\s+(?:[\w.]+\.)?(?:Counter|Gauge|Histogram|Summary)(?:<[^>()]*>)?\(`)
+    promUsage        = regexp.MustCompile(`\.(?:inc|dec|set|observe|startTimer)\(|\.metrics\(\)`)
+
+    // NestJS registers metrics through @willsoto/nestjs-prometheus, whose
+    // module serves default metrics on its own
+    nestPromImport = regexp.MustCompile(`(?:from|import)\s+['"]@willsoto/nestjs-prometheus['"]|require\(\s*['"]@willsoto/nestjs-prometheus['"]\s*\)`)
+    nestPromModule = regexp.MustCompile(`PrometheusModule\.register(?:Async)?\(|makeCounterProvider\(|makeHistogramProvider\(`)
+
+    otelImport   = regexp.MustCompile(`(?:require|import)\(\s*['"]@opentelemetry/[\w-]+['"]\s*\)|(?:from|import)\s+['"]@opentelemetry/[\w-]+['"]`)
+    otelProvider = regexp.MustCompile(`new\s+(?:[\w.]+\.)?(?:NodeSDK|NodeTracerProvider|BasicTracerProvider|WebTracerProvider)\(`)
+    otelActivate = regexp.MustCompile(`\.start\(\)|\.register\(`)
+
+    // NestJS sets up tracing through an OpenTelemetryModule from
+    // nestjs-otel or @metinseylan/nestjs-opentelemetry
+    nestOTelImport = regexp.MustCompile(`(?:from|import)\s+['"](?:nestjs-otel|@metinseylan/nestjs-opentelemetry)['"]`)
+    nestOTelModule = regexp.MustCompile(`OpenTelemetryModule\.forRoot(?:Async)?\(`)
+)
+
+// nodeSkipDirs are directories that never contain first-party source
+var nodeSkipDirs = map[string]bool{
+    "node_modules": true,
+    ".git":         true,
+    "dist":         true,
+    "build":        true,
+    "coverage":     true,
+}
+
+// AnalyzeNodeRepo inspects JavaScript/TypeScript sources with comments stripped
+// and returns metrics/traces candidates only where instrumentation is invoked.
+func AnalyzeNodeRepo(root string) ([]Candidate, error) {
+    metricsFiles := []string{}
+    traceFiles := []string{}
+    metricsUsed := false
+
+    err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if d.IsDir() {
+            if nodeSkipDirs[d.Name()] {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        if !hasExtension(path, nodeExtensions) || excludedFile(d.Name()) || strings.HasSuffix(d.Name(), ".d.ts") {
+            return nil
+        }
+
+        content, err := os.ReadFile(path)
+        if err != nil {
+            return err
+        }
+        src := stripJSComments(string(content))
+        rel, _ := filepath.Rel(root, path)
+        rel = filepath.ToSlash(rel)
+
+        if promClientImport.MatchString(src) && promRegistration.MatchString(src) {
+            metricsFiles = append(metricsFiles, rel)
+        } else if nestPromImport.MatchString(src) && nestPromModule.MatchString(src) {
+            metricsFiles = append(metricsFiles, rel)
+            metricsUsed = true
+        }
+        if promUsage.MatchString(src) {
+            metricsUsed = true
+        }
+        if otelImport.MatchString(src) && otelProvider.MatchString(src) && otelActivate.MatchString(src) {
+            traceFiles = append(traceFiles, rel)
+        } else if nestOTelImport.MatchString(src) && nestOTelModule.MatchString(src) {
+            traceFiles = append(traceFiles, rel)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    candidates := []Candidate{}
+    if len(metricsFiles) > 0 && metricsUsed {
+        candidates = append(candidates, Candidate{Kind: "metrics", Framework: "prom-client", Files: metricsFiles})
+    }
+    if len(traceFiles) > 0 {
+        candidates = append(candidates, Candidate{Kind: "traces", Framework: "OpenTelemetry", Files: traceFiles})
+    }
+    return candidates, nil
+}
+
+func hasExtension(path string, extensions []string) bool {
+    ext := strings.TrimPrefix(filepath.Ext(path), ".")
+    for _, e := range extensions {
+        if ext == e {
+            return true
+        }
+    }
+    return false
+}
+
+// stripJSComments removes // and /* */ comments while leaving string and
+// template literals intact, so commented-out code is never matched.
+func stripJSComments(src string) string {
+    return stripCComments(src, "'\"`")
+}
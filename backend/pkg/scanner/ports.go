@@ -0,0 +1,121 @@
+package scanner
+
+import (
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// portSource is where a language declares its listening port: files with
+// one of extensions or named in names, matched against patterns whose
+// first group is the port
+type portSource struct {
+    extensions []string
+    names      []string
+    patterns   []*regexp.Regexp
+}
+
+var portSources = map[string]portSource{
+    "Go": {
+        extensions: goExtensions,
+        patterns: []*regexp.Regexp{
+            // r.Run(":8080"), e.Start(":8080"), http.ListenAndServe(":8080", r)
+            regexp.MustCompile(`\b(?:Run|Start|ListenAndServe(?:TLS)?|Listen)\([^)]*?"[^"]*:(\d{2,5})"`),
+            // &http.Server{Addr: ":8080"}
+            regexp.MustCompile(`\bAddr:\s*"[^"]*:(\d{2,5})"`),
+        },
+    },
+    "Python": {
+        extensions: pythonExtensions,
+        patterns: []*regexp.Regexp{
+            // app.run(port=5000), uvicorn.run(app, port=8000)
+            regexp.MustCompile(`\.run(?:_app)?\([^)]*\bport\s*=\s*(\d{2,5})`),
+            // gunicorn.conf.py: bind = "0.0.0.0:8000"
+            regexp.MustCompile(`\bbind\s*=\s*["'][^"']*:(\d{2,5})["']`),
+        },
+    },
+    "Java": {
+        names: []string{"application.properties", "application.yml", "application.yaml"},
+        patterns: []*regexp.Regexp{
+            // server.port=8081, quarkus.http.port=8081, micronaut.server.port=8081
+            regexp.MustCompile(`(?m)^\s*(?:server|quarkus\.http|micronaut\.server)\.port\s*[=:]\s*(\d{2,5})\s*$`),
+            // server:
+            //   port: 8081
+            regexp.MustCompile(`(?m)^\s*server:\s*\n(?:[ \t]+.*\n)*?[ \t]+port:\s*(\d{2,5})\s*$`),
+        },
+    },
+    "Node.js": {
+        extensions: nodeExtensions,
+        patterns: []*regexp.Regexp{
+            // app.listen(3000), fastify.listen({ port: 3000 })
+            regexp.MustCompile(`\.listen\(\s*(?:\{\s*port:\s*)?(\d{2,5})`),
+            // const port = process.env.PORT || 3000
+            regexp.MustCompile(`\bPORT\b[^\n]*?(?:\|\||\?\?)\s*["']?(\d{2,5})`),
+        },
+    },
+    "Ruby": {
+        extensions: rubyExtensions,
+        patterns: []*regexp.Regexp{
+            // Sinatra: set :port, 4567
+            regexp.MustCompile(`\bset\s+:port,\s*(\d{2,5})`),
+            // Puma: port ENV.fetch("PORT") { 3000 }, or port 3000
+            regexp.MustCompile(`(?m)^\s*port\s+ENV\.fetch\(\s*["']PORT["']\s*\)\s*\{\s*(\d{2,5})\s*\}`),
+            regexp.MustCompile(`(?m)^\s*port\s+(\d{2,5})\s*$`),
+        },
+    },
+    ".NET": {
+        extensions: dotnetExtensions,
+        names:      []string{"appsettings.json", "launchSettings.json"},
+        patterns: []*regexp.Regexp{
+            // app.Run("http://0.0.0.0:5000"), UseUrls("http://*:5000")
+            regexp.MustCompile(`\b(?:Run|UseUrls)\(\s*"https?://[^"]*:(\d{2,5})`),
+            // options.ListenAnyIP(5000)
+            regexp.MustCompile(`\bListen(?:AnyIP|Localhost)\(\s*(\d{2,5})`),
+            // "applicationUrl": "http://localhost:5000", Kestrel "Url"
+            regexp.MustCompile(`"(?:applicationUrl|Url)"\s*:\s*"https?://[^":;]*:(\d{2,5})`),
+        },
+    },
+    "Rust": {
+        extensions: rustExtensions,
+        patterns: []*regexp.Regexp{
+            // HttpServer::new(..).bind(("0.0.0.0", 8080))
+            regexp.MustCompile(`\bbind\(\s*\(\s*"[^"]*"\s*,\s*(\d{2,5})\s*\)`),
+            // TcpListener::bind("0.0.0.0:3000")
+            regexp.MustCompile(`\bbind\(\s*"[^"]*:(\d{2,5})"`),
+            // SocketAddr::from(([0, 0, 0, 0], 3000))
+            regexp.MustCompile(`SocketAddr::from\(\(\s*\[[^\]]*\]\s*,\s*(\d{2,5})\s*\)\)`),
+        },
+    },
+}
+
+// detectPort returns the port the module's app listens on, as hardcoded in
+// its source or config, or 0 if none is found. Test and generated files
+// are ignored.
+func detectPort(path, language string) int {
+    source, ok := portSources[language]
+    if !ok {
+        return 0
+    }
+    include := func(name string) bool {
+        if excludedFile(name) {
+            return false
+        }
+        ext := strings.TrimPrefix(filepath.Ext(name), ".")
+        return containsString(source.extensions, ext) || containsString(source.names, name)
+    }
+
+    port := 0
+    walkRepoFiles(path, include, func(_, code string) bool {
+        for _, re := range source.patterns {
+            if m := re.FindStringSubmatch(code); m != nil {
+                if n, err := strconv.Atoi(m[1]); err == nil && n > 0 && n <= 65535 {
+                    port = n
+                    return true
+                }
+            }
+        }
+        return false
+    })
+    return port
+}
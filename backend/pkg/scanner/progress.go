@@ -0,0 +1,30 @@
+package scanner
+
+// Scan progress stages, in the order a scan reports them
+const (
+    StageCloneStarted     = "clone_started"
+    StageCloned           = "cloned"
+    StageLanguageDetected = "language_detected"
+    StageCandidatesFound  = "candidates_found"
+    StageComplete         = "complete"
+)
+
+// ScanEvent reports a step of a scan in progress
+type ScanEvent struct {
+    Stage      string `json:"stage"`
+    Message    string `json:"message"`
+    Path       string `json:"path,omitempty"`
+    Language   string `json:"language,omitempty"`
+    Framework  string `json:"framework,omitempty"`
+    Candidates int    `json:"candidates,omitempty"`
+}
+
+// ProgressFunc receives scan events as they happen. A nil ProgressFunc
+// discards them.
+type ProgressFunc func(ScanEvent)
+
+func (p ProgressFunc) emit(event ScanEvent) {
+    if p != nil {
+        p(event)
+    }
+}
@@ -0,0 +1,36 @@
+package scanner
+
+import (
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// pythonMetricsRoute matches a Flask or FastAPI route serving metrics, e.g.
+//
+//	@app.route('/metrics')
+//	app.mount("/metrics", make_asgi_app())
+var pythonMetricsRoute = regexp.MustCompile(`\.(?:route|get|add_url_rule|mount)\(\s*["'](/[\w/.-]*metrics)/?["']`)
+
+// pythonGeneratedFiles are the modules the generator writes. A metrics
+// route in them is the generator's own, not one the app already had.
+var pythonGeneratedFiles = map[string]bool{
+    "metrics_config.py": true,
+    "otel_config.py":    true,
+}
+
+// findPythonMetricsPath returns the route the module's Python files already
+// serve metrics on, or "" when there is none
+func findPythonMetricsPath(path string) string {
+    metricsPath := ""
+    isSource := func(name string) bool {
+        return strings.HasSuffix(name, ".py") && !excludedFile(name) && !pythonGeneratedFiles[filepath.Base(name)]
+    }
+    walkRepoFiles(path, isSource, func(file, code string) bool {
+        if route := pythonMetricsRoute.FindStringSubmatch(code); route != nil {
+            metricsPath = route[1]
+        }
+        return metricsPath != ""
+    })
+    return metricsPath
+}
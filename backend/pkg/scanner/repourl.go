@@ -0,0 +1,40 @@
+package scanner
+
+import (
+    "regexp"
+    "strings"
+)
+
+// scpLikeURL matches the scp-style SSH form git accepts, e.g.
+// git@github.com:owner/repo.git
+var scpLikeURL = regexp.MustCompile(`^(?:[\w.-]+@)?([\w.-]+):([^/][^:]*)$`)
+
+// NormalizeRepoURL rewrites an SSH clone URL to its https equivalent so it
+// can be cloned with token auth and parsed like any other repo URL:
+//
+//  git@github.com:owner/repo.git         -> https://github.com/owner/repo.git
+//  ssh://git@gitlab.com:2222/group/repo  -> https://gitlab.com/group/repo
+//
+// Other URLs are returned trimmed but otherwise unchanged.
+func NormalizeRepoURL(repoURL string) string {
+    repoURL = strings.TrimSpace(repoURL)
+
+    if rest, ok := strings.CutPrefix(repoURL, "ssh://"); ok {
+        host, path, _ := strings.Cut(rest, "/")
+        if at := strings.LastIndex(host, "@"); at >= 0 {
+            host = host[at+1:]
+        }
+        if colon := strings.Index(host, ":"); colon >= 0 {
+            host = host[:colon]
+        }
+        return "https://" + host + "/" + path
+    }
+
+    if strings.Contains(repoURL, "://") {
+        return repoURL
+    }
+    if m := scpLikeURL.FindStringSubmatch(repoURL); m != nil {
+        return "https://" + m[1] + "/" + m[2]
+    }
+    return repoURL
+}
@@ -0,0 +1,54 @@
+package scanner
+
+import "testing"
+
+func TestNormalizeRepoURL(t *testing.T) {
+    tests := []struct {
+        name    string
+        repoURL string
+        want    string
+    }{
+        {name: "scp-like ssh", repoURL: "git@github.com:owner/repo.git", want: "https://github.com/owner/repo.git"},
+        {name: "scp-like ssh without suffix", repoURL: "git@github.com:owner/repo", want: "https://github.com/owner/repo"},
+        {name: "scp-like ssh without user", repoURL: "github.com:owner/repo.git", want: "https://github.com/owner/repo.git"},
+        {name: "scp-like ssh to enterprise host", repoURL: "git@github.mycorp.com:team/service.git", want: "https://github.mycorp.com/team/service.git"},
+        {name: "ssh scheme", repoURL: "ssh://git@github.com/owner/repo.git", want: "https://github.com/owner/repo.git"},
+        {name: "ssh scheme with port", repoURL: "ssh://git@gitlab.com:2222/group/repo", want: "https://gitlab.com/group/repo"},
+        {name: "https", repoURL: "https://github.com/owner/repo.git", want: "https://github.com/owner/repo.git"},
+        {name: "https with whitespace", repoURL: "  https://github.com/owner/repo\n", want: "https://github.com/owner/repo"},
+        {name: "http", repoURL: "http://gitea.local/owner/repo", want: "http://gitea.local/owner/repo"},
+        {name: "empty", repoURL: "", want: ""},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := NormalizeRepoURL(tt.repoURL); got != tt.want {
+                t.Errorf("NormalizeRepoURL(%q) = %q, want %q", tt.repoURL, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestGitHubAuthForSSHURL(t *testing.T) {
+    tests := []struct {
+        name     string
+        repoURL  string
+        token    string
+        wantAuth bool
+    }{
+        {name: "https with token", repoURL: "https://github.com/owner/repo.git", token: "t0ken", wantAuth: true},
+        {name: "scp-like ssh with token", repoURL: "git@github.com:owner/repo.git", token: "t0ken", wantAuth: true},
+        {name: "ssh scheme with token", repoURL: "ssh://git@github.com/owner/repo.git", token: "t0ken", wantAuth: true},
+        {name: "ssh without token", repoURL: "git@github.com:owner/repo.git", token: ""},
+        {name: "other host", repoURL: "git@gitlab.com:owner/repo.git", token: "t0ken"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            auth := GitHubAuth(NormalizeRepoURL(tt.repoURL), tt.token)
+            if got := auth != nil; got != tt.wantAuth {
+                t.Errorf("GitHubAuth(%q) = %v, want auth %v", tt.repoURL, auth, tt.wantAuth)
+            }
+        })
+    }
+}
@@ -1,62 +1,551 @@
 package scanner
 
 import (
+    "context"
     "fmt"
     "os"
-    "os/exec"
     "path/filepath"
+    "regexp"
     "strings"
+    "time"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
+    "github.com/go-git/go-git/v5/plumbing/transport"
+    githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+    "golang.org/x/mod/modfile"
+
+    "observability-copilot/pkg/logging"
 )
 
 type ScanResult struct {
-    Framework   string   `json:"framework"`
-    HasMetrics  bool     `json:"has_metrics"`
-    HasOTel     bool     `json:"has_otel"`
-    Services    []string `json:"services"`
-}
-
-func ScanRepo(repoURL, repoID string) (*ScanResult, error) {
-    clonePath := filepath.Join("/tmp", repoID)
-    os.RemoveAll(clonePath)
-
-    cmd := exec.Command("git", "clone", "--depth=1", repoURL, clonePath)
-    if err := cmd.Run(); err != nil {
-        return nil, fmt.Errorf("failed to clone: %w", err)
-    }
-
-    result := &ScanResult{Services: []string{}}
-
-    if detectPython(clonePath) {
-        result.Framework = "Python"
-        if detectDjango(clonePath) {
-            result.Services = append(result.Services, "django-app")
-        } else if detectFlask(clonePath) {
-            result.Services = append(result.Services, "flask-app")
-        }
-    } else if detectGo(clonePath) {
-        result.Framework = "Go"
-        result.Services = append(result.Services, "go-service")
-    } else if detectJava(clonePath) {
-        result.Framework = "Java"
-        result.Services = append(result.Services, "java-service")
-    } else if detectDotnet(clonePath) {
-        result.Framework = ".NET"
-        result.Services = append(result.Services, "dotnet-service")
-    } else if detectNode(clonePath) {
-        result.Framework = "Node.js"
-        result.Services = append(result.Services, "nodejs-service")
-    } else if detectRust(clonePath) {
-        result.Framework = "Rust"
-        result.Services = append(result.Services, "rust-service")
-    }
-
-    result.HasMetrics = detectMetrics(clonePath, result.Framework)
-    result.HasOTel = detectOTel(clonePath, result.Framework)
-
-    os.RemoveAll(clonePath)
+    Framework   string               `json:"framework"`
+    HasMetrics  bool                 `json:"has_metrics"`
+    HasOTel     bool                 `json:"has_otel"`
+    Services    []string             `json:"services"`
+    Detections  []FrameworkDetection `json:"detections"`
+    // ExistingObservability lists collector and Prometheus configs the
+    // repo already ships
+    ExistingObservability []InfraFile `json:"existing_observability"`
+    // Unsupported is set when no module of a supported language was
+    // found, e.g. in a C++ or Elixir repo
+    Unsupported bool `json:"unsupported"`
+}
+
+// SupportedLanguages are the languages the scanner detects services in
+var SupportedLanguages = []string{"Go", "Python", "Java", "Node.js", ".NET", "Rust", "Ruby"}
+
+// FrameworkDetection describes one service (module directory) found in the repo
+type FrameworkDetection struct {
+    ServiceName string      `json:"service_name"`
+    Language    string      `json:"language"`
+    Framework   string      `json:"framework"`
+    // FrameworkVersion is the framework dependency's version from the
+    // module's manifest, e.g. "v1.9.1" for Gin or "2.3.2" for Flask
+    FrameworkVersion string `json:"framework_version,omitempty"`
+    // Port is the port the app listens on as hardcoded in its source or
+    // config, e.g. 8080 for r.Run(":8080"); 0 when not found
+    Port        int         `json:"port,omitempty"`
+    // ModulePath is a Go module's path from its go.mod, e.g.
+    // "github.com/acme/api", which its packages are imported under
+    ModulePath  string      `json:"module_path,omitempty"`
+    Path        string      `json:"path"`
+    HasMetrics  bool        `json:"has_metrics"`
+    HasOTel     bool        `json:"has_otel"`
+    Candidates  []Candidate `json:"candidates"`
+    // Evidence is what the language and framework were detected from, e.g.
+    // the go.mod require line and the gin.Default() call. Confidence, 0 to
+    // 1, drops when the module also shows the Alternatives frameworks.
+    Evidence     []Evidence `json:"evidence"`
+    Confidence   float64    `json:"confidence"`
+    Alternatives []string   `json:"alternatives,omitempty"`
+}
+
+// Candidate is a set of repo-relative files the generator can anchor
+// instrumentation on, e.g. the files that construct the HTTP app.
+type Candidate struct {
+    Kind      string   `json:"kind"`
+    Framework string   `json:"framework"`
+    Manifest  string   `json:"manifest,omitempty"`
+    Files     []string `json:"files"`
+
+    // MetricsPath is the route already serving Prometheus metrics, if any
+    MetricsPath string `json:"metrics_path,omitempty"`
+
+    // RouterVar is the variable the router is assigned to, e.g. "e" for
+    // e := echo.New()
+    RouterVar string `json:"router_var,omitempty"`
+
+    // Matches are the lines in Files where the candidate was detected,
+    // e.g. the router or app construction, so previews can point at them
+    Matches []Match `json:"matches,omitempty"`
+}
+
+// Match is a line a candidate was detected on. Line is 1-based.
+type Match struct {
+    File string `json:"file"`
+    Line int    `json:"line"`
+    Text string `json:"text"`
+}
+
+// ScanRepo clones the repo and detects its services. An empty branch scans
+// the default branch. A non-empty subpath restricts detection to that
+// subtree; reported paths stay repo-relative. The scan logs with ctx's
+// request ID and stops with ctx's error once ctx is done.
+func ScanRepo(ctx context.Context, repoURL, repoID, branch, subpath string) (*ScanResult, error) {
+    return ScanRepoWithProgress(ctx, repoURL, repoID, branch, subpath, nil)
+}
+
+// ScanRepoWithProgress is ScanRepo reporting each step to progress
+func ScanRepoWithProgress(ctx context.Context, repoURL, repoID, branch, subpath string, progress ProgressFunc) (*ScanResult, error) {
+    return scanRepo(ctx, repoURL, repoID, branch, subpath, progress, nil)
+}
+
+// InspectRepo scans the repo like ScanRepo, then calls inspect with the
+// checkout directory and result before the checkout is released. Changes
+// inspect makes to the checkout are discarded.
+func InspectRepo(ctx context.Context, repoURL, repoID, branch, subpath string, inspect func(dir string, result *ScanResult) error) (*ScanResult, error) {
+    return scanRepo(ctx, repoURL, repoID, branch, subpath, nil, inspect)
+}
+
+// ScanLocalPath detects the services of an existing checkout at path, e.g.
+// the workspace of a CI job, without cloning. The directory is only read,
+// never modified or removed.
+func ScanLocalPath(path, subpath string) (*ScanResult, error) {
+    info, err := os.Stat(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read %s: %w", path, err)
+    }
+    if !info.IsDir() {
+        return nil, fmt.Errorf("%s is not a directory", path)
+    }
+    return scanDir(context.Background(), path, subpath, nil)
+}
+
+func scanRepo(ctx context.Context, repoURL, repoID, branch, subpath string, progress ProgressFunc, inspect func(string, *ScanResult) error) (*ScanResult, error) {
+    repoURL = NormalizeRepoURL(repoURL)
+
+    // Authenticate with the GitHub token when set so private repos can be scanned
+    opts := &git.CloneOptions{
+        URL:   repoURL,
+        Depth: 1,
+        Auth:  GitHubAuth(repoURL, settings.GitHubToken),
+    }
+    if branch != "" {
+        opts.ReferenceName = plumbing.NewBranchReferenceName(branch)
+        opts.SingleBranch = true
+    }
+    log := logging.FromContext(ctx).With("repo", repoURL, "branch", branch, "subpath", subpath)
+    start := time.Now()
+    progress.emit(ScanEvent{Stage: StageCloneStarted, Message: "Cloning " + repoURL})
+    clonePath, cached, release, err := checkout(ctx, repoID, opts)
+    if err != nil {
+        log.Warn("clone failed", "error", err)
+        return nil, err
+    }
+    defer release()
+    if cached {
+        progress.emit(ScanEvent{Stage: StageCloned, Message: "Repository unchanged, using cached clone"})
+        log.Debug("reused cached clone", "duration_ms", time.Since(start).Milliseconds())
+    } else {
+        progress.emit(ScanEvent{Stage: StageCloned, Message: "Clone complete"})
+        log.Debug("cloned repository", "duration_ms", time.Since(start).Milliseconds())
+    }
+
+    result, err := scanDir(ctx, clonePath, subpath, progress)
+    if err != nil {
+        return nil, err
+    }
+    log.Info("scan complete", "framework", result.Framework, "services", len(result.Services), "duration_ms", time.Since(start).Milliseconds())
+    if inspect != nil {
+        if err := inspect(clonePath, result); err != nil {
+            return nil, err
+        }
+    }
+    return result, nil
+}
+
+// scanDir detects the services of the checkout at clonePath, restricted to
+// subpath
+func scanDir(ctx context.Context, clonePath, subpath string, progress ProgressFunc) (*ScanResult, error) {
+    subpath = filepath.ToSlash(filepath.Clean(subpath))
+    if subpath == ".." || strings.HasPrefix(subpath, "../") || filepath.IsAbs(subpath) {
+        return nil, fmt.Errorf("invalid subpath: %s", subpath)
+    }
+    if info, err := os.Stat(filepath.Join(clonePath, subpath)); err != nil || !info.IsDir() {
+        return nil, fmt.Errorf("subpath %q not found in repository", subpath)
+    }
+
+    result := &ScanResult{Services: []string{}, Detections: []FrameworkDetection{}}
+
+    modules := findModules(clonePath)
+    for _, dir := range modules {
+        if err := ctx.Err(); err != nil {
+            return nil, err
+        }
+        if !withinSubpath(dir, subpath) {
+            continue
+        }
+        detection, ok := detectModule(ctx, clonePath, dir, modules, progress)
+        if !ok {
+            continue
+        }
+        if result.Framework == "" {
+            result.Framework = detection.Language
+        }
+        result.HasMetrics = result.HasMetrics || detection.HasMetrics
+        result.HasOTel = result.HasOTel || detection.HasOTel
+        result.Detections = append(result.Detections, detection)
+    }
+    uniqueServiceNames(result.Detections)
+    for _, detection := range result.Detections {
+        result.Services = append(result.Services, detection.ServiceName)
+    }
+
+    // The subpath may be a package inside a larger module: detect the owning
+    // module but keep only candidates under the subpath
+    if len(result.Detections) == 0 && subpath != "." && containsString(modules, owningModule(subpath+"/", modules)) {
+        owner := owningModule(subpath+"/", modules)
+        if detection, ok := detectModule(ctx, clonePath, owner, modules, progress); ok {
+            detection.ServiceName = filepath.Base(subpath)
+            detection.Candidates = filterCandidates(detection.Candidates, subpath)
+            result.Framework = detection.Language
+            result.HasMetrics = detection.HasMetrics
+            result.HasOTel = detection.HasOTel
+            result.Services = append(result.Services, detection.ServiceName)
+            result.Detections = append(result.Detections, detection)
+        }
+    }
+
+    result.ExistingObservability = detectInfra(clonePath)
+    result.Unsupported = len(result.Detections) == 0
+
+    message := fmt.Sprintf("Found %d service(s)", len(result.Detections))
+    if result.Unsupported {
+        message = "No supported framework detected"
+    }
+    progress.emit(ScanEvent{Stage: StageComplete, Message: message})
     return result, nil
 }
 
+// uniqueServiceNames renames services that share a name, e.g. svc-a/api
+// and svc-b/api, after their path (svc-a-api and svc-b-api), numbering any
+// that still collide, so each service of the repo is saved
+func uniqueServiceNames(detections []FrameworkDetection) {
+    count := map[string]int{}
+    for _, d := range detections {
+        count[d.ServiceName]++
+    }
+    taken := map[string]bool{}
+    for i, d := range detections {
+        if count[d.ServiceName] > 1 && d.Path != "." {
+            detections[i].ServiceName = strings.ReplaceAll(d.Path, "/", "-")
+        }
+    }
+    for i, d := range detections {
+        name := d.ServiceName
+        for n := 2; taken[name]; n++ {
+            name = fmt.Sprintf("%s-%d", d.ServiceName, n)
+        }
+        detections[i].ServiceName = name
+        taken[name] = true
+    }
+}
+
+// GitHubHost is the git host tokens are sent to: the configured GitHub
+// Enterprise Server host (e.g. "github.mycorp.com"), or "github.com"
+func GitHubHost() string {
+    if settings.GitHubHost != "" {
+        return settings.GitHubHost
+    }
+    return "github.com"
+}
+
+// GitHubAuth returns git credentials for an https GitHub URL, the same way
+// the PR flow authenticates pushes. Nil is returned when token is empty or
+// the URL isn't on GitHubHost.
+func GitHubAuth(repoURL, token string) transport.AuthMethod {
+    if token == "" || !strings.HasPrefix(repoURL, "https://"+GitHubHost()+"/") {
+        return nil
+    }
+    return &githttp.BasicAuth{Username: "x-access-token", Password: token}
+}
+
+// filterCandidates keeps only candidate files inside subpath
+func filterCandidates(candidates []Candidate, subpath string) []Candidate {
+    filtered := []Candidate{}
+    for _, c := range candidates {
+        files := []string{}
+        for _, f := range c.Files {
+            if strings.HasPrefix(f, subpath+"/") {
+                files = append(files, f)
+            }
+        }
+        if len(files) > 0 {
+            c.Files = files
+            if c.Matches != nil {
+                matches := []Match{}
+                for _, m := range c.Matches {
+                    if strings.HasPrefix(m.File, subpath+"/") {
+                        matches = append(matches, m)
+                    }
+                }
+                c.Matches = matches
+            }
+            filtered = append(filtered, c)
+        }
+    }
+    return filtered
+}
+
+func containsString(list []string, s string) bool {
+    for _, item := range list {
+        if item == s {
+            return true
+        }
+    }
+    return false
+}
+
+// withinSubpath reports whether a repo-relative dir lies inside subpath
+func withinSubpath(dir, subpath string) bool {
+    return subpath == "." || dir == subpath || strings.HasPrefix(dir, subpath+"/")
+}
+
+// moduleManifests mark a directory as the root of a service
+var moduleManifests = []string{
+    "go.mod", "package.json", "pom.xml", "build.gradle", "build.gradle.kts",
+    "requirements.txt", "setup.py", "pyproject.toml", "Pipfile",
+    "Cargo.toml", "Gemfile",
+}
+
+// moduleSkipDirs are never searched for service modules
+var moduleSkipDirs = map[string]bool{
+    ".git": true, "vendor": true, "node_modules": true,
+    "dist": true, "build": true, "target": true,
+}
+
+// findModules returns repo-relative directories containing a build manifest,
+// with the repo root first when it is itself a module. A go.work at the root
+// decides which go.mod directories are Go modules.
+func findModules(root string) []string {
+    modules := []string{}
+    filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+        if err != nil || !d.IsDir() {
+            return nil
+        }
+        if path != root && moduleSkipDirs[d.Name()] {
+            return filepath.SkipDir
+        }
+
+        isModule := false
+        for _, manifest := range moduleManifests {
+            if _, err := os.Stat(filepath.Join(path, manifest)); err == nil {
+                isModule = true
+                break
+            }
+        }
+        if !isModule {
+            projects, _ := filepath.Glob(filepath.Join(path, "*.csproj"))
+            isModule = len(projects) > 0
+        }
+
+        if isModule {
+            rel, _ := filepath.Rel(root, path)
+            modules = append(modules, filepath.ToSlash(rel))
+        }
+        return nil
+    })
+    return applyGoWorkspace(root, modules)
+}
+
+// detectModule runs language detection and candidate collection for one module
+func detectModule(ctx context.Context, root, dir string, modules []string, progress ProgressFunc) (FrameworkDetection, bool) {
+    path := filepath.Join(root, dir)
+    detection := FrameworkDetection{Path: dir, Candidates: []Candidate{}}
+    var candidate Candidate
+    var found bool
+
+    if detectPython(path) {
+        detection.Language = "Python"
+        detection.ServiceName = "python-service"
+        if detectDjango(path) {
+            detection.Framework = "Django"
+            detection.ServiceName = "django-app"
+        } else if detectFastAPI(path) {
+            detection.Framework = "FastAPI"
+            detection.ServiceName = "fastapi-app"
+        } else if detectFlask(path) {
+            detection.Framework = "Flask"
+            detection.ServiceName = "flask-app"
+        }
+        candidate, found = pythonCandidate(path, detection.Framework)
+    } else if detectGo(path) {
+        detection.Language = "Go"
+        detection.ServiceName = "go-service"
+        detection.Framework = detectGoFramework(path)
+        detection.ModulePath = goModulePath(path)
+        candidate, found = goCandidate(path)
+    } else if detectJava(path) {
+        detection.Language = "Java"
+        detection.ServiceName = "java-service"
+        detection.Framework = detectJavaFramework(path)
+        candidate, found = javaCandidate(path, detection.Framework)
+    } else if detectDotnet(path) {
+        detection.Language = ".NET"
+        detection.ServiceName = "dotnet-service"
+        detection.Framework = "ASP.NET Core"
+        candidate, found = dotnetCandidate(path)
+    } else if detectRuby(path) {
+        // Before Node.js: Rails apps often carry a package.json for assets
+        detection.Language = "Ruby"
+        detection.ServiceName = "ruby-service"
+        detection.Framework = detectRubyFramework(path)
+        candidate, found = rubyCandidate(path, detection.Framework)
+    } else if detectNode(path) {
+        detection.Language = "Node.js"
+        detection.ServiceName = "nodejs-service"
+        detection.Framework = detectNodeFramework(path)
+        candidate, found = nodeCandidate(path)
+    } else if detectRust(path) {
+        detection.Language = "Rust"
+        detection.ServiceName = "rust-service"
+        detection.Framework = detectRustFramework(path)
+        candidate, found = rustCandidate(path)
+    } else {
+        return detection, false
+    }
+
+    // gRPC servers get interceptors rather than HTTP middleware. A Go
+    // module without a web framework or HTTP server is a gRPC service, so
+    // its func main() isn't taken for a net/http app.
+    grpc, hasGRPC := grpcCandidate(path, detection.Language)
+    if hasGRPC && detection.Language == "Go" && detection.Framework == "net/http" && !servesGoHTTP(path) {
+        detection.Framework = "gRPC"
+        found = false
+    }
+    detectEvidence(path, dir, modules, &detection)
+    detection.FrameworkVersion = detectFrameworkVersion(path, detection.Language, detection.Framework)
+    detection.Port = detectPort(path, detection.Language)
+    progress.emit(ScanEvent{
+        Stage:     StageLanguageDetected,
+        Message:   fmt.Sprintf("Detected %s in %s", detection.Language, dir),
+        Path:      dir,
+        Language:  detection.Language,
+        Framework: detection.Framework,
+    })
+
+    // Services in subdirectories are named after their directory; scanDir
+    // renames those sharing a name after their path
+    if dir != "." {
+        detection.ServiceName = filepath.Base(dir)
+    }
+    if found {
+        detection.Candidates = append(detection.Candidates, candidate)
+    }
+    if hasGRPC {
+        detection.Candidates = append(detection.Candidates, grpc)
+    }
+    if logs, ok := logsCandidate(path, detection.Language); ok {
+        detection.Candidates = append(detection.Candidates, logs)
+    }
+    if database, ok := databaseCandidate(path, detection.Language); ok {
+        detection.Candidates = append(detection.Candidates, database)
+    }
+
+    // Prefer structured analysis and fall back to grep patterns on error
+    analyzed := false
+    if detection.Language == "Node.js" {
+        if candidates, err := AnalyzeNodeRepo(path); err == nil {
+            detection.Candidates = append(detection.Candidates, candidates...)
+            detection.HasMetrics = hasCandidate(candidates, "metrics")
+            detection.HasOTel = hasCandidate(candidates, "traces")
+            analyzed = true
+        } else {
+            logging.FromContext(ctx).Warn("Node.js analysis failed, falling back to grep", "module", dir, "error", err)
+        }
+    }
+    if !analyzed {
+        detection.HasMetrics = detectMetrics(path, detection.Language)
+        detection.HasOTel = detectOTel(path, detection.Language)
+    }
+
+    detection.Candidates = scopeCandidates(detection.Candidates, dir, modules)
+    progress.emit(ScanEvent{
+        Stage:      StageCandidatesFound,
+        Message:    fmt.Sprintf("Found %d instrumentation candidate(s) in %s", len(detection.Candidates), dir),
+        Path:       dir,
+        Language:   detection.Language,
+        Framework:  detection.Framework,
+        Candidates: len(detection.Candidates),
+    })
+    return detection, true
+}
+
+// scopeCandidates rewrites module-relative paths to be repo-relative and
+// drops files that belong to a nested module.
+func scopeCandidates(candidates []Candidate, dir string, modules []string) []Candidate {
+    scoped := []Candidate{}
+    for _, c := range candidates {
+        files := []string{}
+        for _, f := range c.Files {
+            rel := filepath.ToSlash(filepath.Join(dir, f))
+            if owningModule(rel, modules) == dir {
+                files = append(files, rel)
+            }
+        }
+        if len(files) == 0 {
+            continue
+        }
+        c.Files = files
+        c.Matches = scopeMatches(c.Matches, dir, modules)
+        if c.Manifest != "" {
+            c.Manifest = filepath.ToSlash(filepath.Join(dir, c.Manifest))
+        }
+        scoped = append(scoped, c)
+    }
+    return scoped
+}
+
+// scopeMatches is scopeCandidates for a candidate's matches
+func scopeMatches(matches []Match, dir string, modules []string) []Match {
+    if matches == nil {
+        return nil
+    }
+    scoped := []Match{}
+    for _, m := range matches {
+        m.File = filepath.ToSlash(filepath.Join(dir, m.File))
+        if owningModule(m.File, modules) == dir {
+            scoped = append(scoped, m)
+        }
+    }
+    return scoped
+}
+
+// owningModule returns the deepest module directory containing file
+func owningModule(file string, modules []string) string {
+    owner := "."
+    for _, m := range modules {
+        if m == "." || !strings.HasPrefix(file, m+"/") {
+            continue
+        }
+        if owner == "." || len(m) > len(owner) {
+            owner = m
+        }
+    }
+    return owner
+}
+
+// hasCandidate reports whether any candidate of the given kind was found
+func hasCandidate(candidates []Candidate, kind string) bool {
+    for _, c := range candidates {
+        if c.Kind == kind {
+            return true
+        }
+    }
+    return false
+}
+
 // Framework Detection
 func detectPython(path string) bool {
     files := []string{"requirements.txt", "setup.py", "pyproject.toml", "Pipfile"}
@@ -70,12 +559,17 @@ func detectPython(path string) bool {
 
 func detectDjango(path string) bool {
     content, _ := os.ReadFile(filepath.Join(path, "requirements.txt"))
-    return strings.Contains(string(content), "django")
+    return strings.Contains(strings.ToLower(string(content)), "django")
+}
+
+func detectFastAPI(path string) bool {
+    content, _ := os.ReadFile(filepath.Join(path, "requirements.txt"))
+    return strings.Contains(strings.ToLower(string(content)), "fastapi")
 }
 
 func detectFlask(path string) bool {
     content, _ := os.ReadFile(filepath.Join(path, "requirements.txt"))
-    return strings.Contains(string(content), "flask")
+    return strings.Contains(strings.ToLower(string(content)), "flask")
 }
 
 func detectGo(path string) bool {
@@ -83,14 +577,30 @@ func detectGo(path string) bool {
     return err == nil
 }
 
+// goModulePath returns the module path declared in go.mod, or ""
+func goModulePath(path string) string {
+    content, err := os.ReadFile(filepath.Join(path, "go.mod"))
+    if err != nil {
+        return ""
+    }
+    return modfile.ModulePath(content)
+}
+
+// javaBuildFiles are the Maven and Gradle builds, in order of preference
+var javaBuildFiles = []string{"pom.xml", "build.gradle.kts", "build.gradle"}
+
 func detectJava(path string) bool {
-    files := []string{"pom.xml", "build.gradle"}
-    for _, f := range files {
+    return javaBuildFile(path) != ""
+}
+
+// javaBuildFile returns the module's Maven or Gradle build file, or ""
+func javaBuildFile(path string) string {
+    for _, f := range javaBuildFiles {
         if _, err := os.Stat(filepath.Join(path, f)); err == nil {
-            return true
+            return f
         }
     }
-    return false
+    return ""
 }
 
 func detectDotnet(path string) bool {
@@ -108,87 +618,528 @@ func detectRust(path string) bool {
     return err == nil
 }
 
+func detectRuby(path string) bool {
+    if _, err := os.Stat(filepath.Join(path, "Gemfile")); err == nil {
+        return true
+    }
+    files, _ := filepath.Glob(filepath.Join(path, "*.rb"))
+    return len(files) > 0
+}
+
+// gemPattern matches a Gemfile's gem 'name' line, capturing the name
+var gemPattern = regexp.MustCompile(`(?m)^\s*gem\s+['"]([^'"]+)['"]`)
+
+// detectRubyFramework inspects the Gemfile for Rails or Sinatra
+func detectRubyFramework(path string) string {
+    content, _ := os.ReadFile(filepath.Join(path, "Gemfile"))
+    gems := map[string]bool{}
+    for _, m := range gemPattern.FindAllStringSubmatch(string(content), -1) {
+        gems[m[1]] = true
+    }
+    switch {
+    case gems["rails"], gems["railties"]:
+        return "Rails"
+    case gems["sinatra"]:
+        return "Sinatra"
+    default:
+        return ""
+    }
+}
+
+// detectGoFramework inspects go.mod requirements for a known HTTP router
+func detectGoFramework(path string) string {
+    content, _ := os.ReadFile(filepath.Join(path, "go.mod"))
+    gomod := string(content)
+    switch {
+    case strings.Contains(gomod, "github.com/gin-gonic/gin"):
+        return "Gin"
+    case strings.Contains(gomod, "github.com/labstack/echo"):
+        return "Echo"
+    case strings.Contains(gomod, "github.com/go-chi/chi"):
+        return "Chi"
+    case strings.Contains(gomod, "github.com/gorilla/mux"):
+        return "Gorilla Mux"
+    default:
+        return "net/http"
+    }
+}
+
+// detectJavaFramework inspects the Maven or Gradle build for a known
+// application framework, defaulting to Spring Boot
+func detectJavaFramework(path string) string {
+    var build string
+    for _, f := range javaBuildFiles {
+        content, _ := os.ReadFile(filepath.Join(path, f))
+        build += string(content)
+    }
+    switch {
+    case strings.Contains(build, "io.quarkus"):
+        return "Quarkus"
+    case strings.Contains(build, "io.micronaut"):
+        return "Micronaut"
+    default:
+        return "Spring Boot"
+    }
+}
+
+// detectNodeFramework inspects package.json dependencies for a known web framework
+func detectNodeFramework(path string) string {
+    content, _ := os.ReadFile(filepath.Join(path, "package.json"))
+    pkg := string(content)
+    switch {
+    case strings.Contains(pkg, `"fastify"`):
+        return "Fastify"
+    case strings.Contains(pkg, `"koa"`):
+        return "Koa"
+    case strings.Contains(pkg, `"@hapi/hapi"`):
+        return "Hapi"
+    case strings.Contains(pkg, `"express"`), strings.Contains(pkg, `"@nestjs/core"`):
+        return "Express"
+    default:
+        return ""
+    }
+}
+
+// detectRustFramework inspects Cargo.toml dependencies for a known web framework
+func detectRustFramework(path string) string {
+    content, _ := os.ReadFile(filepath.Join(path, "Cargo.toml"))
+    manifest := string(content)
+    switch {
+    case strings.Contains(manifest, "actix-web"):
+        return "Actix"
+    case strings.Contains(manifest, "axum"):
+        return "Axum"
+    default:
+        return ""
+    }
+}
+
+// Candidate Collection
+var dotnetExtensions = []string{"cs"}
+
+var goExtensions = []string{"go"}
+
+// goRouterPatterns is the router construction call for each Go framework
+var goRouterPatterns = map[string]string{
+    "Gin":         "gin.Default()",
+    "Echo":        "echo.New()",
+    "Chi":         "chi.NewRouter()",
+    "Gorilla Mux": "mux.NewRouter()",
+    "net/http":    "func main() {",
+}
+
+// goCandidate finds the files that construct the router
+func goCandidate(path string) (Candidate, bool) {
+    framework := detectGoFramework(path)
+    matches := findMatchesInRepo(path, goRouterPatterns[framework], goExtensions)
+    if len(matches) == 0 {
+        return Candidate{}, false
+    }
+    files := matchedFiles(matches)
+    return Candidate{
+        Kind:        "http",
+        Framework:   framework,
+        Manifest:    "go.mod",
+        Files:       files,
+        MetricsPath: findGoMetricsPath(path),
+        RouterVar:   goRouterVar(path, files, framework),
+        Matches:     matches,
+    }, true
+}
+
+// grpcServer is how a language's gRPC servers are found: the dependency
+// its manifest declares and the call that constructs a server
+type grpcServer struct {
+    Manifest   string
+    Dependency string
+    Pattern    string
+    Extensions []string
+}
+
+var grpcServers = map[string]grpcServer{
+    "Go":     {Manifest: "go.mod", Dependency: "google.golang.org/grpc", Pattern: "grpc.NewServer(", Extensions: goExtensions},
+    "Java":   {Dependency: "io.grpc", Pattern: "ServerBuilder.forPort(", Extensions: javaExtensions},
+    "Python": {Manifest: "requirements.txt", Dependency: "grpcio", Pattern: "grpc.server(", Extensions: pythonExtensions},
+}
+
+// grpcCandidate finds the files that construct a gRPC server, in modules
+// that depend on gRPC
+func grpcCandidate(path, language string) (Candidate, bool) {
+    server, ok := grpcServers[language]
+    if !ok {
+        return Candidate{}, false
+    }
+    manifest := server.Manifest
+    if language == "Java" {
+        manifest = javaBuildFile(path)
+    }
+    content, _ := os.ReadFile(filepath.Join(path, manifest))
+    if manifest == "" || !strings.Contains(string(content), server.Dependency) {
+        return Candidate{}, false
+    }
+
+    matches := findMatchesInRepo(path, server.Pattern, server.Extensions)
+    if len(matches) == 0 {
+        return Candidate{}, false
+    }
+    return Candidate{Kind: "grpc", Framework: "gRPC", Manifest: manifest, Files: matchedFiles(matches), Matches: matches}, true
+}
+
+// loggingLibrary is how a logging library is found: the dependency the
+// module's manifest declares, if any, and the calls that set a logger up
+type loggingLibrary struct {
+    Name       string
+    Dependency string
+    Patterns   []string
+}
+
+// loggingLibraries are the logging libraries of each language, checked in
+// order. Those without a dependency ship with the language, or in
+// logback's case with Spring Boot, and are taken last.
+var loggingLibraries = map[string][]loggingLibrary{
+    "Go": {
+        {Name: "zap", Dependency: "go.uber.org/zap", Patterns: []string{"zap.NewProduction(", "zap.NewDevelopment(", "zap.NewExample(", "zap.New("}},
+        {Name: "logrus", Dependency: "github.com/sirupsen/logrus", Patterns: []string{"logrus.New(", "logrus.SetFormatter(", "logrus.SetLevel("}},
+        {Name: "slog", Patterns: []string{"slog.New(", "slog.SetDefault("}},
+    },
+    "Python": {
+        {Name: "structlog", Dependency: "structlog", Patterns: []string{"structlog.configure("}},
+        {Name: "logging", Patterns: []string{"logging.basicConfig(", "logging.config.dictConfig(", "logging.getLogger("}},
+    },
+    "Java": {
+        {Name: "log4j", Dependency: "log4j", Patterns: []string{"LogManager.getLogger("}},
+        {Name: "logback", Patterns: []string{"LoggerFactory.getLogger("}},
+    },
+}
+
+// dependencyManifests are the manifests library dependencies, such as
+// logging and database clients, are declared in, and sourceExtensions the
+// files they are set up in
+var dependencyManifests = map[string]string{
+    "Go":     "go.mod",
+    "Python": "requirements.txt",
+}
+
+var sourceExtensions = map[string][]string{
+    "Go":     goExtensions,
+    "Python": pythonExtensions,
+    "Java":   javaExtensions,
+}
+
+// logsCandidate finds the files that set up the module's logger, naming
+// the logging library in Framework
+func logsCandidate(path, language string) (Candidate, bool) {
+    manifest := dependencyManifests[language]
+    if language == "Java" {
+        manifest = javaBuildFile(path)
+    }
+    content, _ := os.ReadFile(filepath.Join(path, manifest))
+
+    for _, library := range loggingLibraries[language] {
+        if library.Dependency != "" && (manifest == "" || !strings.Contains(string(content), library.Dependency)) {
+            continue
+        }
+        matches := grepMatches(path, library.Patterns, sourceExtensions[language])
+        if len(matches) == 0 {
+            continue
+        }
+        return Candidate{Kind: "logs", Framework: library.Name, Manifest: manifest, Files: matchedFiles(matches), Matches: matches}, true
+    }
+    return Candidate{}, false
+}
+
+// databaseClient is how a database client is found: the dependency the
+// module's manifest declares, if any, and the calls that open a database
+type databaseClient struct {
+    Name       string
+    Dependency string
+    Patterns   []string
+}
+
+// databaseClients are the database clients of each language, checked in
+// order. GORM comes before database/sql, which it is built on.
+var databaseClients = map[string][]databaseClient{
+    "Go": {
+        {Name: "GORM", Dependency: "gorm.io/gorm", Patterns: []string{"gorm.Open("}},
+        {Name: "database/sql", Patterns: []string{"sql.Open("}},
+    },
+    "Python": {
+        {Name: "SQLAlchemy", Dependency: "sqlalchemy", Patterns: []string{"create_engine("}},
+    },
+    "Java": {
+        {Name: "JDBC", Patterns: []string{"DriverManager.getConnection(", "JdbcTemplate", "DataSource", "JpaRepository"}},
+    },
+}
+
+// databaseCandidate finds the files that open the module's database,
+// naming the client in Framework
+func databaseCandidate(path, language string) (Candidate, bool) {
+    manifest := dependencyManifests[language]
+    if language == "Java" {
+        manifest = javaBuildFile(path)
+    }
+    content, _ := os.ReadFile(filepath.Join(path, manifest))
+
+    for _, client := range databaseClients[language] {
+        if client.Dependency != "" && (manifest == "" || !strings.Contains(strings.ToLower(string(content)), client.Dependency)) {
+            continue
+        }
+        matches := grepMatches(path, client.Patterns, sourceExtensions[language])
+        if len(matches) == 0 {
+            continue
+        }
+        return Candidate{Kind: "database", Framework: client.Name, Manifest: manifest, Files: matchedFiles(matches), Matches: matches}, true
+    }
+    return Candidate{}, false
+}
+
+// servesGoHTTP reports whether any Go file starts an HTTP server
+func servesGoHTTP(path string) bool {
+    return len(findFilesInRepo(path, "ListenAndServe", goExtensions)) > 0
+}
+
+var rustExtensions = []string{"rs"}
+
+// rustCandidate finds the files that build the Actix App or Axum Router
+func rustCandidate(path string) (Candidate, bool) {
+    framework := detectRustFramework(path)
+    pattern := ""
+    switch framework {
+    case "Actix":
+        pattern = "App::new()"
+    case "Axum":
+        pattern = "Router::new()"
+    default:
+        return Candidate{}, false
+    }
+
+    files := findFilesInRepo(path, pattern, rustExtensions)
+    if len(files) == 0 {
+        return Candidate{}, false
+    }
+    return Candidate{Kind: "http", Framework: framework, Manifest: "Cargo.toml", Files: files}, true
+}
+
+// dotnetCandidate finds the ASP.NET Core host builder and the project file
+func dotnetCandidate(path string) (Candidate, bool) {
+    files := findFilesInRepo(path, "WebApplication.CreateBuilder(", dotnetExtensions)
+    if len(files) == 0 {
+        return Candidate{}, false
+    }
+
+    c := Candidate{Kind: "http", Framework: "ASP.NET Core", Files: files}
+    if projects, _ := filepath.Glob(filepath.Join(path, "*.csproj")); len(projects) > 0 {
+        c.Manifest = filepath.Base(projects[0])
+    }
+    return c, true
+}
+
+var javaExtensions = []string{"java", "kt"}
+
+// javaAppPatterns mark the application class or resources of each Java
+// framework
+var javaAppPatterns = map[string]string{
+    "Spring Boot": "@SpringBootApplication",
+    "Quarkus":     "@Path(",
+    "Micronaut":   "Micronaut.run(",
+}
+
+// javaCandidate finds the application files and the build file the
+// dependencies go into. Without application files the build file itself is
+// the anchor, so Gradle and Maven builds are always told apart.
+func javaCandidate(path, framework string) (Candidate, bool) {
+    build := javaBuildFile(path)
+    if build == "" {
+        return Candidate{}, false
+    }
+    files := findFilesInRepo(path, javaAppPatterns[framework], javaExtensions)
+    if len(files) == 0 {
+        files = []string{build}
+    }
+    return Candidate{Kind: "http", Framework: framework, Manifest: build, Files: files}, true
+}
+
+var rubyExtensions = []string{"rb", "ru"}
+
+// rubyAppPatterns mark the app of each Ruby framework: the Rails
+// application class, or the file requiring Sinatra
+var rubyAppPatterns = map[string][]string{
+    "Rails":   {"< Rails::Application"},
+    "Sinatra": {"require 'sinatra", `require "sinatra`},
+}
+
+// rubyCandidate finds the Rails application or Sinatra app files
+func rubyCandidate(path, framework string) (Candidate, bool) {
+    for _, pattern := range rubyAppPatterns[framework] {
+        files := findFilesInRepo(path, pattern, rubyExtensions)
+        if len(files) > 0 {
+            return Candidate{Kind: "http", Framework: framework, Manifest: "Gemfile", Files: files}, true
+        }
+    }
+    return Candidate{}, false
+}
+
+var pythonExtensions = []string{"py"}
+
+// pythonAppPatterns is the app construction call for each Python web
+// framework; for Django it is the settings module's middleware list
+var pythonAppPatterns = map[string]string{
+    "Django":  "MIDDLEWARE =",
+    "FastAPI": "FastAPI(",
+    "Flask":   "Flask(__name__",
+}
+
+// pythonCandidate finds the files that create the app or Django settings
+func pythonCandidate(path, framework string) (Candidate, bool) {
+    pattern, ok := pythonAppPatterns[framework]
+    if !ok {
+        return Candidate{}, false
+    }
+    matches := findMatchesInRepo(path, pattern, pythonExtensions)
+    if len(matches) == 0 {
+        return Candidate{}, false
+    }
+    c := Candidate{Kind: "http", Framework: framework, Manifest: "requirements.txt", Files: matchedFiles(matches), Matches: matches}
+    if framework != "Django" {
+        c.MetricsPath = findPythonMetricsPath(path)
+    }
+    return c, true
+}
+
+var nodeExtensions = []string{"js", "ts", "mjs", "cjs", "mts", "cts"}
+
+// nodeAppPatterns match the line constructing the app, with the lowercase
+// text a line needs to be considered: express(), new Koa(), and Fastify
+// however it is imported, e.g. Fastify({...}) or require('fastify')()
+var nodeAppPatterns = map[string]struct {
+    Needle  string
+    Pattern *regexp.Regexp
+}{
+    "Express": {"express(", regexp.MustCompile(`\bexpress\(\s*\)`)},
+    "Fastify": {"fastify", regexp.MustCompile(`(?:\b[Ff]astify|require\(\s*['"]fastify['"]\s*\))\s*\(`)},
+    "Koa":     {"new koa(", regexp.MustCompile(`\bnew\s+Koa\(`)},
+}
+
+// nodeCandidate finds the files that create the Express/Fastify/Koa app,
+// recording the lines that do, or that load Hapi
+func nodeCandidate(path string) (Candidate, bool) {
+    framework := detectNodeFramework(path)
+    if framework == "Hapi" {
+        files := findFilesInRepo(path, "@hapi/hapi", nodeExtensions)
+        if len(files) == 0 {
+            return Candidate{}, false
+        }
+        return Candidate{Kind: "http", Framework: framework, Files: files}, true
+    }
+    if framework == "" {
+        framework = "Express"
+    }
+
+    app := nodeAppPatterns[framework]
+    matches := []Match{}
+    for _, m := range findMatchesInRepo(path, app.Needle, nodeExtensions) {
+        if app.Pattern.MatchString(m.Text) {
+            matches = append(matches, m)
+        }
+    }
+    if len(matches) == 0 {
+        return Candidate{}, false
+    }
+    return Candidate{Kind: "http", Framework: framework, Files: matchedFiles(matches), Matches: matches}, true
+}
+
+// metricsRegistrationPatterns mark where metrics are registered, per language
+var metricsRegistrationPatterns = map[string][]string{
+    "Python": {
+        "prometheus_client.start_http_server(",
+        "start_http_server(",
+        "CollectorRegistry()",
+    },
+    "Go": {
+        "prometheus.MustRegister(",
+        "prometheus.Register(",
+        "registry.MustRegister(",
+        "registry.Register(",
+    },
+    "Java": {
+        "new PrometheusMeterRegistry(",
+        "new SimpleMeterRegistry(",
+        "@Bean.*MeterRegistry",
+    },
+    ".NET": {
+        "UsePrometheusServer(",
+        "new KestrelMetricServer(",
+    },
+    "Node.js": {
+        "register.registerMetric(",
+        "collectDefaultMetrics(",
+    },
+    "Rust": {
+        "prometheus::register(",
+    },
+    "Ruby": {
+        "Prometheus::Client.registry",
+        "Prometheus::Client::Registry.new",
+        "Prometheus::Middleware::Exporter",
+    },
+}
+
+// metricsUsagePatterns mark where metrics are recorded or served
+var metricsUsagePatterns = map[string][]string{
+    "Python": {
+        ".inc(",
+        ".dec(",
+        ".set(",
+        ".observe(",
+    },
+    "Go": {
+        ".Inc(",
+        ".Dec(",
+        ".Add(",
+        ".Set(",
+        ".Observe(",
+        "promhttp.Handler()",
+        "http.Handle(\"/metrics\"",
+        "http.HandleFunc(\"/metrics\"",
+        "router.GET(\"/metrics\"",
+        "router.Handle(\"/metrics\"",
+    },
+    "Java": {
+        ".counter(",
+        ".gauge(",
+        ".timer(",
+        ".increment(",
+    },
+    ".NET": {
+        ".Inc(",
+        ".Set(",
+        ".Observe(",
+    },
+    "Node.js": {
+        ".inc(",
+        ".set(",
+        ".observe(",
+        "register.metrics()",
+    },
+    "Rust": {
+        ".inc(",
+        ".set(",
+        ".observe(",
+    },
+    "Ruby": {
+        ".increment(",
+        ".set(",
+        ".observe(",
+        "Prometheus::Middleware::Collector",
+    },
+}
+
 // TWO-PASS METRICS DETECTION
 // Pass 1: Check for registration/initialization
 // Pass 2: Check for actual usage
 func detectMetrics(path string, framework string) bool {
-    // Registration patterns - metrics must be registered
-    registrationPatterns := map[string][]string{
-        "Python": {
-            "prometheus_client.start_http_server(",
-            "start_http_server(",
-            "CollectorRegistry()",
-        },
-        "Go": {
-            "prometheus.MustRegister(",
-            "prometheus.Register(",
-            "registry.MustRegister(",
-            "registry.Register(",
-        },
-        "Java": {
-            "new PrometheusMeterRegistry(",
-            "new SimpleMeterRegistry(",
-            "@Bean.*MeterRegistry",
-        },
-        ".NET": {
-            "UsePrometheusServer(",
-            "new KestrelMetricServer(",
-        },
-        "Node.js": {
-            "register.registerMetric(",
-            "collectDefaultMetrics(",
-        },
-        "Rust": {
-            "prometheus::register(",
-        },
-    }
-
-    // Usage patterns - metrics must be actually used
-    usagePatterns := map[string][]string{
-        "Python": {
-            ".inc(",
-            ".dec(",
-            ".set(",
-            ".observe(",
-        },
-        "Go": {
-            ".Inc(",
-            ".Dec(",
-            ".Add(",
-            ".Set(",
-            ".Observe(",
-            "promhttp.Handler()",
-            "http.Handle(\"/metrics\"",
-            "http.HandleFunc(\"/metrics\"",
-            "router.GET(\"/metrics\"",
-            "router.Handle(\"/metrics\"",
-        },
-        "Java": {
-            ".counter(",
-            ".gauge(",
-            ".timer(",
-            ".increment(",
-        },
-        ".NET": {
-            ".Inc(",
-            ".Set(",
-            ".Observe(",
-        },
-        "Node.js": {
-            ".inc(",
-            ".set(",
-            ".observe(",
-            "register.metrics()",
-        },
-        "Rust": {
-            ".inc(",
-            ".set(",
-            ".observe(",
-        },
-    }
-
-    regPatterns := registrationPatterns[framework]
-    usePatterns := usagePatterns[framework]
+    regPatterns := metricsRegistrationPatterns[framework]
+    usePatterns := metricsUsagePatterns[framework]
     
     if regPatterns == nil || usePatterns == nil {
         return false
@@ -215,78 +1166,87 @@ func detectMetrics(path string, framework string) bool {
     return hasRegistration && hasUsage
 }
 
+// otelInitPatterns mark where a tracer provider is set up, per language
+var otelInitPatterns = map[string][]string{
+    "Python": {
+        "TracerProvider(",
+        "OTLPSpanExporter(",
+        "JaegerExporter(",
+        "trace.set_tracer_provider(",
+    },
+    "Go": {
+        "sdktrace.NewTracerProvider(",
+        "otel.SetTracerProvider(",
+        "otlptrace",
+        "otlptracegrpc.New(",
+        "jaeger.New(",
+    },
+    "Java": {
+        "SdkTracerProvider.builder(",
+        "OpenTelemetrySdk.builder(",
+        "OtlpGrpcSpanExporter",
+    },
+    ".NET": {
+        "TracerProvider.Default.GetTracer(",
+        "new TracerProviderBuilder(",
+    },
+    "Node.js": {
+        "new NodeTracerProvider(",
+        "new BasicTracerProvider(",
+        "new OTLPTraceExporter(",
+    },
+    "Rust": {
+        "global::set_tracer_provider(",
+        "opentelemetry::sdk::trace::TracerProvider",
+    },
+    "Ruby": {
+        "OpenTelemetry::SDK.configure",
+    },
+}
+
+// otelUsagePatterns mark where spans are created
+var otelUsagePatterns = map[string][]string{
+    "Python": {
+        "tracer.start_as_current_span(",
+        "tracer.start_span(",
+        "@tracer.start_as_current_span",
+    },
+    "Go": {
+        "tracer.Start(",
+        "otel.Tracer(",
+        "span.End(",
+        "span.SetAttributes(",
+    },
+    "Java": {
+        "tracer.spanBuilder(",
+        "span.end(",
+    },
+    ".NET": {
+        "tracer.StartActiveSpan(",
+        "var span =",
+    },
+    "Node.js": {
+        "tracer.startSpan(",
+        "tracer.startActiveSpan(",
+    },
+    "Rust": {
+        "tracer.in_span(",
+        "tracer.start(",
+    },
+    "Ruby": {
+        "in_span(",
+        "start_span(",
+        "c.use_all",
+        "c.use ",
+    },
+}
+
 // TWO-PASS OTEL DETECTION
 // Pass 1: Check for tracer provider initialization
 // Pass 2: Check for actual span creation/usage
 func detectOTel(path string, framework string) bool {
-    // Initialization patterns - tracer must be initialized
-    initPatterns := map[string][]string{
-        "Python": {
-            "TracerProvider(",
-            "OTLPSpanExporter(",
-            "JaegerExporter(",
-            "trace.set_tracer_provider(",
-        },
-        "Go": {
-            "sdktrace.NewTracerProvider(",
-            "otel.SetTracerProvider(",
-            "otlptrace",
-            "otlptracegrpc.New(",
-            "jaeger.New(",
-        },
-        "Java": {
-            "SdkTracerProvider.builder(",
-            "OpenTelemetrySdk.builder(",
-            "OtlpGrpcSpanExporter",
-        },
-        ".NET": {
-            "TracerProvider.Default.GetTracer(",
-            "new TracerProviderBuilder(",
-        },
-        "Node.js": {
-            "new NodeTracerProvider(",
-            "new BasicTracerProvider(",
-            "new OTLPTraceExporter(",
-        },
-        "Rust": {
-            "global::set_tracer_provider(",
-            "opentelemetry::sdk::trace::TracerProvider",
-        },
-    }
-
-    // Usage patterns - spans must be created
-    usagePatterns := map[string][]string{
-        "Python": {
-            "tracer.start_as_current_span(",
-            "tracer.start_span(",
-            "@tracer.start_as_current_span",
-        },
-        "Go": {
-            "tracer.Start(",
-            "otel.Tracer(",
-            "span.End(",
-            "span.SetAttributes(",
-        },
-        "Java": {
-            "tracer.spanBuilder(",
-            "span.end(",
-        },
-        ".NET": {
-            "tracer.StartActiveSpan(",
-            "var span =",
-        },
-        "Node.js": {
-            "tracer.startSpan(",
-            "tracer.startActiveSpan(",
-        },
-        "Rust": {
-            "tracer.in_span(",
-            "tracer.start(",
-        },
-    }
-
-    initPats := initPatterns[framework]
-    usePats := usagePatterns[framework]
+    initPats := otelInitPatterns[framework]
+    usePats := otelUsagePatterns[framework]
     
     if initPats == nil || usePats == nil {
         return false
@@ -313,17 +1273,134 @@ func detectOTel(path string, framework string) bool {
     return hasInit && hasUsage
 }
 
+// skipDirs are never descended into when searching a repo
+var skipDirs = map[string]bool{
+    "vendor":       true,
+    "node_modules": true,
+    ".git":         true,
+    "dist":         true,
+}
+
+// walkRepoFiles calls fn with the path and comment-stripped content of
+// every file under repoPath that include accepts, stopping once fn returns
+// true. Vendored dependencies, build output in dist and .git are skipped,
+// and so are nested modules when repoPath is a Go module.
+func walkRepoFiles(repoPath string, include func(name string) bool, fn func(path, code string) bool) {
+    walkFiles(repoPath, detectGo(repoPath), include, fn)
+}
+
+// walkFiles is walkRepoFiles, skipping nested Go modules only when
+// skipNested is set
+func walkFiles(repoPath string, skipNested bool, include func(name string) bool, fn func(path, code string) bool) {
+    done := false
+    filepath.WalkDir(repoPath, func(path string, d os.DirEntry, err error) error {
+        if err != nil || done {
+            return nil
+        }
+        if d.IsDir() {
+            if path != repoPath && (skipDirs[d.Name()] || skipNested && nestedGoModule(path)) {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        if !d.Type().IsRegular() || !include(d.Name()) {
+            return nil
+        }
+        content, err := os.ReadFile(path)
+        if err != nil {
+            return nil
+        }
+        if fn(path, stripComments(string(content), path)) {
+            done = true
+            return filepath.SkipAll
+        }
+        return nil
+    })
+}
+
 // Helper: Search pattern in repo files recursively
-// Excludes vendor, node_modules, and test files to reduce false positives
+// Excludes vendor, node_modules, and test and generated files to reduce
+// false positives
 func searchInRepo(repoPath, pattern string) bool {
-    cmd := exec.Command("grep", "-r", "-i", 
-        "--exclude-dir=vendor",
-        "--exclude-dir=node_modules",
-        "--exclude-dir=.git",
-        "--exclude=*_test.go",
-        "--exclude=*_test.py",
-        "--exclude=*.test.js",
-        pattern, repoPath)
-    err := cmd.Run()
-    return err == nil
+    re, err := regexp.Compile("(?i)" + grepPattern(pattern))
+    if err != nil {
+        return false
+    }
+
+    found := false
+    notExcluded := func(name string) bool {
+        return !excludedFile(name)
+    }
+    walkRepoFiles(repoPath, notExcluded, func(path, code string) bool {
+        found = re.MatchString(code)
+        return found
+    })
+    return found
+}
+
+// grepPattern converts a grep-style pattern, where only . and * are
+// special, to Go regexp syntax
+func grepPattern(pattern string) string {
+    quoted := regexp.QuoteMeta(pattern)
+    return strings.NewReplacer(`\.`, ".", `\*`, "*").Replace(quoted)
+}
+
+// findMatchesInRepo lists the lines containing pattern, ignoring case, in
+// files with the given extensions, skipping test and generated files.
+// Files are relative to repoPath.
+func findMatchesInRepo(repoPath, pattern string, extensions []string) []Match {
+    needle := strings.ToLower(pattern)
+    withExtension := func(name string) bool {
+        return hasExtension(name, extensions) && !excludedFile(name)
+    }
+
+    matches := []Match{}
+    walkRepoFiles(repoPath, withExtension, func(path, code string) bool {
+        if !strings.Contains(strings.ToLower(code), needle) {
+            return false
+        }
+        rel, err := filepath.Rel(repoPath, path)
+        if err != nil {
+            return false
+        }
+        for i, line := range strings.Split(code, "\n") {
+            if strings.Contains(strings.ToLower(line), needle) {
+                matches = append(matches, Match{File: filepath.ToSlash(rel), Line: i + 1, Text: strings.TrimSpace(line)})
+            }
+        }
+        return false
+    })
+    return matches
+}
+
+// matchedFiles lists the distinct files of matches in order
+func matchedFiles(matches []Match) []string {
+    files := []string{}
+    for i, m := range matches {
+        if i == 0 || matches[i-1].File != m.File {
+            files = append(files, m.File)
+        }
+    }
+    return files
+}
+
+// Helper: List repo-relative files containing pattern, limited to the given
+// extensions and skipping test and generated files
+func findFilesInRepo(repoPath, pattern string, extensions []string) []string {
+    needle := strings.ToLower(pattern)
+    withExtension := func(name string) bool {
+        return hasExtension(name, extensions) && !excludedFile(name)
+    }
+
+    files := []string{}
+    walkRepoFiles(repoPath, withExtension, func(path, code string) bool {
+        if !strings.Contains(strings.ToLower(code), needle) {
+            return false
+        }
+        if rel, err := filepath.Rel(repoPath, path); err == nil {
+            files = append(files, filepath.ToSlash(rel))
+        }
+        return false
+    })
+    return files
 }
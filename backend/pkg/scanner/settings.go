@@ -0,0 +1,84 @@
+package scanner
+
+import (
+    "path/filepath"
+    "time"
+)
+
+// Settings configure how repos are fetched and searched. The zero value
+// clones public github.com repos with the default timeout and no size
+// limit, clones afresh for every scan and skips test and generated
+// files.
+type Settings struct {
+    // GitHubHost is the GitHub Enterprise Server host tokens are sent to,
+    // or "" for github.com
+    GitHubHost string
+    // GitHubToken authenticates clones of private repos
+    GitHubToken string
+
+    CloneTimeout  time.Duration
+    MaxCloneBytes int64
+
+    // CloneCacheSize is how many scan checkouts are kept for reuse while
+    // their branch doesn't move; zero clones afresh for every scan
+    CloneCacheSize int
+    // CloneCacheTTL is how long an unused cached checkout is kept; zero
+    // uses DefaultCloneCacheTTL
+    CloneCacheTTL time.Duration
+
+    // IncludeTestFiles searches every source file for instrumentation and
+    // anchors, rather than skipping those matching ExcludePatterns
+    IncludeTestFiles bool
+    // ExcludePatterns are filepath.Match patterns of the file names
+    // skipped, e.g. "*_test.go"; nil uses DefaultExcludePatterns
+    ExcludePatterns []string
+}
+
+// DefaultExcludePatterns match the test and generated files instrumentation
+// is never anchored on
+var DefaultExcludePatterns = []string{
+    // Tests: main_test.go, test_app.py, app.spec.ts, users_spec.rb,
+    // AppTest.java, ServiceTests.cs
+    "*_test.*",
+    "*_spec.*",
+    "test_*.*",
+    "*.test.*",
+    "*.spec.*",
+    "*Test.*",
+    "*Tests.*",
+    // Generated code: protobuf and gRPC stubs, Kubernetes deepcopy,
+    // designer files
+    "*.pb.go",
+    "*.pb.*.go",
+    "zz_generated*",
+    "*_generated.*",
+    "*_pb2.py",
+    "*_pb2_grpc.py",
+    "*.Designer.cs",
+    "*.g.cs",
+}
+
+var settings Settings
+
+// Configure sets the package's settings. It is meant to be called once at
+// startup, before any scan.
+func Configure(s Settings) {
+    settings = s
+}
+
+// excludedFile reports whether a file, by name, is left out of searches
+func excludedFile(name string) bool {
+    if settings.IncludeTestFiles {
+        return false
+    }
+    patterns := settings.ExcludePatterns
+    if patterns == nil {
+        patterns = DefaultExcludePatterns
+    }
+    for _, pattern := range patterns {
+        if ok, _ := filepath.Match(pattern, filepath.Base(name)); ok {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,120 @@
+package scanner
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+const (
+    goServer = "package main\n\nimport \"net/http\"\n\nfunc main() {\n    http.ListenAndServe(\":8080\", nil)\n}\n"
+
+    goMetrics = "package main\n\nimport (\n    \"net/http\"\n\n    \"github.com/prometheus/client_golang/prometheus\"\n    \"github.com/prometheus/client_golang/prometheus/promhttp\"\n)\n\nvar requests = prometheus.NewCounter(prometheus.CounterOpts{Name: \"requests_total\"})\n\nfunc serveMetrics() {\n    prometheus.MustRegister(requests)\n    requests.Inc()\n    http.Handle(\"/metrics\", promhttp.Handler())\n}\n"
+)
+
+func TestScanSkipsTestAndGeneratedFiles(t *testing.T) {
+    tests := []struct {
+        name        string
+        file        string // where goMetrics is written
+        settings    Settings
+        wantMetrics bool
+    }{
+        {name: "production file", file: "metrics.go", wantMetrics: true},
+        {name: "go test file", file: "metrics_test.go"},
+        {name: "go test file in a package", file: "internal/server/metrics_test.go"},
+        {name: "generated protobuf file", file: "metrics.pb.go"},
+        {name: "generated deepcopy file", file: "zz_generated.deepcopy.go"},
+        {name: "test files included", file: "metrics_test.go", settings: Settings{IncludeTestFiles: true}, wantMetrics: true},
+        {name: "custom patterns replace the defaults", file: "metrics_test.go", settings: Settings{ExcludePatterns: []string{"*_mock.go"}}, wantMetrics: true},
+        {name: "custom pattern", file: "metrics_mock.go", settings: Settings{ExcludePatterns: []string{"*_mock.go"}}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            previous := settings
+            Configure(tt.settings)
+            t.Cleanup(func() { Configure(previous) })
+
+            dir := t.TempDir()
+            files := map[string]string{
+                "go.mod":  "module example.com/api\n\ngo 1.21\n\nrequire github.com/prometheus/client_golang v1.17.0\n",
+                "main.go": goServer,
+                tt.file:   goMetrics,
+            }
+            for name, content := range files {
+                path := filepath.Join(dir, name)
+                if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+                    t.Fatal(err)
+                }
+                if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+                    t.Fatal(err)
+                }
+            }
+
+            found := findFilesInRepo(dir, "promhttp.Handler()", goExtensions)
+            if got := len(found) > 0; got != tt.wantMetrics {
+                t.Errorf("findFilesInRepo() = %v, want found %v", found, tt.wantMetrics)
+            }
+            if got := findGoMetricsPath(dir) == "/metrics"; got != tt.wantMetrics {
+                t.Errorf("findGoMetricsPath() = %q, want found %v", findGoMetricsPath(dir), tt.wantMetrics)
+            }
+
+            result, err := scanDir(context.Background(), dir, ".", nil)
+            if err != nil {
+                t.Fatalf("scanDir() error = %v", err)
+            }
+            if result.HasMetrics != tt.wantMetrics {
+                t.Errorf("HasMetrics = %v, want %v", result.HasMetrics, tt.wantMetrics)
+            }
+            if tt.wantMetrics {
+                return
+            }
+            for _, d := range result.Detections {
+                for _, c := range d.Candidates {
+                    for _, f := range c.Files {
+                        if strings.HasSuffix(f, filepath.Base(tt.file)) {
+                            t.Errorf("%s candidate includes %s", c.Kind, f)
+                        }
+                    }
+                }
+            }
+        })
+    }
+}
+
+func TestExcludedFile(t *testing.T) {
+    tests := []struct {
+        name string
+        want bool
+    }{
+        {name: "main.go"},
+        {name: "main_test.go", want: true},
+        {name: "test_app.py", want: true},
+        {name: "app.py"},
+        {name: "app.spec.ts", want: true},
+        {name: "app.test.js", want: true},
+        {name: "users_spec.rb", want: true},
+        {name: "AppTest.java", want: true},
+        {name: "App.java"},
+        {name: "ServiceTests.cs", want: true},
+        {name: "api.pb.go", want: true},
+        {name: "api.pb.gw.go", want: true},
+        {name: "api_pb2.py", want: true},
+        {name: "Form1.Designer.cs", want: true},
+        {name: "src/testing/server.go"},
+    }
+
+    previous := settings
+    Configure(Settings{})
+    t.Cleanup(func() { Configure(previous) })
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := excludedFile(tt.name); got != tt.want {
+                t.Errorf("excludedFile(%q) = %v, want %v", tt.name, got, tt.want)
+            }
+        })
+    }
+}
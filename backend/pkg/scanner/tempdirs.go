@@ -0,0 +1,36 @@
+package scanner
+
+import (
+    "os"
+    "sync"
+)
+
+// tempDirs are the checkouts currently on disk, so a shutting-down server
+// can remove those its in-flight work leaves behind
+var tempDirs sync.Map
+
+// MkdirTemp creates a checkout directory like os.MkdirTemp in the default
+// temp dir, tracked until RemoveTemp
+func MkdirTemp(pattern string) (string, error) {
+    dir, err := os.MkdirTemp("", pattern)
+    if err != nil {
+        return "", err
+    }
+    tempDirs.Store(dir, struct{}{})
+    return dir, nil
+}
+
+// RemoveTemp removes a directory created by MkdirTemp
+func RemoveTemp(dir string) error {
+    tempDirs.Delete(dir)
+    return os.RemoveAll(dir)
+}
+
+// RemoveTempDirs removes every directory MkdirTemp created that is still
+// on disk. It is meant for shutdown, once in-flight work has stopped.
+func RemoveTempDirs() {
+    tempDirs.Range(func(dir, _ interface{}) bool {
+        RemoveTemp(dir.(string))
+        return true
+    })
+}
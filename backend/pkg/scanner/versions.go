@@ -0,0 +1,145 @@
+package scanner
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// frameworkPackages is the dependency that carries each framework's
+// version, per manifest. The first package found wins.
+var frameworkPackages = map[string][]string{
+    "Gin":         {"github.com/gin-gonic/gin"},
+    "Echo":        {"github.com/labstack/echo/v4", "github.com/labstack/echo"},
+    "Chi":         {"github.com/go-chi/chi/v5", "github.com/go-chi/chi"},
+    "Gorilla Mux": {"github.com/gorilla/mux"},
+    "gRPC":        {"google.golang.org/grpc"},
+    "Django":      {"django"},
+    "FastAPI":     {"fastapi"},
+    "Flask":       {"flask"},
+    "Fastify":     {"fastify"},
+    "Koa":         {"koa"},
+    "Hapi":        {"@hapi/hapi"},
+    "Express":     {"express", "@nestjs/core"},
+    "Actix":       {"actix-web"},
+    "Axum":        {"axum"},
+    "Rails":       {"rails", "railties"},
+    "Sinatra":     {"sinatra"},
+}
+
+// detectFrameworkVersion returns the framework version the module depends
+// on, as written in its manifest without range operators, or "" if unknown
+func detectFrameworkVersion(path, language, framework string) string {
+    for _, pkg := range frameworkPackages[framework] {
+        var version string
+        switch language {
+        case "Go":
+            version = goModVersion(filepath.Join(path, "go.mod"), pkg)
+        case "Python":
+            version = requirementVersion(filepath.Join(path, "requirements.txt"), pkg)
+        case "Node.js":
+            version = packageJSONVersion(filepath.Join(path, "package.json"), pkg)
+        case "Rust":
+            version = cargoVersion(filepath.Join(path, "Cargo.toml"), pkg)
+        case "Ruby":
+            version = gemfileVersion(filepath.Join(path, "Gemfile"), pkg)
+        }
+        if version != "" {
+            return version
+        }
+    }
+    return ""
+}
+
+// goModVersion finds module's version in a require directive of go.mod
+func goModVersion(gomod, module string) string {
+    content, err := os.ReadFile(gomod)
+    if err != nil {
+        return ""
+    }
+    for _, line := range strings.Split(string(content), "\n") {
+        fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), "require "))
+        if len(fields) >= 2 && fields[0] == module {
+            return fields[1]
+        }
+    }
+    return ""
+}
+
+// requirementPattern matches a requirements.txt line like "flask==2.3.2",
+// "Django>=4.2,<5" or "fastapi[all] ~= 0.110"
+var requirementPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+)(\[[^\]]*\])?\s*(===|==|~=|>=|<=|!=|>|<)?\s*([0-9][^\s,;#]*)?`)
+
+// requirementVersion finds pkg's pinned or minimum version in requirements.txt
+func requirementVersion(requirements, pkg string) string {
+    content, err := os.ReadFile(requirements)
+    if err != nil {
+        return ""
+    }
+    for _, line := range strings.Split(string(content), "\n") {
+        m := requirementPattern.FindStringSubmatch(strings.TrimSpace(line))
+        if m != nil && strings.EqualFold(m[1], pkg) {
+            return m[4]
+        }
+    }
+    return ""
+}
+
+// packageJSONVersion finds pkg's version range in package.json dependencies
+func packageJSONVersion(packageJSON, pkg string) string {
+    content, err := os.ReadFile(packageJSON)
+    if err != nil {
+        return ""
+    }
+    var manifest struct {
+        Dependencies    map[string]string `json:"dependencies"`
+        DevDependencies map[string]string `json:"devDependencies"`
+    }
+    if err := json.Unmarshal(content, &manifest); err != nil {
+        return ""
+    }
+    version := manifest.Dependencies[pkg]
+    if version == "" {
+        version = manifest.DevDependencies[pkg]
+    }
+    return strings.TrimLeft(version, "^~>=< ")
+}
+
+// cargoDependencyPattern matches `name = "1.0"` and `name = { version = "1.0", ... }`
+var cargoDependencyPattern = regexp.MustCompile(`^([A-Za-z0-9_-]+)\s*=\s*(?:"([^"]*)"|\{.*?\bversion\s*=\s*"([^"]*)")`)
+
+// cargoVersion finds crate's version requirement in Cargo.toml
+func cargoVersion(cargoToml, crate string) string {
+    content, err := os.ReadFile(cargoToml)
+    if err != nil {
+        return ""
+    }
+    for _, line := range strings.Split(string(content), "\n") {
+        m := cargoDependencyPattern.FindStringSubmatch(strings.TrimSpace(line))
+        if m != nil && m[1] == crate {
+            return strings.TrimLeft(m[2]+m[3], "^~>=< ")
+        }
+    }
+    return ""
+}
+
+// gemfileRequirementPattern matches a Gemfile line like
+// `gem 'rails', '~> 7.1.2'`, capturing the name and first requirement
+var gemfileRequirementPattern = regexp.MustCompile(`^gem\s+['"]([^'"]+)['"]\s*,\s*['"]([^'"]*)['"]`)
+
+// gemfileVersion finds gem's version requirement in the Gemfile
+func gemfileVersion(gemfile, gem string) string {
+    content, err := os.ReadFile(gemfile)
+    if err != nil {
+        return ""
+    }
+    for _, line := range strings.Split(string(content), "\n") {
+        m := gemfileRequirementPattern.FindStringSubmatch(strings.TrimSpace(line))
+        if m != nil && m[1] == gem {
+            return strings.TrimLeft(m[2], "~>=< ")
+        }
+    }
+    return ""
+}
@@ -1,44 +1,222 @@
 package togglespec
 
-import "fmt"
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "strings"
 
-func GenerateToggleSpec(serviceName, framework string, hasMetrics, hasOTel bool) (telemetryMode, spec string) {
-    if hasMetrics && hasOTel {
-        telemetryMode = "both"
-        spec = fmt.Sprintf(`# ToggleSpec for %s
-telemetry_mode: both
-metrics:
-  enabled: true
-tracing:
-  enabled: true
-`, serviceName)
-    } else if hasMetrics {
-        telemetryMode = "metrics"
-        spec = fmt.Sprintf(`# ToggleSpec for %s
-telemetry_mode: metrics
-metrics:
-  enabled: true
-tracing:
-  enabled: false
-`, serviceName)
-    } else if hasOTel {
-        telemetryMode = "traces"
-        spec = fmt.Sprintf(`# ToggleSpec for %s
-telemetry_mode: traces
-metrics:
-  enabled: false
-tracing:
-  enabled: true
-`, serviceName)
-    } else {
+    "gopkg.in/yaml.v3"
+)
+
+// DefaultSamplingRate samples every trace when a spec doesn't set a rate
+const DefaultSamplingRate = 1.0
+
+// Formats a spec can be written in. YAML is the default.
+const (
+    FormatYAML = "yaml"
+    FormatJSON = "json"
+)
+
+// ValidateFormat rejects formats other than yaml and json. Empty is
+// allowed and means yaml.
+func ValidateFormat(format string) error {
+    switch format {
+    case "", FormatYAML, FormatJSON:
+        return nil
+    }
+    return fmt.Errorf("invalid format %q, allowed values: yaml, json", format)
+}
+
+// DetectFormat returns the format an existing spec is written in
+func DetectFormat(spec string) string {
+    if strings.HasPrefix(strings.TrimSpace(spec), "{") {
+        return FormatJSON
+    }
+    return FormatYAML
+}
+
+// GenerateToggleSpec returns the telemetry mode matching the signals a
+// service already has, and the spec for it in format
+func GenerateToggleSpec(serviceName, framework string, hasMetrics, hasOTel bool, samplingRate float64, format string) (telemetryMode, spec string) {
+    telemetryMode = DetectedMode(hasMetrics, hasOTel)
+    return telemetryMode, GenerateSpec(serviceName, telemetryMode, samplingRate, format)
+}
+
+// DetectedMode is the telemetry mode for a service with the given signals
+func DetectedMode(hasMetrics, hasOTel bool) string {
+    return ModeFor(hasMetrics, hasOTel, false)
+}
+
+// modeSignals is the allow-list of telemetry modes, with the signals each
+// enables, in the order Modes lists them. "logs" correlates logs with
+// traces and combines with the other signals: "metrics+logs",
+// "traces+logs", and "all" for metrics, traces and logs.
+var modeSignals = []struct {
+    Mode                   string
+    Metrics, Tracing, Logs bool
+}{
+    {"metrics", true, false, false},
+    {"traces", false, true, false},
+    {"both", true, true, false},
+    {"logs", false, false, true},
+    {"metrics+logs", true, false, true},
+    {"traces+logs", false, true, true},
+    {"all", true, true, true},
+    {"none", false, false, false},
+}
+
+// Modes are the telemetry modes
+var Modes = func() []string {
+    modes := []string{}
+    for _, m := range modeSignals {
+        modes = append(modes, m.Mode)
+    }
+    return modes
+}()
+
+// ModeSignals reports the signals a telemetry mode enables; ok is false
+// for unknown modes
+func ModeSignals(mode string) (metrics, tracing, logs, ok bool) {
+    for _, m := range modeSignals {
+        if m.Mode == mode {
+            return m.Metrics, m.Tracing, m.Logs, true
+        }
+    }
+    return false, false, false, false
+}
+
+// ModeFor is the telemetry mode enabling exactly the given signals
+func ModeFor(metrics, tracing, logs bool) string {
+    for _, mode := range Modes {
+        if m, t, l, _ := ModeSignals(mode); m == metrics && t == tracing && l == logs {
+            return mode
+        }
+    }
+    return "none"
+}
+
+// ValidateMode rejects unknown telemetry modes
+func ValidateMode(mode string) error {
+    if _, _, _, ok := ModeSignals(mode); !ok {
+        return fmt.Errorf("invalid telemetry_mode %q, allowed values: %s", mode, strings.Join(Modes, ", "))
+    }
+    return nil
+}
+
+// GenerateSpec returns the ToggleSpec for a telemetry mode, as JSON for
+// FormatJSON and YAML otherwise. The sampling rate is only written when
+// tracing is enabled; unknown modes produce a "none" spec.
+func GenerateSpec(serviceName, telemetryMode string, samplingRate float64, format string) string {
+    metrics, tracing, logs, ok := ModeSignals(telemetryMode)
+    if !ok {
         telemetryMode = "none"
-        spec = fmt.Sprintf(`# ToggleSpec for %s
-telemetry_mode: none
+    }
+    if format == FormatJSON {
+        return generateJSONSpec(telemetryMode, metrics, tracing, logs, samplingRate)
+    }
+
+    spec := fmt.Sprintf(`# ToggleSpec for %s
+telemetry_mode: %s
 metrics:
-  enabled: false
+  enabled: %t
 tracing:
-  enabled: false
-`, serviceName)
+  enabled: %t
+`, serviceName, telemetryMode, metrics, tracing)
+    if tracing {
+        spec += fmt.Sprintf("  sampling_rate: %g\n", samplingRate)
+    }
+    return spec + fmt.Sprintf("logs:\n  enabled: %t\n", logs)
+}
+
+// generateJSONSpec is GenerateSpec for FormatJSON. JSON has no comments,
+// so unlike the YAML it doesn't name the service.
+func generateJSONSpec(telemetryMode string, metrics, tracing, logs bool, samplingRate float64) string {
+    ts := ToggleSpec{TelemetryMode: telemetryMode}
+    ts.Metrics.Enabled, ts.Tracing.Enabled, ts.Logs.Enabled = metrics, tracing, logs
+    if tracing {
+        ts.Tracing.SamplingRate = &samplingRate
+    }
+
+    out, _ := json.MarshalIndent(ts, "", "  ")
+    return string(out) + "\n"
+}
+
+// ToggleSpec is the parsed form of a ToggleSpec YAML or JSON document
+type ToggleSpec struct {
+    TelemetryMode string `yaml:"telemetry_mode" json:"telemetry_mode"`
+    Metrics       Signal  `yaml:"metrics" json:"metrics"`
+    Tracing       Tracing `yaml:"tracing" json:"tracing"`
+    // Logs correlates logs with traces. Specs written before it default to
+    // disabled.
+    Logs Signal `yaml:"logs" json:"logs"`
+}
+
+// Signal toggles a single telemetry signal
+type Signal struct {
+    Enabled bool `yaml:"enabled" json:"enabled"`
+}
+
+// Tracing toggles tracing and sets the fraction of traces sampled
+type Tracing struct {
+    Enabled      bool     `yaml:"enabled" json:"enabled"`
+    SamplingRate *float64 `yaml:"sampling_rate,omitempty" json:"sampling_rate,omitempty"`
+}
+
+// ValidateSamplingRate rejects rates outside [0, 1]
+func ValidateSamplingRate(rate float64) error {
+    if rate < 0 || rate > 1 {
+        return fmt.Errorf("sampling_rate must be between 0.0 and 1.0, got %g", rate)
+    }
+    return nil
+}
+
+// ParseToggleSpec unmarshals a ToggleSpec YAML or JSON document and checks
+// that telemetry_mode agrees with the metrics and tracing flags.
+func ParseToggleSpec(spec string) (ToggleSpec, error) {
+    var ts ToggleSpec
+
+    if DetectFormat(spec) == FormatJSON {
+        decoder := json.NewDecoder(strings.NewReader(spec))
+        decoder.DisallowUnknownFields()
+        if err := decoder.Decode(&ts); err != nil {
+            return ts, fmt.Errorf("invalid toggle spec JSON: %w", err)
+        }
+    } else {
+        decoder := yaml.NewDecoder(strings.NewReader(spec))
+        decoder.KnownFields(true)
+        if err := decoder.Decode(&ts); err != nil {
+            if err == io.EOF {
+                return ts, fmt.Errorf("toggle spec is empty")
+            }
+            return ts, fmt.Errorf("invalid toggle spec YAML: %w", err)
+        }
+    }
+
+    if ts.TelemetryMode == "" {
+        return ts, fmt.Errorf("telemetry_mode is required")
+    }
+    metrics, tracing, logs, ok := ModeSignals(ts.TelemetryMode)
+    if !ok {
+        return ts, ValidateMode(ts.TelemetryMode)
+    }
+
+    if ts.Metrics.Enabled != metrics {
+        return ts, fmt.Errorf("telemetry_mode %q requires metrics.enabled: %t", ts.TelemetryMode, metrics)
+    }
+    if ts.Tracing.Enabled != tracing {
+        return ts, fmt.Errorf("telemetry_mode %q requires tracing.enabled: %t", ts.TelemetryMode, tracing)
+    }
+    if ts.Logs.Enabled != logs {
+        return ts, fmt.Errorf("telemetry_mode %q requires logs.enabled: %t", ts.TelemetryMode, logs)
+    }
+
+    if ts.Tracing.SamplingRate == nil {
+        rate := DefaultSamplingRate
+        ts.Tracing.SamplingRate = &rate
+    }
+    if err := ValidateSamplingRate(*ts.Tracing.SamplingRate); err != nil {
+        return ts, err
     }
-    return
+    return ts, nil
 }
@@ -1,3 +1,4 @@
 window.RUNTIME_CONFIG = {
-  BACKEND_URL: 'PLACEHOLDER_BACKEND_URL'
+  BACKEND_URL: 'PLACEHOLDER_BACKEND_URL',
+  API_KEY: 'PLACEHOLDER_API_KEY'
 };
@@ -38,13 +38,27 @@ interface Repo {
   github_url: string;
 }
 
+interface FrameworkDetection {
+  framework: string;
+  framework_version?: string;
+}
+
 interface ScanResult {
   framework: string;
   has_metrics: boolean;
   has_otel: boolean;
   services: string[];
+  detections?: FrameworkDetection[];
 }
 
+// e.g. "Go (Gin v1.9.1)" when the scan found the web framework's version
+const describeFramework = (result: ScanResult): string => {
+  const detection = result.detections?.[0];
+  if (!detection?.framework) return result.framework;
+  const version = detection.framework_version ? ` ${detection.framework_version}` : "";
+  return `${result.framework} (${detection.framework}${version})`;
+};
+
 interface ServiceInfo {
   name: string;
   framework: string;
@@ -52,6 +66,17 @@ interface ServiceInfo {
   has_otel: boolean;
 }
 
+// Imports run as background jobs; poll until the job finishes
+const waitForJob = async (jobId: string): Promise<any> => {
+  for (;;) {
+    const response = await axios.get(API_ENDPOINTS.JOB(jobId));
+    const job = response.data;
+    if (job.status === "done") return job.result;
+    if (job.status === "failed") throw new Error(job.error);
+    await new Promise((resolve) => setTimeout(resolve, 2000));
+  }
+};
+
 const telemetryModes = [
   {
     value: "metrics",
@@ -132,7 +157,7 @@ export default function ImportForm({
         telemetry_mode: "none",
       });
 
-      const result = response.data.result as ScanResult;
+      const result = (await waitForJob(response.data.job_id)) as ScanResult;
       setScanResult(result);
       setShowScanResult(true);
 
@@ -161,10 +186,11 @@ export default function ImportForm({
 
     setLoading(true);
     try {
-      await axios.post(API_ENDPOINTS.IMPORTS, {
+      const response = await axios.post(API_ENDPOINTS.IMPORTS, {
         github_url: githubURL,
         telemetry_mode: selectedMode,
       });
+      await waitForJob(response.data.job_id);
 
       console.log("Import successful with mode:", selectedMode);
       
@@ -242,7 +268,11 @@ export default function ImportForm({
       // Refresh repo list to update instrumentation status
       await fetchRepos();
     } catch (error: any) {
-      const msg = error.response?.data?.error || "Failed to create PR";
+      const apiError = error.response?.data?.error;
+      const details = (apiError?.details || [])
+        .map((d: { field: string; message: string }) => `${d.field}: ${d.message}`)
+        .join("\n");
+      const msg = details || apiError?.message || "Failed to create PR";
       alert(`❌ Error: ${msg}`);
       console.error("Create PR failed:", error);
     } finally {
@@ -328,7 +358,7 @@ export default function ImportForm({
 
             <Box display="flex" gap={1} mb={3}>
               <Chip
-                label={`Framework: ${scanResult.framework}`}
+                label={`Framework: ${describeFramework(scanResult)}`}
                 size="small"
                 color="primary"
                 variant="outlined"
@@ -1,8 +1,17 @@
+import axios from 'axios';
+
 const BACKEND_URL = (window as any).RUNTIME_CONFIG?.BACKEND_URL || '/api';
 
+// Multi-tenant backends scope every request to the org of this key
+const API_KEY = (window as any).RUNTIME_CONFIG?.API_KEY;
+if (API_KEY && API_KEY !== 'PLACEHOLDER_API_KEY') {
+  axios.defaults.headers.common['X-API-Key'] = API_KEY;
+}
+
 const API_ENDPOINTS = {
   REPOS: `${BACKEND_URL}/v1/repos`,
   IMPORTS: `${BACKEND_URL}/v1/imports`,
+  JOB: (jobId: string) => `${BACKEND_URL}/v1/jobs/${jobId}`,
   PLAN: (repoId: string) => `${BACKEND_URL}/v1/repos/${repoId}/plan`,
   TOGGLES: (repoId: string, service: string, env: string) => 
     `${BACKEND_URL}/v1/repos/${repoId}/services/${service}/toggles/${env}`,